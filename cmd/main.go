@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/grimerssy/todo-service/internal/config"
 	"github.com/grimerssy/todo-service/internal/server"
+	"github.com/grimerssy/todo-service/internal/service"
 	"github.com/grimerssy/todo-service/internal/wiring"
 	"github.com/grimerssy/todo-service/pkg/logging"
 	_ "github.com/lib/pq"
@@ -36,11 +39,64 @@ func main() {
 	}()
 	logger.Log(logging.InfoLevel, "starting the server")
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	go purgeTrash(jobsCtx, cfg.Trash, logger, services.TodoService)
+	go pruneRevokedTokens(jobsCtx, cfg.Tokens, logger, services.UserService)
+
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	logger.Log(logging.InfoLevel, "shutting the server down")
+	stopJobs()
 	if err := srv.Shutdown(closeDB); err != nil {
 		logger.Logf(logging.FatalLevel, "could not shutdown the server: %s", err.Error())
 	}
 }
+
+func purgeTrash(ctx context.Context, cfg service.ConfigTrash, logger logging.Logger,
+	todoService service.TodoService) {
+
+	if cfg.PurgeMinutes == 0 {
+		logger.Log(logging.WarnLevel, "trash purging is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(cfg.PurgeMinutes * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if err := todoService.PurgeTrash(ctx); err != nil {
+			logger.Logf(logging.ErrorLevel, "could not purge trash: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func pruneRevokedTokens(ctx context.Context, cfg service.ConfigTokens, logger logging.Logger,
+	userService service.UserService) {
+
+	if cfg.PruneMinutes == 0 {
+		logger.Log(logging.WarnLevel, "revoked token pruning is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(cfg.PruneMinutes * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if err := userService.PruneRevokedTokens(ctx); err != nil {
+			logger.Logf(logging.ErrorLevel, "could not prune revoked tokens: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
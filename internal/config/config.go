@@ -3,7 +3,9 @@ package config
 import (
 	"github.com/grimerssy/todo-service/internal/handler"
 	"github.com/grimerssy/todo-service/internal/server"
+	"github.com/grimerssy/todo-service/internal/service"
 	"github.com/grimerssy/todo-service/pkg/auth"
+	"github.com/grimerssy/todo-service/pkg/broker"
 	"github.com/grimerssy/todo-service/pkg/cache"
 	"github.com/grimerssy/todo-service/pkg/database"
 	"github.com/grimerssy/todo-service/pkg/encoding"
@@ -20,9 +22,13 @@ const (
 type Config struct {
 	Gin      handler.ConfigGin
 	Server   server.ConfigServer
+	Trash    service.ConfigTrash
+	Tokens   service.ConfigTokens
 	Postgres database.ConfigPostgres
 	LFU      cache.ConfigLFU
+	Broker   broker.ConfigMemory
 	JWT      auth.ConfigJWT
+	PASETO   auth.ConfigPASETO
 	Hashids  encoding.ConfigHashids
 	Bcrypt   hashing.ConfigBcrypt
 	Logrus   logging.ConfigLogrus
@@ -51,6 +57,7 @@ func NewConfig(environment string, logger logging.Logger) *Config {
 func (c *Config) ApplyEnvVariables() error {
 	const (
 		jwtSigningString = "JWT_SIGNING_STRING"
+		pasetoKey        = "PASETO_KEY"
 		postgresUser     = "POSTGRES_USER"
 		postgresPassword = "POSTGRES_PASSWORD"
 	)
@@ -61,6 +68,7 @@ func (c *Config) ApplyEnvVariables() error {
 	}
 
 	c.JWT.SigningString = env[jwtSigningString]
+	c.PASETO.Key = env[pasetoKey]
 
 	c.Postgres.Username = env[postgresUser]
 	c.Postgres.Password = env[postgresPassword]
@@ -0,0 +1,15 @@
+package core
+
+// CalendarObject is a todo as a CalDAV resource. Name and UID are empty for
+// todos not created over CalDAV.
+type CalendarObject struct {
+	Todo Todo
+	Name string
+	UID  string
+}
+
+type CalendarObjectResponse struct {
+	Name    string
+	Version uint
+	Data    []byte
+}
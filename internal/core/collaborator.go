@@ -0,0 +1,42 @@
+package core
+
+import (
+	"errors"
+)
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+// ParseRole accepts the roles an owner can grant to a collaborator.
+func ParseRole(value string) (Role, error) {
+	switch role := Role(value); role {
+	case "":
+		return RoleViewer, nil
+	case RoleEditor, RoleViewer:
+		return role, nil
+	default:
+		return "", errors.New("unknown role")
+	}
+}
+
+type Collaborator struct {
+	UserID   uint
+	Username string
+	Role     Role
+}
+
+type ShareRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type CollaboratorResponse struct {
+	ID       any    `json:"id"`
+	Username string `json:"username"`
+	Role     Role   `json:"role"`
+}
@@ -0,0 +1,28 @@
+package core
+
+import (
+	"time"
+)
+
+type Item struct {
+	ID        uint
+	TodoID    uint
+	Title     string
+	Completed bool
+	Position  uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ItemRequest struct {
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+	Position  *uint  `json:"position"`
+}
+
+type ItemResponse struct {
+	ID        any    `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+	Position  uint   `json:"position"`
+}
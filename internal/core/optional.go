@@ -0,0 +1,31 @@
+package core
+
+import (
+	"encoding/json"
+)
+
+// Optional tells a field left out of a JSON document apart from one set to
+// null. Set reports that the field was present and Null that it was null.
+type Optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{Set: true, Value: value}
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+
+	if string(data) == "null" {
+		var zero T
+		o.Null, o.Value = true, zero
+		return nil
+	}
+
+	o.Null = false
+
+	return json.Unmarshal(data, &o.Value)
+}
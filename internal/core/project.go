@@ -0,0 +1,27 @@
+package core
+
+import (
+	"time"
+)
+
+type Project struct {
+	ID        uint
+	Name      string
+	Color     string
+	Archived  bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ProjectRequest struct {
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	Archived bool   `json:"archived"`
+}
+
+type ProjectResponse struct {
+	ID       any    `json:"id"`
+	Name     string `json:"name"`
+	Color    string `json:"color,omitempty"`
+	Archived bool   `json:"archived"`
+}
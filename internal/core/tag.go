@@ -0,0 +1,21 @@
+package core
+
+import (
+	"time"
+)
+
+type Tag struct {
+	ID        uint
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type TagRequest struct {
+	Name string `json:"name"`
+}
+
+type TagResponse struct {
+	ID   any    `json:"id"`
+	Name string `json:"name"`
+}
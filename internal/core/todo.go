@@ -1,27 +1,230 @@
 package core
 
 import (
+	"errors"
+	"strings"
 	"time"
 )
 
+type Priority uint8
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = []string{
+	PriorityNone:   "none",
+	PriorityLow:    "low",
+	PriorityMedium: "medium",
+	PriorityHigh:   "high",
+	PriorityUrgent: "urgent",
+}
+
+func ParsePriority(name string) (Priority, error) {
+	if len(name) == 0 {
+		return PriorityNone, nil
+	}
+
+	for p, n := range priorityNames {
+		if n == name {
+			return Priority(p), nil
+		}
+	}
+
+	return PriorityNone, errors.New("unknown priority")
+}
+
+func (p Priority) String() string {
+	if int(p) >= len(priorityNames) {
+		return priorityNames[PriorityNone]
+	}
+
+	return priorityNames[p]
+}
+
+type TodoSortField string
+
+const (
+	SortByCreatedAt TodoSortField = "created_at"
+	SortByUpdatedAt TodoSortField = "updated_at"
+	SortByPriority  TodoSortField = "priority"
+	SortByDueAt     TodoSortField = "due_at"
+)
+
+type TodoSort struct {
+	Field      TodoSortField
+	Descending bool
+}
+
+// ParseTodoSort accepts a field name optionally prefixed with '-' for
+// descending order, e.g. "-priority". An empty value sorts by creation time.
+func ParseTodoSort(value string) (TodoSort, error) {
+	sort := TodoSort{
+		Field: SortByCreatedAt,
+	}
+
+	if len(value) == 0 {
+		return sort, nil
+	}
+
+	if strings.HasPrefix(value, "-") {
+		sort.Descending = true
+		value = value[1:]
+	}
+
+	switch field := TodoSortField(value); field {
+	case SortByCreatedAt, SortByUpdatedAt, SortByPriority, SortByDueAt:
+		sort.Field = field
+	default:
+		return TodoSort{}, errors.New("unknown sort field")
+	}
+
+	return sort, nil
+}
+
+// Value returns the value the todo is sorted by, with times in microseconds,
+// or false when it is NULL.
+func (s TodoSort) Value(todo Todo) (int64, bool) {
+	switch s.Field {
+	case SortByUpdatedAt:
+		return todo.UpdatedAt.UnixMicro(), true
+	case SortByPriority:
+		return int64(todo.Priority), true
+	case SortByDueAt:
+		if todo.DueAt == nil {
+			return 0, false
+		}
+		return todo.DueAt.UnixMicro(), true
+	default:
+		return todo.CreatedAt.UnixMicro(), true
+	}
+}
+
+type TagMatch string
+
+const (
+	MatchAnyTag  TagMatch = "any"
+	MatchAllTags TagMatch = "all"
+)
+
+func ParseTagMatch(value string) (TagMatch, error) {
+	switch match := TagMatch(value); match {
+	case "":
+		return MatchAnyTag, nil
+	case MatchAnyTag, MatchAllTags:
+		return match, nil
+	default:
+		return "", errors.New("unknown tag match")
+	}
+}
+
+type TodoFilter struct {
+	Tags     []string
+	TagMatch TagMatch
+}
+
+// TodoPage selects the todos that follow the todo with the After id, which
+// was sorted by AfterValue unless AfterNull is set.
+type TodoPage struct {
+	After      uint
+	AfterValue int64
+	AfterNull  bool
+	Limit      uint
+}
+
+type TodoPageRequest struct {
+	Cursor string
+	Limit  uint
+}
+
+type TodoPageResponse struct {
+	Todos      []TodoResponse `json:"todos"`
+	NextCursor any            `json:"nextCursor,omitempty"`
+}
+
 type Todo struct {
-	ID          uint
-	Title       string
-	Description string
-	Completed   bool
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID           uint
+	Title        string
+	Description  string
+	Completed    bool
+	Priority     Priority
+	DueAt        *time.Time
+	AutoComplete bool
+	Recurrence   string
+	ProjectID    *uint
+	Tags         []string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    *time.Time
+	Version      uint
 }
 
 type TodoRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   bool   `json:"completed"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	Completed    bool       `json:"completed"`
+	Priority     string     `json:"priority"`
+	DueAt        *time.Time `json:"dueAt"`
+	AutoComplete bool       `json:"autoComplete"`
+	Recurrence   string     `json:"recurrence"`
+	ProjectID    any        `json:"projectId"`
+}
+
+// TodoPatch holds the fields of a todo to be changed. A set DueAt or ProjectID
+// with a nil value clears the due date or moves the todo to the inbox.
+type TodoPatch struct {
+	Title        Optional[string]
+	Description  Optional[string]
+	Completed    Optional[bool]
+	Priority     Optional[Priority]
+	DueAt        Optional[*time.Time]
+	AutoComplete Optional[bool]
+	Recurrence   Optional[string]
+	ProjectID    Optional[*uint]
+	Version      uint
+}
+
+// TodoPatchRequest is a JSON Merge Patch (RFC 7396) of a TodoRequest.
+type TodoPatchRequest struct {
+	Title        Optional[string]    `json:"title"`
+	Description  Optional[string]    `json:"description"`
+	Completed    Optional[bool]      `json:"completed"`
+	Priority     Optional[string]    `json:"priority"`
+	DueAt        Optional[time.Time] `json:"dueAt"`
+	AutoComplete Optional[bool]      `json:"autoComplete"`
+	Recurrence   Optional[string]    `json:"recurrence"`
+	ProjectID    Optional[any]       `json:"projectId"`
 }
 
 type TodoResponse struct {
-	ID          any    `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description,omitempty"`
-	Completed   bool   `json:"completed"`
+	ID           any        `json:"id"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description,omitempty"`
+	Completed    bool       `json:"completed"`
+	Priority     string     `json:"priority"`
+	DueAt        *time.Time `json:"dueAt,omitempty"`
+	AutoComplete bool       `json:"autoComplete"`
+	Recurrence   string     `json:"recurrence,omitempty"`
+	ProjectID    any        `json:"projectId,omitempty"`
+	Tags         []string   `json:"tags"`
+	DeletedAt    *time.Time `json:"deletedAt,omitempty"`
+	Version      uint       `json:"version"`
+}
+
+type TodoSearchResult struct {
+	Todo               Todo
+	Rank               float32
+	TitleSnippet       string
+	DescriptionSnippet string
+}
+
+type TodoSearchResponse struct {
+	Todo               TodoResponse `json:"todo"`
+	Rank               float32      `json:"rank"`
+	TitleSnippet       string       `json:"titleSnippet"`
+	DescriptionSnippet string       `json:"descriptionSnippet,omitempty"`
 }
@@ -0,0 +1,41 @@
+package core
+
+type BatchOp string
+
+const (
+	BatchCreate   BatchOp = "create"
+	BatchUpdate   BatchOp = "update"
+	BatchPatch    BatchOp = "patch"
+	BatchDelete   BatchOp = "delete"
+	BatchComplete BatchOp = "complete"
+)
+
+// TodoOperationRequest is a single operation of a batch. A non-zero Version
+// is checked by all but create.
+type TodoOperationRequest struct {
+	Op      BatchOp          `json:"op"`
+	ID      any              `json:"id"`
+	Version uint             `json:"version"`
+	Todo    TodoRequest      `json:"todo"`
+	Patch   TodoPatchRequest `json:"patch"`
+}
+
+type TodoBatchRequest struct {
+	Operations      []TodoOperationRequest `json:"operations"`
+	ContinueOnError bool                   `json:"continueOnError"`
+}
+
+type TodoOperationResult struct {
+	ID  any
+	Err error
+}
+
+type TodoOperationResponse struct {
+	Status int    `json:"status"`
+	ID     any    `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type TodoBatchResponse struct {
+	Results []TodoOperationResponse `json:"results"`
+}
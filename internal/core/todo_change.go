@@ -0,0 +1,22 @@
+package core
+
+type TodoChange string
+
+const (
+	TodoChangeCreated TodoChange = "created"
+	TodoChangeUpdated TodoChange = "updated"
+	TodoChangeDeleted TodoChange = "deleted"
+	// TodoChangeReset tells that any number of todos may have changed, e.g.
+	// after an import or when changes were missed, so they must be reloaded.
+	TodoChangeReset TodoChange = "reset"
+)
+
+type TodoChangeEvent struct {
+	ID     string
+	Change TodoChange
+	TodoID any
+}
+
+type TodoChangeResponse struct {
+	ID any `json:"id,omitempty"`
+}
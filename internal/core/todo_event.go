@@ -0,0 +1,84 @@
+package core
+
+import (
+	"time"
+)
+
+type TodoAction string
+
+const (
+	TodoCreated  TodoAction = "created"
+	TodoUpdated  TodoAction = "updated"
+	TodoDeleted  TodoAction = "deleted"
+	TodoRestored TodoAction = "restored"
+)
+
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+type TodoEvent struct {
+	ID        uint
+	TodoID    uint
+	UserID    uint
+	Username  string
+	Action    TodoAction
+	Changes   map[string]FieldChange
+	CreatedAt time.Time
+}
+
+type TodoEventResponse struct {
+	ID        any                    `json:"id"`
+	UserID    any                    `json:"userId"`
+	Username  string                 `json:"username"`
+	Action    TodoAction             `json:"action"`
+	Changes   map[string]FieldChange `json:"changes"`
+	CreatedAt time.Time              `json:"createdAt"`
+}
+
+type TodoHistoryResponse struct {
+	Events     []TodoEventResponse `json:"events"`
+	NextCursor any                 `json:"nextCursor,omitempty"`
+}
+
+// DiffTodos lists the fields that differ, leaving out projects and tags,
+// which are per collaborator.
+func DiffTodos(before, after Todo) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+
+	if before.Title != after.Title {
+		changes["title"] = FieldChange{Before: before.Title, After: after.Title}
+	}
+	if before.Description != after.Description {
+		changes["description"] = FieldChange{Before: before.Description, After: after.Description}
+	}
+	if before.Completed != after.Completed {
+		changes["completed"] = FieldChange{Before: before.Completed, After: after.Completed}
+	}
+	if before.Priority != after.Priority {
+		changes["priority"] = FieldChange{Before: before.Priority.String(), After: after.Priority.String()}
+	}
+	if !equalTimes(before.DueAt, after.DueAt) {
+		changes["dueAt"] = FieldChange{Before: before.DueAt, After: after.DueAt}
+	}
+	if before.AutoComplete != after.AutoComplete {
+		changes["autoComplete"] = FieldChange{Before: before.AutoComplete, After: after.AutoComplete}
+	}
+	if before.Recurrence != after.Recurrence {
+		changes["recurrence"] = FieldChange{Before: before.Recurrence, After: after.Recurrence}
+	}
+	if !equalTimes(before.DeletedAt, after.DeletedAt) {
+		changes["deletedAt"] = FieldChange{Before: before.DeletedAt, After: after.DeletedAt}
+	}
+
+	return changes
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
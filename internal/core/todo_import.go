@@ -0,0 +1,14 @@
+package core
+
+type ImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type ImportResponse struct {
+	Created int           `json:"created"`
+	Skipped int           `json:"skipped"`
+	Failed  int           `json:"failed"`
+	DryRun  bool          `json:"dryRun"`
+	Errors  []ImportError `json:"errors,omitempty"`
+}
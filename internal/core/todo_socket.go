@@ -0,0 +1,34 @@
+package core
+
+type SocketMessageType string
+
+const (
+	SocketSubscribe   SocketMessageType = "subscribe"
+	SocketUnsubscribe SocketMessageType = "unsubscribe"
+	SocketResult      SocketMessageType = "result"
+	SocketEvent       SocketMessageType = "event"
+)
+
+// SocketRequest is a message sent over the todo WebSocket, either a
+// subscription or an operation of a batch.
+type SocketRequest struct {
+	ID          string            `json:"id"`
+	Type        SocketMessageType `json:"type"`
+	LastEventID string            `json:"lastEventId"`
+	TodoID      any               `json:"todoId"`
+	Version     uint              `json:"version"`
+	Todo        TodoRequest       `json:"todo"`
+	Patch       TodoPatchRequest  `json:"patch"`
+}
+
+// SocketResponse is either the result of a request, with the status its
+// endpoint would have responded with, or a change to the todos.
+type SocketResponse struct {
+	Type    SocketMessageType `json:"type"`
+	ID      string            `json:"id,omitempty"`
+	Status  int               `json:"status,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	EventID string            `json:"eventId,omitempty"`
+	Change  TodoChange        `json:"change,omitempty"`
+	TodoID  any               `json:"todoId,omitempty"`
+}
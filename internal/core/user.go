@@ -22,3 +22,12 @@ type UserRequest struct {
 	Username  string `json:"username"`
 	Password  string `json:"password"`
 }
+
+type Tokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
@@ -2,6 +2,8 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"time"
 
@@ -62,13 +64,13 @@ func (h *AuthGin) signIn(c *gin.Context) {
 		return
 	}
 
-	token, err := h.userService.SignIn(ctx, userReq)
+	tokens, err := h.userService.SignIn(ctx, userReq)
 	switch err {
 	case nil:
 		h.logger.LogFields(logging.InfoLevel, logging.Fields{
 			"username": userReq.Username,
 		}, "user has signed in")
-		c.JSON(http.StatusOK, map[string]string{"token": token})
+		c.JSON(http.StatusOK, tokens)
 		return
 
 	case service.ErrUserNotFound:
@@ -86,3 +88,139 @@ func (h *AuthGin) signIn(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
 	}
 }
+
+func (h *AuthGin) refresh(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	var refreshReq core.RefreshRequest
+	if err := c.BindJSON(&refreshReq); err != nil {
+		message := "could not bind json"
+		h.logger.Logf(logging.WarnLevel, "could not refresh tokens: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	if len(refreshReq.RefreshToken) == 0 {
+		message := "empty refresh token"
+		h.logger.Logf(logging.WarnLevel, "could not refresh tokens: %s", message)
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	tokens, err := h.userService.Refresh(ctx, refreshReq.RefreshToken)
+	switch err {
+	case nil:
+		h.logger.Log(logging.InfoLevel, "refreshed tokens")
+		c.JSON(http.StatusOK, tokens)
+		return
+
+	case service.ErrInvalidRefreshToken:
+		h.logger.Logf(logging.WarnLevel, "could not refresh tokens: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		return
+
+	case service.ErrRefreshTokenReused:
+		h.logger.Logf(logging.WarnLevel, "could not refresh tokens: %s, revoked its family", err.Error())
+		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		return
+
+	default:
+		message := "could not refresh tokens"
+		h.logger.Logf(logging.ErrorLevel, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+// signOut revokes the access token of the request. The refresh token may be
+// sent in the body to revoke it as well.
+func (h *AuthGin) signOut(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var refreshReq core.RefreshRequest
+	if err := c.ShouldBindJSON(&refreshReq); err != nil && !errors.Is(err, io.EOF) {
+		message := "could not bind json"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "user could not sign out: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	if err := h.userService.SignOut(ctx, c.GetString(accessTokenKey), refreshReq.RefreshToken); err != nil {
+		message := "could not sign out"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+	}, "user has signed out")
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AuthGin) signOutAll(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	err := h.userService.SignOutAll(ctx, c.GetString(accessTokenKey))
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+		}, "user has signed out everywhere")
+		c.Status(http.StatusNoContent)
+
+	case service.ErrUserNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "user could not sign out everywhere: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+
+	default:
+		message := "could not sign out everywhere"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+// jwks publishes the public keys of access tokens, so that other services can
+// verify them without sharing a secret.
+func (h *AuthGin) jwks(c *gin.Context) {
+	keySet, err := h.userService.GetKeySet()
+	switch err {
+	case nil:
+		c.Header("Cache-Control", jwksCacheControl)
+		c.JSON(http.StatusOK, keySet)
+
+	case service.ErrKeySetNotPublished:
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+
+	default:
+		message := "could not get key set"
+		h.logger.Logf(logging.ErrorLevel, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
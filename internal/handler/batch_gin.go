@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/internal/service"
+	"github.com/grimerssy/todo-service/pkg/logging"
+)
+
+func (h *TodoGin) batch(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var batchReq core.TodoBatchRequest
+	if err := c.BindJSON(&batchReq); err != nil {
+		message := "could not bind json"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not run todo batch: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	if n := len(batchReq.Operations); n == 0 || n > maxBatchOperations {
+		message := fmt.Sprintf("batch must have between 1 and %d operations", maxBatchOperations)
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not run todo batch: %s", message)
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	results, err := h.todoService.Batch(ctx, userID, batchReq)
+	if err != nil {
+		message := "could not run todo batch"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	batchRes := core.TodoBatchResponse{
+		Results: make([]core.TodoOperationResponse, len(results)),
+	}
+	for i, result := range results {
+		batchRes.Results[i] = operationResponse(batchReq.Operations[i].Op, result)
+		if batchRes.Results[i].Status == http.StatusInternalServerError {
+			h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+				"user_id": userID,
+				"todo_id": result.ID,
+			}, "could not run todo batch operation %d: %s", i, result.Err.Error())
+		}
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+	}, "ran todo batch")
+	c.JSON(http.StatusOK, batchRes)
+}
+
+func operationResponse(op core.BatchOp, result core.TodoOperationResult) core.TodoOperationResponse {
+	res := core.TodoOperationResponse{
+		ID: result.ID,
+	}
+
+	switch result.Err {
+	case nil:
+		res.Status = http.StatusNoContent
+		if op == core.BatchCreate {
+			res.Status = http.StatusCreated
+		}
+		return res
+	case service.ErrTodoNotFound, service.ErrTodoOrProjectNotFound, service.ErrProjectNotFound:
+		res.Status = http.StatusNotFound
+	case service.ErrTodoModified:
+		res.Status = http.StatusPreconditionFailed
+	case service.ErrInvalidOperation:
+		res.Status = http.StatusBadRequest
+	case service.ErrBatchAborted:
+		res.Status = http.StatusFailedDependency
+	default:
+		res.Status = http.StatusInternalServerError
+		res.Error = "could not run operation"
+		return res
+	}
+
+	res.Error = result.Err.Error()
+
+	return res
+}
@@ -0,0 +1,609 @@
+package handler
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/internal/service"
+	"github.com/grimerssy/todo-service/pkg/caldav"
+	"github.com/grimerssy/todo-service/pkg/ical"
+	"github.com/grimerssy/todo-service/pkg/logging"
+)
+
+// The principal of a user doubles as their calendar home, which holds a single
+// calendar of every todo the user has.
+const (
+	davPrincipalPath = "/dav/"
+	davCalendarPath  = "/dav/todos/"
+)
+
+const calendarObjectContentType = "text/calendar; charset=utf-8; component=vtodo"
+
+func (h *TodoGin) davOptions(c *gin.Context) {
+	c.Header("DAV", "1, 3, calendar-access")
+	c.Header("Allow", "OPTIONS, GET, PUT, DELETE, PROPFIND, REPORT")
+	c.Status(http.StatusOK)
+}
+
+// davRedirect points clients looking for the CalDAV service at the principal,
+// as described in RFC 6764.
+func (h *TodoGin) davRedirect(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, davPrincipalPath)
+}
+
+func (h *TodoGin) propfindPrincipal(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	davReq, err := parseDAVRequest(c, caldav.Propfind)
+	if err != nil {
+		message := "invalid propfind request"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not find properties: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	responses := []caldav.Response{davResponse(davReq, davPrincipalPath, principalProps())}
+
+	if c.GetHeader("Depth") != "0" {
+		ctag, err := h.calendarCTag(ctx, userID, nil)
+		if err != nil {
+			message := "could not find properties"
+			h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+				"user_id": userID,
+			}, "%s: %s", message, err.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+			return
+		}
+		responses = append(responses, davResponse(davReq, davCalendarPath, calendarProps(ctag)))
+	}
+
+	h.writeMultistatus(c, userID, responses)
+}
+
+func (h *TodoGin) propfindCalendar(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	davReq, err := parseDAVRequest(c, caldav.Propfind)
+	if err != nil {
+		message := "invalid propfind request"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not find properties: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	var objects []caldav.Response
+	ctag, err := h.calendarCTag(ctx, userID, func(obj core.CalendarObjectResponse) {
+		if c.GetHeader("Depth") != "0" {
+			objects = append(objects, objectResponse(davReq, obj, false))
+		}
+	})
+	if err != nil {
+		message := "could not find properties"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	responses := append([]caldav.Response{davResponse(davReq, davCalendarPath, calendarProps(ctag))}, objects...)
+
+	h.writeMultistatus(c, userID, responses)
+}
+
+// reportCalendar answers calendar-query and calendar-multiget reports. Queries
+// are only filtered by component, so they match every todo.
+func (h *TodoGin) reportCalendar(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	davReq, err := parseDAVRequest(c, caldav.CalendarQuery, caldav.CalendarMultiget)
+	if err != nil {
+		message := "invalid report request"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not report: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	var responses []caldav.Response
+
+	switch davReq.Kind {
+	case caldav.CalendarQuery:
+		if len(davReq.Component) != 0 && davReq.Component != "VTODO" {
+			break
+		}
+		err = h.todoService.GetCalendarObjects(ctx, userID, func(obj core.CalendarObjectResponse) error {
+			responses = append(responses, objectResponse(davReq, obj, true))
+			return nil
+		})
+	case caldav.CalendarMultiget:
+		for _, href := range davReq.Hrefs {
+			var res caldav.Response
+			if res, err = h.multigetResponse(ctx, userID, davReq, href); err != nil {
+				break
+			}
+			responses = append(responses, res)
+		}
+	}
+
+	if err != nil {
+		message := "could not report"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	h.writeMultistatus(c, userID, responses)
+}
+
+func (h *TodoGin) multigetResponse(ctx context.Context, userID any, davReq caldav.Request,
+	href string) (caldav.Response, error) {
+
+	notFound := caldav.Response{Href: href, Status: http.StatusNotFound}
+
+	u, err := url.Parse(href)
+	if err != nil || !strings.HasPrefix(u.Path, davCalendarPath) {
+		return notFound, nil
+	}
+
+	name := strings.TrimPrefix(u.Path, davCalendarPath)
+	if len(name) == 0 || strings.Contains(name, "/") {
+		return notFound, nil
+	}
+
+	switch obj, err := h.todoService.GetCalendarObject(ctx, userID, name); err {
+	case nil:
+		res := objectResponse(davReq, obj, true)
+		res.Href = href
+		return res, nil
+	case service.ErrTodoNotFound:
+		return notFound, nil
+	default:
+		return caldav.Response{}, err
+	}
+}
+
+func (h *TodoGin) getCalendarObject(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	name := c.Param(calendarObjectKey)
+
+	obj, err := h.todoService.GetCalendarObject(ctx, userID, name)
+
+	switch err {
+	case nil:
+		etag := todoETag(obj.Version)
+		c.Header(etagHeader, etag)
+		if ifNoneMatch(c, etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "got calendar object")
+		c.Data(http.StatusOK, ical.ContentType, obj.Data)
+		return
+	case service.ErrTodoNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "could not get calendar object: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not get calendar object"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+// putCalendarObject responds without an entity tag, since the data stored
+// differs from the data sent.
+func (h *TodoGin) putCalendarObject(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	name := c.Param(calendarObjectKey)
+
+	version, err := parseIfMatch(c)
+	if err != nil {
+		message := "invalid If-Match header"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "could not put calendar object: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	// Clients send If-None-Match: * to create a resource without replacing
+	// one that exists.
+	createOnly := strings.TrimSpace(c.GetHeader(ifNoneMatchHeader)) == "*"
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxCalendarObjectSize))
+	if err != nil {
+		message := "could not read calendar object"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "could not put calendar object: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, map[string]string{"error": message})
+		return
+	}
+
+	created, err := h.todoService.PutCalendarObject(ctx, userID, name, data, version, createOnly)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+			"created": created,
+		}, "put calendar object")
+		if created {
+			c.Status(http.StatusCreated)
+			return
+		}
+		c.Status(http.StatusNoContent)
+		return
+	case service.ErrInvalidCalendarObject:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "could not put calendar object: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	case service.ErrTodoNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "could not put calendar object: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case service.ErrTodoModified:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "could not put calendar object: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, map[string]string{"error": err.Error()})
+		return
+	case service.ErrCalendarObjectConflict:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "could not put calendar object: %s", err.Error())
+		status := http.StatusConflict
+		if createOnly {
+			status = http.StatusPreconditionFailed
+		}
+		c.AbortWithStatusJSON(status, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not put calendar object"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+func (h *TodoGin) deleteCalendarObject(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	name := c.Param(calendarObjectKey)
+
+	version, err := parseIfMatch(c)
+	if err != nil {
+		message := "invalid If-Match header"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "could not delete calendar object: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	err = h.todoService.DeleteCalendarObject(ctx, userID, name, version)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "deleted calendar object")
+		c.Status(http.StatusNoContent)
+		return
+	case service.ErrTodoNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "could not delete calendar object: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case service.ErrTodoModified:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "could not delete calendar object: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not delete calendar object"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"name":    name,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+// calendarCTag computes the collection tag of the calendar, which changes
+// whenever any of its resources does, and passes each resource to fn.
+func (h *TodoGin) calendarCTag(ctx context.Context, userID any,
+	fn func(obj core.CalendarObjectResponse)) (string, error) {
+
+	hash := fnv.New64a()
+	err := h.todoService.GetCalendarObjects(ctx, userID, func(obj core.CalendarObjectResponse) error {
+		hash.Write([]byte(obj.Name))
+		hash.Write(obj.Data)
+		if fn != nil {
+			fn(obj)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (h *TodoGin) writeMultistatus(c *gin.Context, userID any, responses []caldav.Response) {
+	c.Header("Content-Type", caldav.ContentType)
+	c.Status(http.StatusMultiStatus)
+
+	if err := caldav.WriteMultistatus(c.Writer, responses); err != nil {
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not write multistatus: %s", err.Error())
+		c.Abort()
+		return
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+		"method":  c.Request.Method,
+		"path":    c.Request.URL.Path,
+	}, "answered dav request")
+}
+
+func parseDAVRequest(c *gin.Context, kinds ...caldav.RequestKind) (caldav.Request, error) {
+	davReq, err := caldav.ParseRequest(c.Request.Body)
+	if err != nil {
+		return caldav.Request{}, err
+	}
+
+	for _, kind := range kinds {
+		if davReq.Kind == kind {
+			return davReq, nil
+		}
+	}
+
+	return caldav.Request{}, errors.New("unexpected request")
+}
+
+func davResponse(davReq caldav.Request, href string, props []caldav.Element) caldav.Response {
+	found, notFound := davReq.Select(props)
+	return caldav.Response{Href: href, Props: found, NotFound: notFound}
+}
+
+func objectResponse(davReq caldav.Request, obj core.CalendarObjectResponse, withData bool) caldav.Response {
+	props := []caldav.Element{
+		{Name: caldav.DAVName("resourcetype")},
+		{Name: caldav.DAVName("getetag"), Text: todoETag(obj.Version)},
+		{Name: caldav.DAVName("getcontenttype"), Text: calendarObjectContentType},
+	}
+	if withData {
+		props = append(props, caldav.Element{Name: caldav.CalDAVName("calendar-data"), Text: string(obj.Data)})
+	}
+
+	return davResponse(davReq, davCalendarPath+url.PathEscape(obj.Name), props)
+}
+
+func principalProps() []caldav.Element {
+	return []caldav.Element{
+		{
+			Name:     caldav.DAVName("resourcetype"),
+			Children: []caldav.Element{{Name: caldav.DAVName("collection")}, {Name: caldav.DAVName("principal")}},
+		},
+		{Name: caldav.DAVName("current-user-principal"), Children: []caldav.Element{caldav.Href(davPrincipalPath)}},
+		{Name: caldav.DAVName("principal-URL"), Children: []caldav.Element{caldav.Href(davPrincipalPath)}},
+		{Name: caldav.CalDAVName("calendar-home-set"), Children: []caldav.Element{caldav.Href(davPrincipalPath)}},
+	}
+}
+
+func calendarProps(ctag string) []caldav.Element {
+	privilege := func(name string) caldav.Element {
+		return caldav.Element{
+			Name:     caldav.DAVName("privilege"),
+			Children: []caldav.Element{{Name: caldav.DAVName(name)}},
+		}
+	}
+	report := func(name string) caldav.Element {
+		return caldav.Element{
+			Name: caldav.DAVName("supported-report"),
+			Children: []caldav.Element{{
+				Name:     caldav.DAVName("report"),
+				Children: []caldav.Element{{Name: caldav.CalDAVName(name)}},
+			}},
+		}
+	}
+
+	return []caldav.Element{
+		{
+			Name:     caldav.DAVName("resourcetype"),
+			Children: []caldav.Element{{Name: caldav.DAVName("collection")}, {Name: caldav.CalDAVName("calendar")}},
+		},
+		{Name: caldav.DAVName("displayname"), Text: calendarName},
+		{Name: caldav.DAVName("current-user-principal"), Children: []caldav.Element{caldav.Href(davPrincipalPath)}},
+		{
+			Name:     caldav.DAVName("current-user-privilege-set"),
+			Children: []caldav.Element{privilege("read"), privilege("write")},
+		},
+		{
+			Name:     caldav.DAVName("supported-report-set"),
+			Children: []caldav.Element{report("calendar-query"), report("calendar-multiget")},
+		},
+		{
+			Name: caldav.CalDAVName("supported-calendar-component-set"),
+			Children: []caldav.Element{{
+				Name:  caldav.CalDAVName("comp"),
+				Attrs: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: "VTODO"}},
+			}},
+		},
+		{Name: xml.Name{Space: caldav.NamespaceCalendarServer, Local: "getctag"}, Text: ctag},
+	}
+}
+
+// createDavPassword replaces the app password CalDAV clients authenticate with,
+// together with the username of the account.
+func (h *AuthGin) createDavPassword(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	password, err := h.userService.CreateDavPassword(ctx, userID)
+	if err != nil {
+		message := "could not create dav password"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+	}, "created dav password")
+	c.JSON(http.StatusCreated, map[string]string{
+		"password": password,
+		"path":     davPrincipalPath,
+	})
+}
+
+func (h *AuthGin) revokeDavPassword(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	err := h.userService.RevokeDavPassword(ctx, userID)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+		}, "revoked dav password")
+		c.Status(http.StatusNoContent)
+		return
+	case service.ErrDavPasswordNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not revoke dav password: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not revoke dav password"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
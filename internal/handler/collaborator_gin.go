@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/internal/service"
+	"github.com/grimerssy/todo-service/pkg/logging"
+)
+
+func (h *TodoGin) share(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	todoID := c.Param(todoIDKey)
+
+	var shareReq core.ShareRequest
+	if err := c.BindJSON(&shareReq); err != nil {
+		message := "could not bind json"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not share todo: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	err := h.todoService.Share(ctx, userID, todoID, shareReq)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "shared todo")
+		c.Status(http.StatusNoContent)
+		return
+	case service.ErrTodoOrUserNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not share todo: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not share todo"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+func (h *TodoGin) getCollaborators(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	todoID := c.Param(todoIDKey)
+
+	collaboratorsRes, err := h.todoService.GetCollaborators(ctx, userID, todoID)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "got collaborators")
+		c.JSON(http.StatusOK, collaboratorsRes)
+		return
+	case service.ErrTodoNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not get collaborators: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not get collaborators"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+func (h *TodoGin) revokeAccess(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	todoID := c.Param(todoIDKey)
+	collaboratorID := c.Param(collaboratorIDKey)
+
+	err := h.todoService.RevokeAccess(ctx, userID, todoID, collaboratorID)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id":         userID,
+			"todo_id":         todoID,
+			"collaborator_id": collaboratorID,
+		}, "revoked access to todo")
+		c.Status(http.StatusNoContent)
+		return
+	case service.ErrCollaboratorNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id":         userID,
+			"todo_id":         todoID,
+			"collaborator_id": collaboratorID,
+		}, "could not revoke access to todo: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not revoke access to todo"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id":         userID,
+			"todo_id":         todoID,
+			"collaborator_id": collaboratorID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/pkg/logging"
+)
+
+// streamEvents streams todo changes as Server-Sent Events, resuming after
+// the Last-Event-ID header. The stream ends once the access token expires or
+// is revoked.
+func (h *TodoGin) streamEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	token := c.GetString(accessTokenKey)
+	expiresAt, ok := h.tokenExpiry(c, userID, token)
+	if !ok {
+		return
+	}
+
+	changes, err := h.todoService.SubscribeChanges(ctx, userID, c.GetHeader(lastEventIDHeader))
+	if err != nil {
+		message := "could not subscribe to changes"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+	}, "subscribed to changes")
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	tokenCheck := time.NewTicker(tokenCheckInterval)
+	defer tokenCheck.Stop()
+	expiry := time.NewTimer(time.Until(expiresAt))
+	defer expiry.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				return false
+			}
+			c.Render(-1, sse.Event{
+				Id:    change.ID,
+				Event: string(change.Change),
+				Data:  core.TodoChangeResponse{ID: change.TodoID},
+			})
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return false
+			}
+		case <-expiry.C:
+			return false
+		case <-tokenCheck.C:
+			return h.tokenValid(ctx, userID, token)
+		}
+		return true
+	})
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+	}, "unsubscribed from changes")
+}
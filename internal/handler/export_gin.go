@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/pkg/export"
+	"github.com/grimerssy/todo-service/pkg/logging"
+)
+
+// export streams the todos to the client as they are read. Once the first
+// bytes are sent, an error can only cut the response short.
+func (h *TodoGin) export(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	format, err := export.ParseFormat(c.Query(formatQuery))
+	if err != nil {
+		message := "invalid format parameter"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not export todos: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	w, err := export.NewWriter(c.Writer, format)
+	if err != nil {
+		message := "could not export todos"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="todos.%s"`, format.Extension()))
+	c.Status(http.StatusOK)
+
+	err = h.todoService.Export(ctx, userID, func(todo core.TodoResponse) error {
+		return w.Write(exportTodo(todo))
+	})
+	if err == nil {
+		err = w.Close()
+	}
+
+	if err != nil {
+		message := "could not export todos"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		if c.Writer.Written() {
+			c.Abort()
+			return
+		}
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+		"format":  format,
+	}, "exported todos")
+}
+
+func exportTodo(todo core.TodoResponse) export.Todo {
+	var projectID string
+	if todo.ProjectID != nil {
+		projectID = fmt.Sprint(todo.ProjectID)
+	}
+
+	return export.Todo{
+		ID:          fmt.Sprint(todo.ID),
+		Title:       todo.Title,
+		Description: todo.Description,
+		Completed:   todo.Completed,
+		Priority:    todo.Priority,
+		DueAt:       todo.DueAt,
+		Recurrence:  todo.Recurrence,
+		ProjectID:   projectID,
+		Tags:        todo.Tags,
+	}
+}
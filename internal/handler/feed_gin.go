@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grimerssy/todo-service/internal/service"
+	"github.com/grimerssy/todo-service/pkg/ical"
+	"github.com/grimerssy/todo-service/pkg/logging"
+)
+
+const calendarName = "Todos"
+
+// createFeedToken replaces the feed token of the user. The token is part of the
+// feed URL, since calendar clients cannot send an Authorization header.
+func (h *AuthGin) createFeedToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	feedToken, err := h.userService.CreateFeedToken(ctx, userID)
+	if err != nil {
+		message := "could not create feed token"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+	}, "created feed token")
+	c.JSON(http.StatusCreated, map[string]string{
+		"token": feedToken,
+		"path":  "/feed/" + feedToken + "/todos.ics",
+	})
+}
+
+func (h *AuthGin) revokeFeedToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	err := h.userService.RevokeFeedToken(ctx, userID)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+		}, "revoked feed token")
+		c.Status(http.StatusNoContent)
+		return
+	case service.ErrFeedTokenNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not revoke feed token: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not revoke feed token"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+// getCalendar streams the todos with due dates as an iCalendar feed. Once the
+// first bytes are sent, an error can only cut the response short.
+func (h *TodoGin) getCalendar(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w := ical.NewWriter(c.Writer, calendarName)
+
+	c.Header("Content-Type", ical.ContentType)
+	c.Header("Cache-Control", "private, no-cache")
+	c.Status(http.StatusOK)
+
+	err := h.todoService.GetCalendar(ctx, userID, w.Write)
+	if err == nil {
+		err = w.Close()
+	}
+
+	if err != nil {
+		message := "could not get calendar"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		if c.Writer.Written() {
+			c.Abort()
+			return
+		}
+		c.Writer.Header().Del("Content-Type")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+	}, "got calendar")
+}
@@ -7,9 +7,83 @@ import (
 )
 
 const (
-	authorizationHeader = "Authorization"
-	userIDKey           = "user_id"
-	todoIDKey           = "todo_id"
+	authorizationHeader   = "Authorization"
+	wwwAuthenticateHeader = "WWW-Authenticate"
+	etagHeader            = "ETag"
+	ifMatchHeader         = "If-Match"
+	ifNoneMatchHeader     = "If-None-Match"
+	lastEventIDHeader     = "Last-Event-ID"
+	userIDKey             = "user_id"
+	accessTokenKey        = "access_token"
+	todoIDKey             = "todo_id"
+	itemIDKey             = "item_id"
+	tagIDKey              = "tag_id"
+	projectIDKey          = "project_id"
+	collaboratorIDKey     = "collaborator_id"
+	feedTokenKey          = "feed_token"
+	calendarObjectKey     = "calendar_object"
+	sortQuery             = "sort"
+	tagQuery              = "tag"
+	tagMatchQuery         = "match"
+	daysQuery             = "days"
+	countQuery            = "count"
+	timezoneQuery         = "tz"
+	projectTodosQuery     = "todos"
+	cursorQuery           = "cursor"
+	limitQuery            = "limit"
+	searchQuery           = "q"
+	formatQuery           = "format"
+	dryRunQuery           = "dry_run"
+	importFileField       = "file"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+const (
+	maxBatchOperations    = 100
+	maxImportSize         = 10 << 20
+	maxCalendarObjectSize = 1 << 20
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// heartbeatInterval is how often idle event streams and sockets are written
+// to, so that proxies do not close them.
+const heartbeatInterval = 15 * time.Second
+
+// jwksCacheControl lets clients cache the key set for a while, but short
+// enough that a new signing key is picked up before it is used.
+const jwksCacheControl = "public, max-age=300"
+
+const (
+	socketWriteTimeout   = 10 * time.Second
+	socketSendBuffer     = 16
+	maxSocketMessageSize = 1 << 16
+	// tokenCheckInterval is how often sockets and event streams check that
+	// their access token has not been revoked.
+	tokenCheckInterval = time.Minute
+)
+
+const (
+	basicChallenge  = `Basic realm="todo-service", charset="UTF-8"`
+	bearerChallenge = `Bearer realm="todo-service"`
+)
+
+// error codes of bearer challenges, as defined by RFC 6750
+const (
+	invalidRequestError = "invalid_request"
+	invalidTokenError   = "invalid_token"
+)
+
+const (
+	moveTodosToInbox   = "inbox"
+	deleteProjectTodos = "delete"
 )
 
 type ConfigGin struct {
@@ -20,6 +94,8 @@ type HandlersGin struct {
 	Auth       *AuthGin
 	Middleware *MiddlewareGin
 	Todo       *TodoGin
+	Tag        *TagGin
+	Project    *ProjectGin
 }
 
 func (h *HandlersGin) InitRoutes() *gin.Engine {
@@ -29,20 +105,91 @@ func (h *HandlersGin) InitRoutes() *gin.Engine {
 	{
 		auth.POST("/sign-up", h.Auth.signUp)
 		auth.POST("/sign-in", h.Auth.signIn)
+		auth.POST("/refresh", h.Auth.refresh)
+		auth.POST("/sign-out", h.Middleware.authorize, h.Auth.signOut)
+		auth.POST("/sign-out-all", h.Middleware.authorize, h.Auth.signOutAll)
+	}
+
+	feed := router.Group("/feed/:"+feedTokenKey, h.Middleware.authorizeFeed)
+	{
+		feed.GET("/todos.ics", h.Todo.getCalendar)
+	}
+
+	router.GET("/.well-known/jwks.json", h.Auth.jwks)
+	router.GET("/.well-known/caldav", h.Todo.davRedirect)
+	router.Handle("PROPFIND", "/.well-known/caldav", h.Todo.davRedirect)
+	router.OPTIONS("/dav/*path", h.Todo.davOptions)
+
+	dav := router.Group("/dav", h.Middleware.authorizeBasic)
+	{
+		dav.Handle("PROPFIND", "/", h.Todo.propfindPrincipal)
+		dav.Handle("PROPFIND", "/todos/", h.Todo.propfindCalendar)
+		dav.Handle("REPORT", "/todos/", h.Todo.reportCalendar)
+		dav.GET("/todos/:"+calendarObjectKey, h.Todo.getCalendarObject)
+		dav.PUT("/todos/:"+calendarObjectKey, h.Todo.putCalendarObject)
+		dav.DELETE("/todos/:"+calendarObjectKey, h.Todo.deleteCalendarObject)
 	}
 
 	api := router.Group("/api", h.Middleware.authorize)
 	{
+		api.POST("/feed-token", h.Auth.createFeedToken)
+		api.DELETE("/feed-token", h.Auth.revokeFeedToken)
+		api.POST("/dav-password", h.Auth.createDavPassword)
+		api.DELETE("/dav-password", h.Auth.revokeDavPassword)
+		api.GET("/ws", h.Todo.socket)
+
 		todos := api.Group("/todos")
 		{
 			todos.POST("/", h.Todo.create)
+			todos.POST("/batch", h.Todo.batch)
+			todos.GET("/events", h.Todo.streamEvents)
 			todos.GET("/:"+todoIDKey, h.Todo.getByID)
 			todos.GET("/pending", h.Todo.getPending)
+			todos.GET("/overdue", h.Todo.getOverdue)
+			todos.GET("/search", h.Todo.search)
+			todos.GET("/export", h.Todo.export)
+			todos.POST("/import", h.Todo.importTodos)
+			todos.GET("/due-today", h.Todo.getDueToday)
+			todos.GET("/due-within", h.Todo.getDueWithin)
 			todos.GET("/", h.Todo.getAll)
+			todos.GET("/:"+todoIDKey+"/occurrences", h.Todo.getOccurrences)
+			todos.GET("/:"+todoIDKey+"/history", h.Todo.getHistory)
 			todos.PUT("/:"+todoIDKey, h.Todo.updateByID)
 			todos.PATCH("/:"+todoIDKey, h.Todo.patchByID)
 			todos.DELETE("/:"+todoIDKey, h.Todo.deleteByID)
 			todos.DELETE("/completed", h.Todo.deleteCompleted)
+			todos.GET("/trash", h.Todo.getTrash)
+			todos.POST("/:"+todoIDKey+"/restore", h.Todo.restore)
+			todos.DELETE("/trash", h.Todo.emptyTrash)
+			todos.POST("/:"+todoIDKey+"/items", h.Todo.createItem)
+			todos.GET("/:"+todoIDKey+"/items/:"+itemIDKey, h.Todo.getItemByID)
+			todos.GET("/:"+todoIDKey+"/items", h.Todo.getItems)
+			todos.PUT("/:"+todoIDKey+"/items/:"+itemIDKey, h.Todo.updateItemByID)
+			todos.DELETE("/:"+todoIDKey+"/items/:"+itemIDKey, h.Todo.deleteItemByID)
+			todos.PUT("/:"+todoIDKey+"/tags/:"+tagIDKey, h.Tag.attachToTodo)
+			todos.DELETE("/:"+todoIDKey+"/tags/:"+tagIDKey, h.Tag.detachFromTodo)
+			todos.POST("/:"+todoIDKey+"/collaborators", h.Todo.share)
+			todos.GET("/:"+todoIDKey+"/collaborators", h.Todo.getCollaborators)
+			todos.DELETE("/:"+todoIDKey+"/collaborators/:"+collaboratorIDKey, h.Todo.revokeAccess)
+		}
+
+		tags := api.Group("/tags")
+		{
+			tags.POST("/", h.Tag.create)
+			tags.GET("/:"+tagIDKey, h.Tag.getByID)
+			tags.GET("/", h.Tag.getAll)
+			tags.PUT("/:"+tagIDKey, h.Tag.updateByID)
+			tags.DELETE("/:"+tagIDKey, h.Tag.deleteByID)
+		}
+
+		projects := api.Group("/projects")
+		{
+			projects.POST("/", h.Project.create)
+			projects.GET("/:"+projectIDKey, h.Project.getByID)
+			projects.GET("/", h.Project.getAll)
+			projects.GET("/:"+projectIDKey+"/todos", h.Todo.getByProject)
+			projects.PUT("/:"+projectIDKey, h.Project.updateByID)
+			projects.DELETE("/:"+projectIDKey, h.Project.deleteByID)
 		}
 	}
 
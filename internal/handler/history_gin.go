@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grimerssy/todo-service/internal/service"
+	"github.com/grimerssy/todo-service/pkg/logging"
+)
+
+func (h *TodoGin) getHistory(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	todoID := c.Param(todoIDKey)
+
+	pageReq, err := parseTodoPage(c)
+	if err != nil {
+		message := "invalid limit parameter"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not get todo history: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	historyRes, err := h.todoService.GetHistory(ctx, userID, todoID, pageReq)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "got todo history")
+		c.JSON(http.StatusOK, historyRes)
+		return
+	case service.ErrTodoNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not get todo history: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case service.ErrInvalidCursor:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not get todo history: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not get todo history"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grimerssy/todo-service/pkg/export"
+	"github.com/grimerssy/todo-service/pkg/logging"
+)
+
+// importTodos creates todos from the file uploaded in importFileField.
+func (h *TodoGin) importTodos(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	format, err := export.ParseFormat(c.Query(formatQuery))
+	if err != nil {
+		message := "invalid format parameter"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not import todos: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	var dryRun bool
+	if value := c.Query(dryRunQuery); len(value) != 0 {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			message := "invalid dry run parameter"
+			h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+				"user_id": userID,
+			}, "could not import todos: %s: %s", message, err.Error())
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	fileHeader, err := c.FormFile(importFileField)
+	if err != nil {
+		message := "could not get file"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not import todos: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		message := "could not open file"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not import todos: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+	defer file.Close()
+
+	r, err := export.NewReader(file, format)
+	if err != nil {
+		message := "invalid format parameter"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not import todos: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	importRes, err := h.todoService.Import(ctx, userID, r, dryRun)
+	if err != nil {
+		message := "could not import todos"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+		"created": importRes.Created,
+		"skipped": importRes.Skipped,
+		"failed":  importRes.Failed,
+		"dry_run": dryRun,
+	}, "imported todos")
+	c.JSON(http.StatusOK, importRes)
+}
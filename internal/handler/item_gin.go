@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/internal/service"
+	"github.com/grimerssy/todo-service/pkg/logging"
+)
+
+func (h *TodoGin) createItem(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	todoID := c.Param(todoIDKey)
+
+	var itemReq core.ItemRequest
+	if err := c.BindJSON(&itemReq); err != nil {
+		message := "could not bind json"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not create item: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	err := h.todoService.CreateItem(ctx, userID, todoID, itemReq)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "created item")
+		c.Status(http.StatusCreated)
+		return
+	case service.ErrTodoNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not create item: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not create item"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+func (h *TodoGin) getItemByID(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	todoID := c.Param(todoIDKey)
+	itemID := c.Param(itemIDKey)
+
+	itemRes, err := h.todoService.GetItemByID(ctx, userID, todoID, itemID)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+			"item_id": itemID,
+		}, "got item by id")
+		c.JSON(http.StatusOK, itemRes)
+		return
+	case service.ErrItemNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+			"item_id": itemID,
+		}, "could not get item by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not get item by id"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+			"item_id": itemID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+func (h *TodoGin) getItems(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	todoID := c.Param(todoIDKey)
+
+	itemsRes, err := h.todoService.GetItems(ctx, userID, todoID)
+	if err != nil {
+		message := "could not get items"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+		"todo_id": todoID,
+	}, "got items")
+	c.JSON(http.StatusOK, itemsRes)
+}
+
+func (h *TodoGin) updateItemByID(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	todoID := c.Param(todoIDKey)
+	itemID := c.Param(itemIDKey)
+
+	var itemReq core.ItemRequest
+	if err := c.BindJSON(&itemReq); err != nil {
+		message := "could not bind json"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+			"item_id": itemID,
+		}, "could not update item by id: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	err := h.todoService.UpdateItemByID(ctx, userID, todoID, itemID, itemReq)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+			"item_id": itemID,
+		}, "updated item by id")
+		c.Status(http.StatusNoContent)
+		return
+	case service.ErrItemNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+			"item_id": itemID,
+		}, "could not update item by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not update item by id"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+			"item_id": itemID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+func (h *TodoGin) deleteItemByID(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	todoID := c.Param(todoIDKey)
+	itemID := c.Param(itemIDKey)
+
+	err := h.todoService.DeleteItemByID(ctx, userID, todoID, itemID)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+			"item_id": itemID,
+		}, "deleted item by id")
+		c.Status(http.StatusNoContent)
+		return
+	case service.ErrItemNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+			"item_id": itemID,
+		}, "could not delete item by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not delete item by id"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+			"item_id": itemID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
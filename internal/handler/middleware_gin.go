@@ -3,12 +3,14 @@ package handler
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/grimerssy/todo-service/internal/service"
+	"github.com/grimerssy/todo-service/pkg/auth"
 	"github.com/grimerssy/todo-service/pkg/logging"
 )
 
@@ -35,6 +37,7 @@ func (h *MiddlewareGin) authorize(c *gin.Context) {
 	if len(header) == 0 {
 		err := errors.New("could not authorize: empty authorization header")
 		h.logger.Log(logging.WarnLevel, err.Error())
+		c.Header(wwwAuthenticateHeader, bearerChallenge)
 		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
 		return
 	}
@@ -44,18 +47,107 @@ func (h *MiddlewareGin) authorize(c *gin.Context) {
 	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
 		err := errors.New("could not authorize: invalid authorization token")
 		h.logger.Log(logging.WarnLevel, err.Error())
+		c.Header(wwwAuthenticateHeader, bearerError(invalidRequestError, "The authorization header is malformed"))
 		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
 		return
 	}
 
 	token := headerParts[1]
 	userID, err := h.userService.GetID(ctx, token)
-	if err != nil {
+	switch {
+	case err == nil:
+		c.Set(userIDKey, userID)
+		c.Set(accessTokenKey, token)
+	case errors.Is(err, service.ErrInvalidToken), err == service.ErrTokenRevoked:
 		message := "could not authorize"
 		h.logger.Logf(logging.WarnLevel, "%s: %s", message, err.Error())
+		c.Header(wwwAuthenticateHeader, bearerError(invalidTokenError, tokenErrorDescription(err)))
 		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": message})
+	default:
+		message := "could not authorize"
+		h.logger.Logf(logging.ErrorLevel, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+func bearerError(code, description string) string {
+	return fmt.Sprintf(`%s, error="%s", error_description="%s"`, bearerChallenge, code, description)
+}
+
+// tokenErrorDescription tells the client why its access token was rejected.
+func tokenErrorDescription(err error) string {
+	switch {
+	case err == service.ErrTokenRevoked:
+		return "The access token has been revoked"
+	case errors.Is(err, auth.ErrTokenExpired):
+		return "The access token expired"
+	case errors.Is(err, auth.ErrTokenNotValidYet):
+		return "The access token is not valid yet"
+	case errors.Is(err, auth.ErrInvalidSignature):
+		return "The access token signature is invalid"
+	case errors.Is(err, auth.ErrInvalidIssuer):
+		return "The access token was issued by an unknown issuer"
+	case errors.Is(err, auth.ErrInvalidAudience):
+		return "The access token is meant for another audience"
+	default:
+		return "The access token is invalid"
+	}
+}
+
+// authorizeFeed identifies the user by the feed token in the path. Unlike
+// access tokens, feed tokens only ever grant access to the feeds.
+func (h *MiddlewareGin) authorizeFeed(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, err := h.userService.GetIDByFeedToken(ctx, c.Param(feedTokenKey))
+
+	switch err {
+	case nil:
+		c.Set(userIDKey, userID)
+	case service.ErrFeedTokenNotFound:
+		h.logger.Logf(logging.WarnLevel, "could not authorize feed: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	default:
+		message := "could not authorize feed"
+		h.logger.Logf(logging.ErrorLevel, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+// authorizeBasic identifies the user by the credentials of HTTP Basic
+// authentication, since CalDAV clients cannot obtain access tokens. The
+// password is the revocable app password, not the one of the account.
+func (h *MiddlewareGin) authorizeBasic(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	username, password, ok := c.Request.BasicAuth()
+	if !ok {
+		err := errors.New("could not authorize: missing basic credentials")
+		h.logger.Log(logging.WarnLevel, err.Error())
+		c.Header(wwwAuthenticateHeader, basicChallenge)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
 		return
 	}
 
-	c.Set(userIDKey, userID)
+	userID, err := h.userService.AuthenticateDav(ctx, username, password)
+
+	switch err {
+	case nil:
+		c.Set(userIDKey, userID)
+	case service.ErrInvalidDavCredentials:
+		message := "could not authorize"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"username": username,
+		}, "%s: %s", message, err.Error())
+		c.Header(wwwAuthenticateHeader, basicChallenge)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": message})
+	default:
+		message := "could not authorize"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"username": username,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
 }
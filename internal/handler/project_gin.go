@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/internal/service"
+	"github.com/grimerssy/todo-service/pkg/logging"
+)
+
+type ProjectGin struct {
+	logger         logging.Logger
+	projectService service.ProjectService
+	requestTimeout time.Duration
+}
+
+func NewProjectGin(cfg ConfigGin, logger logging.Logger, projectService service.ProjectService) *ProjectGin {
+	return &ProjectGin{
+		logger:         logger,
+		projectService: projectService,
+		requestTimeout: cfg.RequestSeconds * time.Second,
+	}
+}
+
+func (h *ProjectGin) create(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var projectReq core.ProjectRequest
+	if err := c.BindJSON(&projectReq); err != nil {
+		message := "could not bind json"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not create project: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	err := h.projectService.Create(ctx, userID, projectReq)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+		}, "created project")
+		c.Status(http.StatusCreated)
+		return
+	case service.ErrProjectAlreadyExists:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not create project: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not create project"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+func (h *ProjectGin) getByID(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	projectID := c.Param(projectIDKey)
+
+	projectRes, err := h.projectService.GetByID(ctx, userID, projectID)
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "got project by id")
+		c.JSON(http.StatusOK, projectRes)
+		return
+	case service.ErrProjectNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "could not get project by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not get project by id"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+func (h *ProjectGin) getAll(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	projectsRes, err := h.projectService.GetAll(ctx, userID)
+	if err != nil {
+		message := "could not get all projects"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+	}, "got all projects")
+	c.JSON(http.StatusOK, projectsRes)
+}
+
+func (h *ProjectGin) updateByID(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	projectID := c.Param(projectIDKey)
+
+	var projectReq core.ProjectRequest
+	if err := c.BindJSON(&projectReq); err != nil {
+		message := "could not bind json"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "could not update project by id: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	err := h.projectService.UpdateByID(ctx, userID, projectID, projectReq)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "updated project by id")
+		c.Status(http.StatusNoContent)
+		return
+	case service.ErrProjectNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "could not update project by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case service.ErrProjectAlreadyExists:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "could not update project by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not update project by id"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+func (h *ProjectGin) deleteByID(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	projectID := c.Param(projectIDKey)
+
+	var deleteTodos bool
+	switch c.DefaultQuery(projectTodosQuery, moveTodosToInbox) {
+	case moveTodosToInbox:
+		deleteTodos = false
+	case deleteProjectTodos:
+		deleteTodos = true
+	default:
+		message := "invalid todos parameter"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "could not delete project by id: %s", message)
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	err := h.projectService.DeleteByID(ctx, userID, projectID, deleteTodos)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "deleted project by id")
+		c.Status(http.StatusNoContent)
+		return
+	case service.ErrProjectNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "could not delete project by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not delete project by id"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
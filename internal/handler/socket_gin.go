@@ -0,0 +1,361 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/internal/service"
+	"github.com/grimerssy/todo-service/pkg/logging"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// todoSocket serves a WebSocket of a user. Requests are read and run one at a
+// time, while responses and changes are written by a single writer.
+type todoSocket struct {
+	h      *TodoGin
+	conn   *websocket.Conn
+	userID any
+	token  string
+	send   chan core.SocketResponse
+
+	subscriptionMu sync.Mutex
+	unsubscribe    context.CancelFunc
+}
+
+// socket is closed once the client stops answering pings, or once the
+// access token expires or is revoked.
+func (h *TodoGin) socket(c *gin.Context) {
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	token := c.GetString(accessTokenKey)
+	expiresAt, ok := h.tokenExpiry(c, userID, token)
+	if !ok {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not upgrade connection: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+	}, "opened socket")
+
+	s := &todoSocket{
+		h:      h,
+		conn:   conn,
+		userID: userID,
+		token:  token,
+		send:   make(chan core.SocketResponse, socketSendBuffer),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		// closing the connection stops a read that is waiting for a message
+		defer conn.Close()
+		s.write(ctx, expiresAt)
+	}()
+
+	s.read(ctx, &wg)
+	cancel()
+	wg.Wait()
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+	}, "closed socket")
+}
+
+// read runs the requests of the client until the connection is closed.
+func (s *todoSocket) read(ctx context.Context, wg *sync.WaitGroup) {
+	s.conn.SetReadLimit(maxSocketMessageSize)
+	s.conn.SetReadDeadline(time.Now().Add(2 * heartbeatInterval))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(2 * heartbeatInterval))
+	})
+
+	for {
+		_, message, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+					"user_id": s.userID,
+				}, "could not read socket message: %s", err.Error())
+			}
+			return
+		}
+
+		var req core.SocketRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			s.reply(ctx, core.SocketResponse{
+				Type:   core.SocketResult,
+				Status: http.StatusBadRequest,
+				Error:  "could not parse message",
+			})
+			continue
+		}
+
+		switch req.Type {
+		case core.SocketSubscribe:
+			s.subscribe(ctx, wg, req)
+		case core.SocketUnsubscribe:
+			s.stopSubscription()
+			s.reply(ctx, core.SocketResponse{Type: core.SocketResult, ID: req.ID, Status: http.StatusOK})
+		default:
+			s.runOperation(ctx, req)
+		}
+	}
+}
+
+// write sends the queued responses and pings the client until the context is
+// done, a write fails or the access token can no longer be used.
+func (s *todoSocket) write(ctx context.Context, expiresAt time.Time) {
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	tokenCheck := time.NewTicker(tokenCheckInterval)
+	defer tokenCheck.Stop()
+	expiry := time.NewTimer(time.Until(expiresAt))
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.close(websocket.CloseNormalClosure, "")
+			return
+		case <-expiry.C:
+			s.close(websocket.ClosePolicyViolation, "token expired")
+			return
+		case <-tokenCheck.C:
+			if !s.h.tokenValid(ctx, s.userID, s.token) {
+				s.close(websocket.ClosePolicyViolation, "token revoked")
+				return
+			}
+		case res := <-s.send:
+			s.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+			if err := s.conn.WriteJSON(res); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil,
+				time.Now().Add(socketWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *todoSocket) close(code int, text string) {
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text),
+		time.Now().Add(socketWriteTimeout))
+}
+
+// tokenExpiry returns when the access token of a connection expires, or
+// aborts the request when it cannot be used.
+func (h *TodoGin) tokenExpiry(c *gin.Context, userID any, token string) (time.Time, bool) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.requestTimeout)
+	defer cancel()
+
+	expiresAt, err := h.userService.GetExpiry(ctx, token)
+	switch {
+	case err == nil:
+		return expiresAt, true
+	case errors.Is(err, service.ErrInvalidToken), err == service.ErrTokenRevoked:
+		message := "could not authorize"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.Header(wwwAuthenticateHeader, bearerError(invalidTokenError, tokenErrorDescription(err)))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": message})
+		return time.Time{}, false
+	default:
+		message := "could not get token expiry"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return time.Time{}, false
+	}
+}
+
+// tokenValid reports whether the access token of a connection has neither
+// expired nor been revoked. A token that cannot be checked is kept.
+func (h *TodoGin) tokenValid(ctx context.Context, userID any, token string) bool {
+	ctx, cancel := context.WithTimeout(ctx, h.requestTimeout)
+	defer cancel()
+
+	_, err := h.userService.GetID(ctx, token)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, service.ErrInvalidToken), err == service.ErrTokenRevoked:
+		h.logger.LogFieldsf(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+		}, "closing connection: %s", err.Error())
+		return false
+	default:
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not check connection token: %s", err.Error())
+		return true
+	}
+}
+
+func (s *todoSocket) reply(ctx context.Context, res core.SocketResponse) bool {
+	select {
+	case s.send <- res:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// subscribe replaces the subscription of the socket, resubscribing from the
+// last change sent if the subscriber falls behind.
+func (s *todoSocket) subscribe(ctx context.Context, wg *sync.WaitGroup, req core.SocketRequest) {
+	s.stopSubscription()
+
+	subCtx, unsubscribe := context.WithCancel(ctx)
+	changes, err := s.h.todoService.SubscribeChanges(subCtx, s.userID, req.LastEventID)
+	if err != nil {
+		unsubscribe()
+		s.h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": s.userID,
+		}, "could not subscribe to changes: %s", err.Error())
+		s.reply(ctx, core.SocketResponse{
+			Type:   core.SocketResult,
+			ID:     req.ID,
+			Status: http.StatusInternalServerError,
+			Error:  "could not subscribe to changes",
+		})
+		return
+	}
+
+	s.subscriptionMu.Lock()
+	s.unsubscribe = unsubscribe
+	s.subscriptionMu.Unlock()
+
+	s.reply(ctx, core.SocketResponse{Type: core.SocketResult, ID: req.ID, Status: http.StatusOK})
+
+	wg.Add(1)
+	go func(changes <-chan core.TodoChangeEvent) {
+		defer wg.Done()
+
+		lastEventID := req.LastEventID
+		for {
+			for change := range changes {
+				lastEventID = change.ID
+				s.reply(subCtx, core.SocketResponse{
+					Type:    core.SocketEvent,
+					EventID: change.ID,
+					Change:  change.Change,
+					TodoID:  change.TodoID,
+				})
+			}
+			if subCtx.Err() != nil {
+				return
+			}
+
+			var err error
+			changes, err = s.h.todoService.SubscribeChanges(subCtx, s.userID, lastEventID)
+			if err != nil {
+				s.h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+					"user_id": s.userID,
+				}, "could not subscribe to changes: %s", err.Error())
+				return
+			}
+		}
+	}(changes)
+}
+
+func (s *todoSocket) stopSubscription() {
+	s.subscriptionMu.Lock()
+	defer s.subscriptionMu.Unlock()
+
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+		s.unsubscribe = nil
+	}
+}
+
+func (s *todoSocket) runOperation(ctx context.Context, req core.SocketRequest) {
+	opCtx, cancel := context.WithTimeout(ctx, s.h.requestTimeout)
+	defer cancel()
+
+	op := core.TodoOperationRequest{
+		Op:      core.BatchOp(req.Type),
+		ID:      req.TodoID,
+		Version: req.Version,
+		Todo:    req.Todo,
+		Patch:   req.Patch,
+	}
+
+	results, err := s.h.todoService.Batch(opCtx, s.userID, core.TodoBatchRequest{
+		Operations: []core.TodoOperationRequest{op},
+	})
+	if err != nil {
+		message := "could not run operation"
+		s.h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": s.userID,
+			"todo_id": req.TodoID,
+		}, "%s: %s", message, err.Error())
+		s.reply(ctx, core.SocketResponse{
+			Type:   core.SocketResult,
+			ID:     req.ID,
+			Status: http.StatusInternalServerError,
+			Error:  message,
+		})
+		return
+	}
+
+	opRes := operationResponse(op.Op, results[0])
+	switch {
+	case opRes.Status == http.StatusInternalServerError:
+		s.h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": s.userID,
+			"todo_id": req.TodoID,
+		}, "could not run socket operation %s: %s", op.Op, results[0].Err.Error())
+	case results[0].Err == service.ErrInvalidOperation:
+		s.h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": s.userID,
+		}, "could not run socket operation %s: %s", op.Op, results[0].Err.Error())
+	default:
+		s.h.logger.LogFieldsf(logging.InfoLevel, logging.Fields{
+			"user_id": s.userID,
+			"todo_id": opRes.ID,
+		}, "ran socket operation %s", op.Op)
+	}
+
+	s.reply(ctx, core.SocketResponse{
+		Type:   core.SocketResult,
+		ID:     req.ID,
+		Status: opRes.Status,
+		Error:  opRes.Error,
+		TodoID: opRes.ID,
+	})
+}
@@ -3,7 +3,10 @@ package handler
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,13 +18,17 @@ import (
 type TodoGin struct {
 	logger         logging.Logger
 	todoService    service.TodoService
+	userService    service.UserService
 	requestTimeout time.Duration
 }
 
-func NewTodoGin(cfg ConfigGin, logger logging.Logger, todoService service.TodoService) *TodoGin {
+func NewTodoGin(cfg ConfigGin, logger logging.Logger, todoService service.TodoService,
+	userService service.UserService) *TodoGin {
+
 	return &TodoGin{
 		logger:         logger,
 		todoService:    todoService,
+		userService:    userService,
 		requestTimeout: cfg.RequestSeconds * time.Second,
 	}
 }
@@ -48,19 +55,28 @@ func (h *TodoGin) create(c *gin.Context) {
 		return
 	}
 
-	if err := h.todoService.Create(ctx, userID, todoReq); err != nil {
+	err := h.todoService.Create(ctx, userID, todoReq)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+		}, "created todo")
+		c.Status(http.StatusCreated)
+		return
+	case service.ErrProjectNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not create todo: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	default:
 		message := "could not create todo"
 		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
 			"user_id": userID,
 		}, "%s: %s", message, err.Error())
 		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
-		return
 	}
-
-	h.logger.LogFields(logging.InfoLevel, logging.Fields{
-		"user_id": userID,
-	}, "created todo")
-	c.Status(http.StatusCreated)
 }
 
 func (h *TodoGin) getByID(c *gin.Context) {
@@ -80,6 +96,16 @@ func (h *TodoGin) getByID(c *gin.Context) {
 	todoRes, err := h.todoService.GetByID(ctx, userID, todoID)
 	switch err {
 	case nil:
+		etag := todoETag(todoRes.Version)
+		c.Header(etagHeader, etag)
+		if ifNoneMatch(c, etag) {
+			h.logger.LogFields(logging.InfoLevel, logging.Fields{
+				"user_id": userID,
+				"todo_id": todoID,
+			}, "todo not modified")
+			c.Status(http.StatusNotModified)
+			return
+		}
 		h.logger.LogFields(logging.InfoLevel, logging.Fields{
 			"user_id": userID,
 			"todo_id": todoID,
@@ -117,23 +143,193 @@ func (h *TodoGin) getPending(c *gin.Context) {
 		return
 	}
 
-	todosRes, err := h.todoService.GetByCompletion(ctx, userID, completed)
+	sort, err := core.ParseTodoSort(c.Query(sortQuery))
+	if err != nil {
+		message := "invalid sort parameter"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not get pending todos: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	pageReq, err := parseTodoPage(c)
 	if err != nil {
+		message := "invalid limit parameter"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not get pending todos: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	pageRes, err := h.todoService.GetByCompletion(ctx, userID, completed, sort, pageReq)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+		}, "got pending todos")
+		c.JSON(http.StatusOK, pageRes)
+		return
+	case service.ErrInvalidCursor:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not get pending todos: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	default:
 		message := "could not get pending todos"
 		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
 			"user_id": userID,
 		}, "%s: %s", message, err.Error())
 		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+func (h *TodoGin) getAll(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	sort, err := core.ParseTodoSort(c.Query(sortQuery))
+	if err != nil {
+		message := "invalid sort parameter"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not get all todos: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	tagMatch, err := core.ParseTagMatch(c.Query(tagMatchQuery))
+	if err != nil {
+		message := "invalid tag match parameter"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not get all todos: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	filter := core.TodoFilter{
+		Tags:     c.QueryArray(tagQuery),
+		TagMatch: tagMatch,
+	}
+
+	pageReq, err := parseTodoPage(c)
+	if err != nil {
+		message := "invalid limit parameter"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not get all todos: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	pageRes, err := h.todoService.GetAll(ctx, userID, filter, sort, pageReq)
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+		}, "got all todos")
+		c.JSON(http.StatusOK, pageRes)
+		return
+	case service.ErrInvalidCursor:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not get all todos: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not get all todos"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
+func (h *TodoGin) getByProject(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	projectID := c.Param(projectIDKey)
+
+	sort, err := core.ParseTodoSort(c.Query(sortQuery))
+	if err != nil {
+		message := "invalid sort parameter"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "could not get project todos: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	todosRes, err := h.todoService.GetByProject(ctx, userID, projectID, sort)
+	if err != nil {
+		message := "could not get project todos"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id":    userID,
+			"project_id": projectID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id":    userID,
+		"project_id": projectID,
+	}, "got project todos")
+	c.JSON(http.StatusOK, todosRes)
+}
+
+func (h *TodoGin) getOverdue(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	todosRes, err := h.todoService.GetOverdue(ctx, userID)
+	if err != nil {
+		message := "could not get overdue todos"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
 		return
 	}
 
 	h.logger.LogFields(logging.InfoLevel, logging.Fields{
 		"user_id": userID,
-	}, "got pending todos")
+	}, "got overdue todos")
 	c.JSON(http.StatusOK, todosRes)
 }
 
-func (h *TodoGin) getAll(c *gin.Context) {
+func (h *TodoGin) search(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
 	defer cancel()
 
@@ -145,9 +341,29 @@ func (h *TodoGin) getAll(c *gin.Context) {
 		return
 	}
 
-	todosRes, err := h.todoService.GetAll(ctx, userID)
+	query := strings.TrimSpace(c.Query(searchQuery))
+	if len(query) == 0 {
+		message := "empty search query"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not search todos: %s", message)
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	limit, err := parseLimit(c)
 	if err != nil {
-		message := "could not get all todos"
+		message := "invalid limit parameter"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not search todos: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	resultsRes, err := h.todoService.Search(ctx, userID, query, limit)
+	if err != nil {
+		message := "could not search todos"
 		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
 			"user_id": userID,
 		}, "%s: %s", message, err.Error())
@@ -157,10 +373,153 @@ func (h *TodoGin) getAll(c *gin.Context) {
 
 	h.logger.LogFields(logging.InfoLevel, logging.Fields{
 		"user_id": userID,
-	}, "got all todos")
+	}, "searched todos")
+	c.JSON(http.StatusOK, resultsRes)
+}
+
+func (h *TodoGin) getDueToday(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	location, err := time.LoadLocation(c.Query(timezoneQuery))
+	if err != nil {
+		message := "invalid time zone"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not get todos due today: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	todosRes, err := h.todoService.GetDueToday(ctx, userID, location)
+	if err != nil {
+		message := "could not get todos due today"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+	}, "got todos due today")
+	c.JSON(http.StatusOK, todosRes)
+}
+
+func (h *TodoGin) getDueWithin(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	days, err := strconv.ParseUint(c.Query(daysQuery), 10, 16)
+	if err != nil {
+		message := "invalid number of days"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+		}, "could not get todos due within days: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	todosRes, err := h.todoService.GetDueWithin(ctx, userID, uint(days))
+	if err != nil {
+		message := "could not get todos due within days"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"days":    days,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+		return
+	}
+
+	h.logger.LogFields(logging.InfoLevel, logging.Fields{
+		"user_id": userID,
+		"days":    days,
+	}, "got todos due within days")
 	c.JSON(http.StatusOK, todosRes)
 }
 
+func (h *TodoGin) getOccurrences(c *gin.Context) {
+	const (
+		defaultCount = 5
+		maxCount     = 100
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		err := errors.New("could not get user id")
+		h.logger.Log(logging.ErrorLevel, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	todoID := c.Param(todoIDKey)
+
+	count, err := strconv.ParseUint(c.DefaultQuery(countQuery, strconv.Itoa(defaultCount)), 10, 16)
+	if err != nil || count == 0 || count > maxCount {
+		message := "invalid number of occurrences"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not get todo occurrences: %s", message)
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	occurrences, err := h.todoService.GetOccurrences(ctx, userID, todoID, uint(count))
+
+	switch err {
+	case nil:
+		h.logger.LogFields(logging.InfoLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+			"count":   count,
+		}, "got todo occurrences")
+		c.JSON(http.StatusOK, occurrences)
+		return
+	case service.ErrTodoNotFound:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not get todo occurrences: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case service.ErrTodoNotRecurring:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not get todo occurrences: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	default:
+		message := "could not get todo occurrences"
+		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"error": message})
+	}
+}
+
 func (h *TodoGin) updateByID(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
 	defer cancel()
@@ -186,7 +545,18 @@ func (h *TodoGin) updateByID(c *gin.Context) {
 		return
 	}
 
-	err := h.todoService.UpdateByID(ctx, userID, todoID, todoReq)
+	version, err := parseIfMatch(c)
+	if err != nil {
+		message := "invalid If-Match header"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not update todo by id: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	err = h.todoService.UpdateByID(ctx, userID, todoID, todoReq, version)
 
 	switch err {
 	case nil:
@@ -196,13 +566,20 @@ func (h *TodoGin) updateByID(c *gin.Context) {
 		}, "updated todo by id")
 		c.Status(http.StatusNoContent)
 		return
-	case service.ErrTodoNotFound:
+	case service.ErrTodoNotFound, service.ErrTodoOrProjectNotFound:
 		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
 			"user_id": userID,
 			"todo_id": todoID,
 		}, "could not update todo by id: %s", err.Error())
 		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
 		return
+	case service.ErrTodoModified:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not update todo by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, map[string]string{"error": err.Error()})
+		return
 	default:
 		message := "could not update todo by id"
 		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
@@ -213,6 +590,8 @@ func (h *TodoGin) updateByID(c *gin.Context) {
 	}
 }
 
+// patchByID takes a JSON Merge Patch, or a JSON Patch when the request says
+// so in its Content-Type.
 func (h *TodoGin) patchByID(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
 	defer cancel()
@@ -227,9 +606,9 @@ func (h *TodoGin) patchByID(c *gin.Context) {
 
 	todoID := c.Param(todoIDKey)
 
-	var todoReq core.TodoRequest
-	if err := c.BindJSON(&todoReq); err != nil {
-		message := "could not bind json"
+	version, err := parseIfMatch(c)
+	if err != nil {
+		message := "invalid If-Match header"
 		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
 			"user_id": userID,
 			"todo_id": todoID,
@@ -238,7 +617,42 @@ func (h *TodoGin) patchByID(c *gin.Context) {
 		return
 	}
 
-	err := h.todoService.PatchByID(ctx, userID, todoID, todoReq)
+	switch contentType := c.ContentType(); contentType {
+	case jsonPatchContentType:
+		patch, err := c.GetRawData()
+		if err != nil {
+			message := "could not read body"
+			h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+				"user_id": userID,
+				"todo_id": todoID,
+			}, "could not patch todo: %s: %s", message, err.Error())
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+			return
+		}
+
+		err = h.todoService.ApplyJSONPatch(ctx, userID, todoID, patch, version)
+	case mergePatchContentType, gin.MIMEJSON, "":
+		var patchReq core.TodoPatchRequest
+		if err := c.BindJSON(&patchReq); err != nil {
+			message := "could not bind json"
+			h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+				"user_id": userID,
+				"todo_id": todoID,
+			}, "could not patch todo: %s: %s", message, err.Error())
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+			return
+		}
+
+		err = h.todoService.PatchByID(ctx, userID, todoID, patchReq, version)
+	default:
+		message := "unsupported content type"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not patch todo: %s: %s", message, contentType)
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, map[string]string{"error": message})
+		return
+	}
 
 	switch err {
 	case nil:
@@ -248,13 +662,41 @@ func (h *TodoGin) patchByID(c *gin.Context) {
 		}, "patched todo by id")
 		c.Status(http.StatusNoContent)
 		return
-	case service.ErrTodoNotFound:
+	case service.ErrTodoNotFound, service.ErrTodoOrProjectNotFound:
 		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
 			"user_id": userID,
 			"todo_id": todoID,
 		}, "could not patch todo by id: %s", err.Error())
 		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
 		return
+	case service.ErrTodoModified:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not patch todo by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, map[string]string{"error": err.Error()})
+		return
+	case service.ErrMalformedPatch:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not patch todo by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	case service.ErrPatchTestFailed:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not patch todo by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	case service.ErrInvalidPatch:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not patch todo by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
 	default:
 		message := "could not patch todo by id"
 		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
@@ -279,7 +721,18 @@ func (h *TodoGin) deleteByID(c *gin.Context) {
 
 	todoID := c.Param(todoIDKey)
 
-	err := h.todoService.DeleteByID(ctx, userID, todoID)
+	version, err := parseIfMatch(c)
+	if err != nil {
+		message := "invalid If-Match header"
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not delete todo by id: %s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+
+	err = h.todoService.DeleteByID(ctx, userID, todoID, version)
 
 	switch err {
 	case nil:
@@ -296,6 +749,13 @@ func (h *TodoGin) deleteByID(c *gin.Context) {
 		}, "could not delete todo by id: %s", err.Error())
 		c.AbortWithStatusJSON(http.StatusNotFound, map[string]string{"error": err.Error()})
 		return
+	case service.ErrTodoModified:
+		h.logger.LogFieldsf(logging.WarnLevel, logging.Fields{
+			"user_id": userID,
+			"todo_id": todoID,
+		}, "could not delete todo by id: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, map[string]string{"error": err.Error()})
+		return
 	default:
 		message := "could not delete todo by id"
 		h.logger.LogFieldsf(logging.ErrorLevel, logging.Fields{
@@ -334,3 +794,71 @@ func (h *TodoGin) deleteCompleted(c *gin.Context) {
 	}, "deleted completed todos")
 	c.Status(http.StatusNoContent)
 }
+
+// todoETag is a strong entity tag of the todo at the given version.
+func todoETag(version uint) string {
+	return strconv.Quote(strconv.FormatUint(uint64(version), 10))
+}
+
+// parseIfMatch returns zero when the request has no If-Match header or it
+// matches any version.
+func parseIfMatch(c *gin.Context) (uint, error) {
+	value := strings.TrimSpace(c.GetHeader(ifMatchHeader))
+	if len(value) == 0 || value == "*" {
+		return 0, nil
+	}
+
+	unquoted, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		return 0, errors.New("not a strong entity tag")
+	}
+
+	version, err := strconv.ParseUint(unquoted, 10, 32)
+	if err != nil || version == 0 {
+		return 0, errors.New("unknown entity tag")
+	}
+
+	return uint(version), nil
+}
+
+// ifNoneMatch reports whether the If-None-Match header of the request matches
+// the entity tag, comparing the tags weakly.
+func ifNoneMatch(c *gin.Context, etag string) bool {
+	value := c.GetHeader(ifNoneMatchHeader)
+	if len(value) == 0 {
+		return false
+	}
+
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseTodoPage(c *gin.Context) (core.TodoPageRequest, error) {
+	limit, err := parseLimit(c)
+	if err != nil {
+		return core.TodoPageRequest{}, err
+	}
+
+	return core.TodoPageRequest{
+		Cursor: c.Query(cursorQuery),
+		Limit:  limit,
+	}, nil
+}
+
+func parseLimit(c *gin.Context) (uint, error) {
+	limit, err := strconv.ParseUint(c.DefaultQuery(limitQuery, strconv.Itoa(defaultPageLimit)), 10, 16)
+	if err != nil {
+		return 0, err
+	}
+	if limit == 0 || limit > maxPageLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+
+	return uint(limit), nil
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/grimerssy/todo-service/internal/core"
+)
+
+const (
+	itemTodoUserIDs = "ARRAY(SELECT user_id FROM " + usersTodosTable + " WHERE todo_id = it.todo_id) AS user_ids"
+)
+
+type ItemPostgres struct {
+	db *sql.DB
+}
+
+func NewItemPostgres(db *sql.DB) *ItemPostgres {
+	return &ItemPostgres{
+		db: db,
+	}
+}
+
+func (r *ItemPostgres) Create(ctx context.Context, userID uint, todoID uint, item core.Item) ([]uint, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+INSERT INTO %s AS it (todo_id, title, completed, position)
+SELECT ut.todo_id, $3, $4, COALESCE($5, (SELECT MAX(position) + 1 FROM %s WHERE todo_id = ut.todo_id), 1)
+FROM %s ut
+WHERE ut.user_id = $1
+    AND ut.todo_id = $2
+    AND %s
+    AND %s
+RETURNING %s;
+`, todoItemsTable, todoItemsTable, usersTodosTable, canEditTodo, todoNotTrashed, itemTodoUserIDs)
+
+	var position *uint
+	if item.Position != 0 {
+		position = &item.Position
+	}
+
+	row := tx.QueryRowContext(ctx, query, userID, todoID, item.Title, item.Completed, position)
+	userIDs, err := scanUserIDs(row)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	if err := r.autoCompleteTodo(ctx, tx, userID, todoID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return userIDs, nil
+}
+
+func (r *ItemPostgres) GetByID(ctx context.Context, userID uint, todoID uint, itemID uint) (core.Item, error) {
+	query := fmt.Sprintf(`
+SELECT it.id, it.todo_id, it.title, it.completed, it.position, it.created_at, it.updated_at
+FROM %s it
+INNER JOIN %s ut
+ON ut.todo_id = it.todo_id
+WHERE ut.user_id = $1
+    AND it.todo_id = $2
+    AND it.id = $3
+    AND %s
+LIMIT 1;
+`, todoItemsTable, usersTodosTable, todoNotTrashed)
+
+	row := r.db.QueryRowContext(ctx, query, userID, todoID, itemID)
+	item, err := scanItem(row)
+	if err != nil {
+		return core.Item{}, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return item, nil
+}
+
+func (r *ItemPostgres) GetByTodoID(ctx context.Context, userID uint, todoID uint) ([]core.Item, error) {
+	query := fmt.Sprintf(`
+SELECT it.id, it.todo_id, it.title, it.completed, it.position, it.created_at, it.updated_at
+FROM %s it
+INNER JOIN %s ut
+ON ut.todo_id = it.todo_id
+WHERE ut.user_id = $1
+    AND it.todo_id = $2
+    AND %s
+ORDER BY it.position, it.id;
+`, todoItemsTable, usersTodosTable, todoNotTrashed)
+
+	rows, err := r.db.QueryContext(ctx, query, userID, todoID)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var items []core.Item
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan row: %s", err.Error())
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not iterate through rows: %s", err.Error())
+	}
+
+	return items, nil
+}
+
+func (r *ItemPostgres) UpdateByID(ctx context.Context, userID uint, todoID uint, itemID uint,
+	item core.Item) ([]uint, error) {
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+UPDATE %s it
+SET title = $1,
+    completed = $2,
+    position = COALESCE($3, it.position)
+FROM %s ut
+WHERE ut.user_id = $4
+    AND ut.todo_id = it.todo_id
+    AND it.todo_id = $5
+    AND it.id = $6
+    AND %s
+    AND %s
+RETURNING %s;
+`, todoItemsTable, usersTodosTable, canEditTodo, todoNotTrashed, itemTodoUserIDs)
+
+	var position *uint
+	if item.Position != 0 {
+		position = &item.Position
+	}
+
+	row := tx.QueryRowContext(ctx, query, item.Title, item.Completed, position, userID, todoID, itemID)
+	userIDs, err := scanUserIDs(row)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	if err := r.autoCompleteTodo(ctx, tx, userID, todoID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return userIDs, nil
+}
+
+func (r *ItemPostgres) DeleteByID(ctx context.Context, userID uint, todoID uint, itemID uint) ([]uint, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+DELETE FROM %s it
+USING %s ut
+WHERE ut.user_id = $1
+    AND ut.todo_id = it.todo_id
+    AND it.todo_id = $2
+    AND it.id = $3
+    AND %s
+    AND %s
+RETURNING %s;
+`, todoItemsTable, usersTodosTable, canEditTodo, todoNotTrashed, itemTodoUserIDs)
+
+	row := tx.QueryRowContext(ctx, query, userID, todoID, itemID)
+	userIDs, err := scanUserIDs(row)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	if err := r.autoCompleteTodo(ctx, tx, userID, todoID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return userIDs, nil
+}
+
+// autoCompleteTodo completes the todo once all of its items are completed,
+// recording the change in the history of the todo on behalf of the user.
+// Recurring todos are skipped, since completing them advances them instead.
+func (r *ItemPostgres) autoCompleteTodo(ctx context.Context, tx *sql.Tx, userID uint, todoID uint) error {
+	lockQuery := fmt.Sprintf(`
+SELECT %s
+FROM %s td
+WHERE td.id = $1
+    AND td.auto_complete = TRUE
+    AND td.completed = FALSE
+    AND td.recurrence = ''
+    AND EXISTS (SELECT 1 FROM %s WHERE todo_id = td.id)
+    AND NOT EXISTS (SELECT 1 FROM %s WHERE todo_id = td.id AND completed = FALSE)
+FOR UPDATE OF td;
+`, todoStateColumns, todosTable, todoItemsTable, todoItemsTable)
+
+	var before core.Todo
+	err := tx.QueryRowContext(ctx, lockQuery, todoID).Scan(todoStateFields(&before)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	query := fmt.Sprintf(`
+UPDATE %s td
+SET completed = TRUE
+WHERE td.id = $1
+RETURNING %s;
+`, todosTable, todoChangeColumns)
+
+	_, after, err := scanTodoChange(tx.QueryRowContext(ctx, query, todoID))
+	if err != nil {
+		return fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return recordTodoEvent(ctx, tx, userID, core.TodoUpdated, before, after)
+}
+
+func scanItem(row rowScanner) (core.Item, error) {
+	var item core.Item
+	err := row.Scan(
+		&item.ID, &item.TodoID, &item.Title, &item.Completed, &item.Position, &item.CreatedAt, &item.UpdatedAt)
+
+	return item, err
+}
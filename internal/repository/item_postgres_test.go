@@ -0,0 +1,394 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// autoCompleteStateColumns are the columns of the todo an item may complete,
+// and autoCompleteColumns those returned once the item completes it.
+var (
+	autoCompleteStateColumns = []string{
+		"title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "deleted_at"}
+	autoCompleteColumns = append(append([]string{"id"}, autoCompleteStateColumns...), "user_ids")
+)
+
+func TestItemPostgres_Create(t *testing.T) {
+	const (
+		id       = 1
+		userID   = 1
+		todoID   = 1
+		title    = "t"
+		position = 2
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewItemPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		input     core.Item
+		want      []uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_ids"}).AddRow("{1,2}")
+				m.ExpectBegin()
+				m.ExpectQuery("INSERT INTO "+todoItemsTable).
+					WithArgs(userID, todoID, title, false, nil).
+					WillReturnRows(rows)
+				m.ExpectQuery("SELECT (.+) FROM " + todosTable + " td WHERE (.+) AND td.recurrence = '' (.+) FOR UPDATE OF td").
+					WithArgs(todoID).
+					WillReturnRows(sqlmock.NewRows(autoCompleteStateColumns))
+				m.ExpectCommit()
+			},
+			input:     core.Item{Title: title},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok with position",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_ids"}).AddRow("{1,2}")
+				m.ExpectBegin()
+				m.ExpectQuery("INSERT INTO "+todoItemsTable).
+					WithArgs(userID, todoID, title, false, position).
+					WillReturnRows(rows)
+				m.ExpectQuery("SELECT (.+) FROM " + todosTable + " td WHERE (.+) AND td.recurrence = '' (.+) FOR UPDATE OF td").
+					WithArgs(todoID).
+					WillReturnRows(sqlmock.NewRows(autoCompleteStateColumns))
+				m.ExpectCommit()
+			},
+			input:     core.Item{Title: title, Position: position},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok completed",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_ids"}).AddRow("{1,2}")
+				m.ExpectBegin()
+				m.ExpectQuery("INSERT INTO "+todoItemsTable).
+					WithArgs(userID, todoID, title, true, nil).
+					WillReturnRows(rows)
+				m.ExpectQuery("SELECT (.+) FROM " + todosTable).
+					WithArgs(todoID).
+					WillReturnRows(sqlmock.NewRows(autoCompleteStateColumns).
+						AddRow("t", "", false, core.PriorityNone, nil, true, "", nil))
+				m.ExpectQuery("UPDATE " + todosTable).
+					WithArgs(todoID).
+					WillReturnRows(sqlmock.NewRows(autoCompleteColumns).
+						AddRow(todoID, "t", "", true, core.PriorityNone, nil, true, "", nil, "{1,2}"))
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(todoID, userID, core.TodoUpdated, `{"completed":{"before":false,"after":true}}`).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				m.ExpectCommit()
+			},
+			input:     core.Item{Title: title, Completed: true},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "todo not found",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_ids"})
+				m.ExpectBegin()
+				m.ExpectQuery("INSERT INTO "+todoItemsTable).
+					WithArgs(userID, todoID, title, false, nil).
+					WillReturnRows(rows)
+				m.ExpectRollback()
+			},
+			input:     core.Item{Title: title},
+			want:      nil,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.Create(context.Background(), userID, todoID, tt.input)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestItemPostgres_GetByID(t *testing.T) {
+	const (
+		id       = 1
+		userID   = 1
+		todoID   = 1
+		title    = "t"
+		position = 1
+	)
+	now := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewItemPostgres(db)
+
+	columns := []string{"id", "todo_id", "title", "completed", "position", "created_at", "updated_at"}
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		want      core.Item
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(id, todoID, title, true, position, now, now)
+				m.ExpectQuery("SELECT (.+) FROM "+todoItemsTable).
+					WithArgs(userID, todoID, id).
+					WillReturnRows(rows)
+			},
+			want: core.Item{
+				ID:        id,
+				TodoID:    todoID,
+				Title:     title,
+				Completed: true,
+				Position:  position,
+				CreatedAt: now,
+				UpdatedAt: now,
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "not found",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns)
+				m.ExpectQuery("SELECT (.+) FROM "+todoItemsTable).
+					WithArgs(userID, todoID, id).
+					WillReturnRows(rows)
+			},
+			want:      core.Item{},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetByID(context.Background(), userID, todoID, id)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestItemPostgres_GetByTodoID(t *testing.T) {
+	const (
+		userID = 1
+		todoID = 1
+		title  = "t"
+	)
+	now := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewItemPostgres(db)
+
+	columns := []string{"id", "todo_id", "title", "completed", "position", "created_at", "updated_at"}
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		want      []core.Item
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(1, todoID, title, false, 1, now, now).
+					AddRow(2, todoID, title, true, 2, now, now)
+				m.ExpectQuery("SELECT (.+) FROM "+todoItemsTable+"(.+)ORDER BY it.position, it.id").
+					WithArgs(userID, todoID).
+					WillReturnRows(rows)
+			},
+			want: []core.Item{
+				{ID: 1, TodoID: todoID, Title: title, Completed: false, Position: 1, CreatedAt: now, UpdatedAt: now},
+				{ID: 2, TodoID: todoID, Title: title, Completed: true, Position: 2, CreatedAt: now, UpdatedAt: now},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "fail to select",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("SELECT (.+) FROM "+todoItemsTable).
+					WithArgs(userID, todoID).
+					WillReturnError(errors.New(""))
+			},
+			want:      nil,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetByTodoID(context.Background(), userID, todoID)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestItemPostgres_UpdateByID(t *testing.T) {
+	const (
+		id     = 1
+		userID = 1
+		todoID = 1
+		title  = "t"
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewItemPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		input     core.Item
+		want      []uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_ids"}).AddRow("{1,2}")
+				m.ExpectBegin()
+				m.ExpectQuery("UPDATE "+todoItemsTable).
+					WithArgs(title, true, nil, userID, todoID, id).
+					WillReturnRows(rows)
+				m.ExpectQuery("SELECT (.+) FROM " + todosTable).
+					WithArgs(todoID).
+					WillReturnRows(sqlmock.NewRows(autoCompleteStateColumns).
+						AddRow("t", "", false, core.PriorityNone, nil, true, "", nil))
+				m.ExpectQuery("UPDATE " + todosTable).
+					WithArgs(todoID).
+					WillReturnRows(sqlmock.NewRows(autoCompleteColumns).
+						AddRow(todoID, "t", "", true, core.PriorityNone, nil, true, "", nil, "{1,2}"))
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(todoID, userID, core.TodoUpdated, `{"completed":{"before":false,"after":true}}`).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				m.ExpectCommit()
+			},
+			input:     core.Item{Title: title, Completed: true},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "not found",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_ids"})
+				m.ExpectBegin()
+				m.ExpectQuery("UPDATE "+todoItemsTable).
+					WithArgs(title, true, nil, userID, todoID, id).
+					WillReturnRows(rows)
+				m.ExpectRollback()
+			},
+			input:     core.Item{Title: title, Completed: true},
+			want:      nil,
+			errAssert: assert.Error,
+		},
+		{
+			name: "fail to auto complete",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_ids"}).AddRow("{1,2}")
+				m.ExpectBegin()
+				m.ExpectQuery("UPDATE "+todoItemsTable).
+					WithArgs(title, true, nil, userID, todoID, id).
+					WillReturnRows(rows)
+				m.ExpectQuery("SELECT (.+) FROM " + todosTable).
+					WithArgs(todoID).
+					WillReturnError(errors.New(""))
+				m.ExpectRollback()
+			},
+			input:     core.Item{Title: title, Completed: true},
+			want:      nil,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.UpdateByID(context.Background(), userID, todoID, id, tt.input)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestItemPostgres_DeleteByID(t *testing.T) {
+	const (
+		id     = 1
+		userID = 1
+		todoID = 1
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewItemPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		want      []uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_ids"}).AddRow("{1,2}")
+				m.ExpectBegin()
+				m.ExpectQuery("DELETE FROM "+todoItemsTable).
+					WithArgs(userID, todoID, id).
+					WillReturnRows(rows)
+				m.ExpectQuery("SELECT (.+) FROM " + todosTable + " td WHERE (.+) AND td.recurrence = '' (.+) FOR UPDATE OF td").
+					WithArgs(todoID).
+					WillReturnRows(sqlmock.NewRows(autoCompleteStateColumns))
+				m.ExpectCommit()
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "not found",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_ids"})
+				m.ExpectBegin()
+				m.ExpectQuery("DELETE FROM "+todoItemsTable).
+					WithArgs(userID, todoID, id).
+					WillReturnRows(rows)
+				m.ExpectRollback()
+			},
+			want:      nil,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.DeleteByID(context.Background(), userID, todoID, id)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/grimerssy/todo-service/internal/core"
+)
+
+type ProjectPostgres struct {
+	db *sql.DB
+}
+
+func NewProjectPostgres(db *sql.DB) *ProjectPostgres {
+	return &ProjectPostgres{
+		db: db,
+	}
+}
+
+func (r *ProjectPostgres) Create(ctx context.Context, userID uint, project core.Project) error {
+	query := fmt.Sprintf(`
+INSERT INTO %s (user_id, name, color, archived)
+VALUES ($1, $2, $3, $4);
+`, projectsTable)
+
+	if _, err := r.db.ExecContext(ctx, query, userID, project.Name, project.Color, project.Archived); err != nil {
+		if isUniqueViolation(err) {
+			return ErrAlreadyExists
+		}
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (r *ProjectPostgres) GetByID(ctx context.Context, userID uint, projectID uint) (core.Project, error) {
+	query := fmt.Sprintf(`
+SELECT id, name, color, archived, created_at, updated_at
+FROM %s
+WHERE user_id = $1
+    AND id = $2
+LIMIT 1;
+`, projectsTable)
+
+	row := r.db.QueryRowContext(ctx, query, userID, projectID)
+	project, err := scanProject(row)
+	if err != nil {
+		return core.Project{}, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return project, nil
+}
+
+func (r *ProjectPostgres) GetAll(ctx context.Context, userID uint) ([]core.Project, error) {
+	query := fmt.Sprintf(`
+SELECT id, name, color, archived, created_at, updated_at
+FROM %s
+WHERE user_id = $1
+ORDER BY archived, name;
+`, projectsTable)
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var projects []core.Project
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan row: %s", err.Error())
+		}
+		projects = append(projects, project)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not iterate through rows: %s", err.Error())
+	}
+
+	return projects, nil
+}
+
+func (r *ProjectPostgres) UpdateByID(ctx context.Context, userID uint, projectID uint,
+	project core.Project) (uint, error) {
+
+	query := fmt.Sprintf(`
+UPDATE %s
+SET name = $1,
+    color = $2,
+    archived = $3
+WHERE user_id = $4
+    AND id = $5
+RETURNING id;
+`, projectsTable)
+
+	var id uint
+	row := r.db.QueryRowContext(ctx, query, project.Name, project.Color, project.Archived, userID, projectID)
+	if err := row.Scan(&id); err != nil {
+		if isUniqueViolation(err) {
+			return 0, ErrAlreadyExists
+		}
+		return 0, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return id, nil
+}
+
+// DeleteByID returns the users whose todos were affected. Owned todos are
+// either trashed or moved to the inbox, shared ones always go to the inbox.
+func (r *ProjectPostgres) DeleteByID(ctx context.Context, userID uint, projectID uint,
+	deleteTodos bool) ([]uint, error) {
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	userIDs := []uint{userID}
+
+	if deleteTodos {
+		query := fmt.Sprintf(`
+UPDATE %s td
+SET deleted_at = NOW()
+FROM %s ut
+WHERE ut.user_id = $1
+    AND ut.project_id = $2
+    AND ut.todo_id = td.id
+    AND td.deleted_at IS NULL
+    AND %s
+RETURNING %s;
+`, todosTable, usersTodosTable, ownsTodo, todoChangeColumns)
+
+		rows, err := tx.QueryContext(ctx, query, userID, projectID)
+		if err != nil {
+			return nil, fmt.Errorf("could not execute query: %s", err.Error())
+		}
+		ids, err := recordTrashEvents(ctx, tx, userID, rows)
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, ids...)
+	}
+
+	query := fmt.Sprintf(`
+DELETE FROM %s
+WHERE user_id = $1
+    AND id = $2
+RETURNING id;
+`, projectsTable)
+
+	var id uint
+	row := tx.QueryRowContext(ctx, query, userID, projectID)
+	if err := row.Scan(&id); err != nil {
+		return nil, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return userIDs, nil
+}
+
+func scanProject(row rowScanner) (core.Project, error) {
+	var project core.Project
+	err := row.Scan(
+		&project.ID, &project.Name, &project.Color, &project.Archived, &project.CreatedAt, &project.UpdatedAt)
+
+	return project, err
+}
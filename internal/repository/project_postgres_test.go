@@ -0,0 +1,385 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectPostgres_Create(t *testing.T) {
+	const (
+		id       = 1
+		name     = "n"
+		color    = "#ff0000"
+		archived = false
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewProjectPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		input     core.Project
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+projectsTable).
+					WithArgs(id, name, color, archived).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+			},
+			input:     core.Project{Name: name, Color: color},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "duplicate name",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+projectsTable).
+					WithArgs(id, name, color, archived).
+					WillReturnError(&pq.Error{Code: uniqueViolationCode})
+			},
+			input: core.Project{Name: name, Color: color},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrAlreadyExists)
+			},
+		},
+		{
+			name: "fail to insert",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+projectsTable).
+					WithArgs(id, name, color, archived).
+					WillReturnError(errors.New(""))
+			},
+			input:     core.Project{Name: name, Color: color},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.Create(context.Background(), id, tt.input)
+		tt.errAssert(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestProjectPostgres_GetByID(t *testing.T) {
+	const (
+		id       = 1
+		name     = "n"
+		color    = "#ff0000"
+		archived = true
+	)
+	now := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewProjectPostgres(db)
+
+	columns := []string{"id", "name", "color", "archived", "created_at", "updated_at"}
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		projectID uint
+		want      core.Project
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(id, name, color, archived, now, now)
+				m.ExpectQuery("SELECT id, name, color, archived, created_at, updated_at FROM "+projectsTable).
+					WithArgs(id, id).
+					WillReturnRows(rows)
+			},
+			userID:    id,
+			projectID: id,
+			want: core.Project{
+				ID:        id,
+				Name:      name,
+				Color:     color,
+				Archived:  archived,
+				CreatedAt: now,
+				UpdatedAt: now,
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no project",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns)
+				m.ExpectQuery("SELECT id, name, color, archived, created_at, updated_at FROM "+projectsTable).
+					WithArgs(id, 0).
+					WillReturnRows(rows)
+			},
+			userID:    id,
+			projectID: 0,
+			want:      core.Project{},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetByID(context.Background(), tt.userID, tt.projectID)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestProjectPostgres_GetAll(t *testing.T) {
+	const (
+		id    = 1
+		name  = "n"
+		color = "#ff0000"
+	)
+	now := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewProjectPostgres(db)
+
+	columns := []string{"id", "name", "color", "archived", "created_at", "updated_at"}
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		want      []core.Project
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(id, name, color, false, now, now)
+				m.ExpectQuery("SELECT id, name, color, archived, created_at, updated_at FROM " + projectsTable).
+					WithArgs(id).
+					WillReturnRows(rows)
+			},
+			userID: id,
+			want: []core.Project{
+				{
+					ID:        id,
+					Name:      name,
+					Color:     color,
+					CreatedAt: now,
+					UpdatedAt: now,
+				},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no results",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns)
+				m.ExpectQuery("SELECT id, name, color, archived, created_at, updated_at FROM " + projectsTable).
+					WithArgs(id).
+					WillReturnRows(rows)
+			},
+			userID:    id,
+			want:      nil,
+			errAssert: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetAll(context.Background(), tt.userID)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestProjectPostgres_UpdateByID(t *testing.T) {
+	const (
+		id       = 1
+		name     = "n"
+		color    = "#ff0000"
+		archived = true
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewProjectPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		projectID uint
+		input     core.Project
+		want      uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"}).
+					AddRow(id)
+				m.ExpectQuery("UPDATE "+projectsTable).
+					WithArgs(name, color, archived, id, id).
+					WillReturnRows(rows)
+			},
+			userID:    id,
+			projectID: id,
+			input:     core.Project{Name: name, Color: color, Archived: archived},
+			want:      id,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "duplicate name",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("UPDATE "+projectsTable).
+					WithArgs(name, color, archived, id, id).
+					WillReturnError(&pq.Error{Code: uniqueViolationCode})
+			},
+			userID:    id,
+			projectID: id,
+			input:     core.Project{Name: name, Color: color, Archived: archived},
+			want:      0,
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrAlreadyExists)
+			},
+		},
+		{
+			name: "no project",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"})
+				m.ExpectQuery("UPDATE "+projectsTable).
+					WithArgs(name, color, archived, id, 0).
+					WillReturnRows(rows)
+			},
+			userID:    id,
+			projectID: 0,
+			input:     core.Project{Name: name, Color: color, Archived: archived},
+			want:      0,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.UpdateByID(context.Background(), tt.userID, tt.projectID, tt.input)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestProjectPostgres_DeleteByID(t *testing.T) {
+	const id = 1
+	deletedAt := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewProjectPostgres(db)
+
+	changeColumns := []string{
+		"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "deleted_at",
+		"user_ids"}
+
+	tests := []struct {
+		name        string
+		mock        func(m sqlmock.Sqlmock)
+		userID      uint
+		projectID   uint
+		deleteTodos bool
+		want        []uint
+		errAssert   assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok move todos to inbox",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"}).
+					AddRow(id)
+				m.ExpectBegin()
+				m.ExpectQuery("DELETE FROM "+projectsTable).
+					WithArgs(id, id).
+					WillReturnRows(rows)
+				m.ExpectCommit()
+			},
+			userID:      id,
+			projectID:   id,
+			deleteTodos: false,
+			want:        []uint{id},
+			errAssert:   assert.NoError,
+		},
+		{
+			name: "ok delete todos",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"}).
+					AddRow(id)
+				m.ExpectBegin()
+				todoRows := sqlmock.NewRows(changeColumns).
+					AddRow(1, "t", "", false, core.PriorityNone, nil, false, "", deletedAt, "{1}").
+					AddRow(2, "t", "", false, core.PriorityNone, nil, false, "", deletedAt, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable+" td SET deleted_at = NOW\\(\\)").
+					WithArgs(id, id).
+					WillReturnRows(todoRows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(1, id, core.TodoDeleted, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(2, id, core.TodoDeleted, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				m.ExpectQuery("DELETE FROM "+projectsTable).
+					WithArgs(id, id).
+					WillReturnRows(rows)
+				m.ExpectCommit()
+			},
+			userID:      id,
+			projectID:   id,
+			deleteTodos: true,
+			want:        []uint{id, 1, 1, 2},
+			errAssert:   assert.NoError,
+		},
+		{
+			name: "no project",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"})
+				m.ExpectBegin()
+				m.ExpectQuery("UPDATE "+todosTable+" td SET deleted_at = NOW\\(\\)").
+					WithArgs(id, 0).
+					WillReturnRows(sqlmock.NewRows(changeColumns))
+				m.ExpectQuery("DELETE FROM "+projectsTable).
+					WithArgs(id, 0).
+					WillReturnRows(rows)
+				m.ExpectRollback()
+			},
+			userID:      id,
+			projectID:   0,
+			deleteTodos: true,
+			want:        nil,
+			errAssert:   assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.DeleteByID(context.Background(), tt.userID, tt.projectID, tt.deleteTodos)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
@@ -2,33 +2,112 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/grimerssy/todo-service/internal/core"
 )
 
 const (
-	usersTable      = "users"
-	todosTable      = "todos"
-	usersTodosTable = "users_todos"
+	usersTable         = "users"
+	todosTable         = "todos"
+	usersTodosTable    = "users_todos"
+	tagsTable          = "tags"
+	projectsTable      = "projects"
+	todosTagsTable     = "todos_tags"
+	todoItemsTable     = "todo_items"
+	todoEventsTable    = "todo_events"
+	feedTokensTable    = "feed_tokens"
+	davPasswordsTable  = "dav_passwords"
+	refreshTokensTable = "refresh_tokens"
+	revokedTokensTable = "revoked_tokens"
+)
+
+var (
+	ErrAlreadyExists   = errors.New("record already exists")
+	ErrNotFound        = errors.New("record not found")
+	ErrVersionMismatch = errors.New("record version does not match")
+	ErrTokenReused     = errors.New("token has already been used")
 )
 
 type Repositories struct {
 	UserRepository
 	TodoRepository
+	ItemRepository
+	TagRepository
+	ProjectRepository
 }
 
 type UserRepository interface {
 	Create(ctx context.Context, user core.User) error
 	GetCredentialsByUsername(ctx context.Context, username string) (core.User, error)
+	SetFeedToken(ctx context.Context, userID uint, tokenHash string) error
+	GetIDByFeedToken(ctx context.Context, tokenHash string) (uint, error)
+	DeleteFeedToken(ctx context.Context, userID uint) error
+	SetDavPassword(ctx context.Context, userID uint, passwordHash string) error
+	GetIDByDavPassword(ctx context.Context, username, passwordHash string) (uint, error)
+	DeleteDavPassword(ctx context.Context, userID uint) error
+	CreateRefreshToken(ctx context.Context, userID uint, tokenHash string, expiresAt time.Time) error
+	RotateRefreshToken(ctx context.Context, tokenHash, newTokenHash string, expiresAt time.Time) (uint, error)
+	DeleteRefreshTokenFamily(ctx context.Context, userID uint, tokenHash string) error
+	RevokeToken(ctx context.Context, userID uint, tokenID string, expiresAt time.Time) error
+	RevokeAllTokens(ctx context.Context, userID uint, issuedAt time.Time) error
+	IsTokenRevoked(ctx context.Context, userID uint, tokenID string, issuedAt time.Time) (bool, error)
+	PruneRevokedTokens(ctx context.Context) error
 }
 
 type TodoRepository interface {
-	Create(ctx context.Context, userID uint, todo core.Todo) error
+	Create(ctx context.Context, userID uint, todo core.Todo) (uint, error)
 	GetByID(ctx context.Context, userID uint, todoID uint) (core.Todo, error)
-	GetByCompletion(ctx context.Context, userID uint, completed bool) ([]core.Todo, error)
-	GetAll(ctx context.Context, userID uint) ([]core.Todo, error)
-	UpdateByID(ctx context.Context, userID uint, todoID uint, todo core.Todo) (uint, error)
-	PatchByID(ctx context.Context, userID uint, todoID uint, todo core.Todo) (uint, error)
-	DeleteByID(ctx context.Context, userID uint, todoID uint) (uint, error)
+	GetByCompletion(ctx context.Context, userID uint, completed bool, sort core.TodoSort,
+		page core.TodoPage) ([]core.Todo, error)
+	GetAll(ctx context.Context, userID uint, filter core.TodoFilter, sort core.TodoSort,
+		page core.TodoPage) ([]core.Todo, error)
+	GetByProject(ctx context.Context, userID uint, projectID uint, sort core.TodoSort) ([]core.Todo, error)
+	GetOverdue(ctx context.Context, userID uint, now time.Time) ([]core.Todo, error)
+	GetDueBetween(ctx context.Context, userID uint, from, to time.Time) ([]core.Todo, error)
+	Search(ctx context.Context, userID uint, query string, limit uint) ([]core.TodoSearchResult, error)
+	UpdateByID(ctx context.Context, userID uint, todoID uint, todo core.Todo) ([]uint, error)
+	PatchByID(ctx context.Context, userID uint, todoID uint, patch core.TodoPatch) ([]uint, error)
+	DeleteByID(ctx context.Context, userID uint, todoID uint, version uint) ([]uint, error)
 	DeleteByCompletion(ctx context.Context, userID uint, completed bool) ([]uint, error)
+	Export(ctx context.Context, userID uint, fn func(todo core.Todo) error) error
+	ExportCalendar(ctx context.Context, userID uint, fn func(obj core.CalendarObject) error) error
+	GetCalendarObject(ctx context.Context, userID uint, name string, todoID uint) (core.CalendarObject, error)
+	SetCalendarName(ctx context.Context, userID uint, todoID uint, name, uid string) error
+	GetTrash(ctx context.Context, userID uint) ([]core.Todo, error)
+	Restore(ctx context.Context, userID uint, todoID uint) ([]uint, error)
+	EmptyTrash(ctx context.Context, userID uint) error
+	Purge(ctx context.Context, before time.Time) ([]uint, error)
+	Share(ctx context.Context, ownerID uint, todoID uint, username string, role core.Role) (uint, error)
+	GetCollaborators(ctx context.Context, userID uint, todoID uint) ([]core.Collaborator, error)
+	RevokeAccess(ctx context.Context, userID uint, todoID uint, collaboratorID uint) error
+	GetHistory(ctx context.Context, userID uint, todoID uint, page core.TodoPage) ([]core.TodoEvent, error)
+	InTransaction(ctx context.Context, fn func(repo TodoRepository) error) error
+}
+
+type ItemRepository interface {
+	Create(ctx context.Context, userID uint, todoID uint, item core.Item) ([]uint, error)
+	GetByID(ctx context.Context, userID uint, todoID uint, itemID uint) (core.Item, error)
+	GetByTodoID(ctx context.Context, userID uint, todoID uint) ([]core.Item, error)
+	UpdateByID(ctx context.Context, userID uint, todoID uint, itemID uint, item core.Item) ([]uint, error)
+	DeleteByID(ctx context.Context, userID uint, todoID uint, itemID uint) ([]uint, error)
+}
+
+type TagRepository interface {
+	Create(ctx context.Context, userID uint, tag core.Tag) error
+	GetByID(ctx context.Context, userID uint, tagID uint) (core.Tag, error)
+	GetAll(ctx context.Context, userID uint) ([]core.Tag, error)
+	UpdateByID(ctx context.Context, userID uint, tagID uint, tag core.Tag) (uint, error)
+	DeleteByID(ctx context.Context, userID uint, tagID uint) (uint, error)
+	AttachToTodo(ctx context.Context, userID uint, todoID uint, tagID uint) error
+	DetachFromTodo(ctx context.Context, userID uint, todoID uint, tagID uint) error
+}
+
+type ProjectRepository interface {
+	Create(ctx context.Context, userID uint, project core.Project) error
+	GetByID(ctx context.Context, userID uint, projectID uint) (core.Project, error)
+	GetAll(ctx context.Context, userID uint) ([]core.Project, error)
+	UpdateByID(ctx context.Context, userID uint, projectID uint, project core.Project) (uint, error)
+	DeleteByID(ctx context.Context, userID uint, projectID uint, deleteTodos bool) ([]uint, error)
 }
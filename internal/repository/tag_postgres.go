@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/lib/pq"
+)
+
+const (
+	uniqueViolationCode = "23505"
+)
+
+type TagPostgres struct {
+	db *sql.DB
+}
+
+func NewTagPostgres(db *sql.DB) *TagPostgres {
+	return &TagPostgres{
+		db: db,
+	}
+}
+
+func (r *TagPostgres) Create(ctx context.Context, userID uint, tag core.Tag) error {
+	query := fmt.Sprintf(`
+INSERT INTO %s (user_id, name)
+VALUES ($1, $2);
+`, tagsTable)
+
+	if _, err := r.db.ExecContext(ctx, query, userID, tag.Name); err != nil {
+		if isUniqueViolation(err) {
+			return ErrAlreadyExists
+		}
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (r *TagPostgres) GetByID(ctx context.Context, userID uint, tagID uint) (core.Tag, error) {
+	query := fmt.Sprintf(`
+SELECT id, name, created_at, updated_at
+FROM %s
+WHERE user_id = $1
+    AND id = $2
+LIMIT 1;
+`, tagsTable)
+
+	var tag core.Tag
+	row := r.db.QueryRowContext(ctx, query, userID, tagID)
+	if err := row.Scan(&tag.ID, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+		return core.Tag{}, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return tag, nil
+}
+
+func (r *TagPostgres) GetAll(ctx context.Context, userID uint) ([]core.Tag, error) {
+	query := fmt.Sprintf(`
+SELECT id, name, created_at, updated_at
+FROM %s
+WHERE user_id = $1
+ORDER BY name;
+`, tagsTable)
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var tags []core.Tag
+	for rows.Next() {
+		var tag core.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("could not scan row: %s", err.Error())
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not iterate through rows: %s", err.Error())
+	}
+
+	return tags, nil
+}
+
+func (r *TagPostgres) UpdateByID(ctx context.Context, userID uint, tagID uint, tag core.Tag) (uint, error) {
+	query := fmt.Sprintf(`
+UPDATE %s
+SET name = $1
+WHERE user_id = $2
+    AND id = $3
+RETURNING id;
+`, tagsTable)
+
+	var id uint
+	row := r.db.QueryRowContext(ctx, query, tag.Name, userID, tagID)
+	if err := row.Scan(&id); err != nil {
+		if isUniqueViolation(err) {
+			return 0, ErrAlreadyExists
+		}
+		return 0, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return id, nil
+}
+
+func (r *TagPostgres) DeleteByID(ctx context.Context, userID uint, tagID uint) (uint, error) {
+	query := fmt.Sprintf(`
+DELETE FROM %s
+WHERE user_id = $1
+    AND id = $2
+RETURNING id;
+`, tagsTable)
+
+	var id uint
+	row := r.db.QueryRowContext(ctx, query, userID, tagID)
+	if err := row.Scan(&id); err != nil {
+		return 0, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return id, nil
+}
+
+func (r *TagPostgres) AttachToTodo(ctx context.Context, userID uint, todoID uint, tagID uint) error {
+	query := fmt.Sprintf(`
+WITH target AS (
+    SELECT ut.todo_id, tg.id AS tag_id
+    FROM %s ut
+    INNER JOIN %s tg
+    ON tg.user_id = ut.user_id
+    WHERE ut.user_id = $1
+        AND ut.todo_id = $2
+        AND tg.id = $3
+        AND %s
+        AND %s
+), attached AS (
+    INSERT INTO %s (todo_id, tag_id)
+    SELECT todo_id, tag_id FROM target
+    ON CONFLICT DO NOTHING
+)
+SELECT todo_id FROM target;
+`, usersTodosTable, tagsTable, canEditTodo, todoNotTrashed, todosTagsTable)
+
+	var id uint
+	row := r.db.QueryRowContext(ctx, query, userID, todoID, tagID)
+	if err := row.Scan(&id); err != nil {
+		return fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (r *TagPostgres) DetachFromTodo(ctx context.Context, userID uint, todoID uint, tagID uint) error {
+	query := fmt.Sprintf(`
+DELETE FROM %s tt
+USING %s ut, %s tg
+WHERE ut.user_id = $1
+    AND ut.todo_id = tt.todo_id
+    AND tg.user_id = $1
+    AND tg.id = tt.tag_id
+    AND tt.todo_id = $2
+    AND tt.tag_id = $3
+    AND %s
+    AND %s
+RETURNING tt.todo_id;
+`, todosTagsTable, usersTodosTable, tagsTable, canEditTodo, todoNotTrashed)
+
+	var id uint
+	row := r.db.QueryRowContext(ctx, query, userID, todoID, tagID)
+	if err := row.Scan(&id); err != nil {
+		return fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode
+}
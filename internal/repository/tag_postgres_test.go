@@ -0,0 +1,439 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagPostgres_Create(t *testing.T) {
+	const (
+		id   = 1
+		name = "n"
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTagPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		input     core.Tag
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+tagsTable).
+					WithArgs(id, name).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+			},
+			input:     core.Tag{Name: name},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "duplicate name",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+tagsTable).
+					WithArgs(id, name).
+					WillReturnError(&pq.Error{Code: uniqueViolationCode})
+			},
+			input: core.Tag{Name: name},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrAlreadyExists)
+			},
+		},
+		{
+			name: "fail to insert",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+tagsTable).
+					WithArgs(id, name).
+					WillReturnError(errors.New(""))
+			},
+			input:     core.Tag{Name: name},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.Create(context.Background(), id, tt.input)
+		tt.errAssert(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTagPostgres_GetByID(t *testing.T) {
+	const (
+		id   = 1
+		name = "n"
+	)
+	now := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTagPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		tagID     uint
+		want      core.Tag
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at"}).
+					AddRow(id, name, now, now)
+				m.ExpectQuery("SELECT id, name, created_at, updated_at FROM "+tagsTable).
+					WithArgs(id, id).
+					WillReturnRows(rows)
+			},
+			userID: id,
+			tagID:  id,
+			want: core.Tag{
+				ID:        id,
+				Name:      name,
+				CreatedAt: now,
+				UpdatedAt: now,
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no tag",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at"})
+				m.ExpectQuery("SELECT id, name, created_at, updated_at FROM "+tagsTable).
+					WithArgs(id, 0).
+					WillReturnRows(rows)
+			},
+			userID:    id,
+			tagID:     0,
+			want:      core.Tag{},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetByID(context.Background(), tt.userID, tt.tagID)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTagPostgres_GetAll(t *testing.T) {
+	const (
+		id   = 1
+		name = "n"
+	)
+	now := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTagPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		want      []core.Tag
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at"}).
+					AddRow(id, name, now, now)
+				m.ExpectQuery("SELECT id, name, created_at, updated_at FROM " + tagsTable).
+					WithArgs(id).
+					WillReturnRows(rows)
+			},
+			userID: id,
+			want: []core.Tag{
+				{
+					ID:        id,
+					Name:      name,
+					CreatedAt: now,
+					UpdatedAt: now,
+				},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no results",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at"})
+				m.ExpectQuery("SELECT id, name, created_at, updated_at FROM " + tagsTable).
+					WithArgs(id).
+					WillReturnRows(rows)
+			},
+			userID:    id,
+			want:      nil,
+			errAssert: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetAll(context.Background(), tt.userID)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTagPostgres_UpdateByID(t *testing.T) {
+	const (
+		id   = 1
+		name = "n"
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTagPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		tagID     uint
+		input     core.Tag
+		want      uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"}).
+					AddRow(id)
+				m.ExpectQuery("UPDATE "+tagsTable).
+					WithArgs(name, id, id).
+					WillReturnRows(rows)
+			},
+			userID:    id,
+			tagID:     id,
+			input:     core.Tag{Name: name},
+			want:      id,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "duplicate name",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("UPDATE "+tagsTable).
+					WithArgs(name, id, id).
+					WillReturnError(&pq.Error{Code: uniqueViolationCode})
+			},
+			userID: id,
+			tagID:  id,
+			input:  core.Tag{Name: name},
+			want:   0,
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrAlreadyExists)
+			},
+		},
+		{
+			name: "no tag",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"})
+				m.ExpectQuery("UPDATE "+tagsTable).
+					WithArgs(name, id, 0).
+					WillReturnRows(rows)
+			},
+			userID:    id,
+			tagID:     0,
+			input:     core.Tag{Name: name},
+			want:      0,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.UpdateByID(context.Background(), tt.userID, tt.tagID, tt.input)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTagPostgres_DeleteByID(t *testing.T) {
+	const id = 1
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTagPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		tagID     uint
+		want      uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"}).
+					AddRow(id)
+				m.ExpectQuery("DELETE FROM "+tagsTable).
+					WithArgs(id, id).
+					WillReturnRows(rows)
+			},
+			userID:    id,
+			tagID:     id,
+			want:      id,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no tag",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"})
+				m.ExpectQuery("DELETE FROM "+tagsTable).
+					WithArgs(id, 0).
+					WillReturnRows(rows)
+			},
+			userID:    id,
+			tagID:     0,
+			want:      0,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.DeleteByID(context.Background(), tt.userID, tt.tagID)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTagPostgres_AttachToTodo(t *testing.T) {
+	const id = 1
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTagPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		todoID    uint
+		tagID     uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"todo_id"}).
+					AddRow(id)
+				m.ExpectQuery("INSERT INTO "+todosTagsTable).
+					WithArgs(id, id, id).
+					WillReturnRows(rows)
+			},
+			todoID:    id,
+			tagID:     id,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no todo",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"todo_id"})
+				m.ExpectQuery("INSERT INTO "+todosTagsTable).
+					WithArgs(id, 0, id).
+					WillReturnRows(rows)
+			},
+			todoID:    0,
+			tagID:     id,
+			errAssert: assert.Error,
+		},
+		{
+			name: "no tag",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"todo_id"})
+				m.ExpectQuery("INSERT INTO "+todosTagsTable).
+					WithArgs(id, id, 0).
+					WillReturnRows(rows)
+			},
+			todoID:    id,
+			tagID:     0,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.AttachToTodo(context.Background(), id, tt.todoID, tt.tagID)
+		tt.errAssert(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTagPostgres_DetachFromTodo(t *testing.T) {
+	const id = 1
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTagPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		todoID    uint
+		tagID     uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"todo_id"}).
+					AddRow(id)
+				m.ExpectQuery("DELETE FROM "+todosTagsTable).
+					WithArgs(id, id, id).
+					WillReturnRows(rows)
+			},
+			todoID:    id,
+			tagID:     id,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "not attached",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"todo_id"})
+				m.ExpectQuery("DELETE FROM "+todosTagsTable).
+					WithArgs(id, id, 0).
+					WillReturnRows(rows)
+			},
+			todoID:    id,
+			tagID:     0,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.DetachFromTodo(context.Background(), id, tt.todoID, tt.tagID)
+		tt.errAssert(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
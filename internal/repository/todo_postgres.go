@@ -3,14 +3,50 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/lib/pq"
+)
+
+const (
+	// Titles are short, so they are highlighted as a whole, while descriptions
+	// are cut down to the fragments around the matches.
+	titleHeadlineOptions       = "StartSel=<mark>, StopSel=</mark>, HighlightAll=TRUE"
+	descriptionHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5"
+	// todoUserIDs lists every user the todo is shared with, the owner included.
+	todoUserIDs = "ARRAY(SELECT user_id FROM " + usersTodosTable + " WHERE todo_id = td.id) AS user_ids"
+	// todoNotTrashed keeps the rows of users_todos, aliased ut, whose todos
+	// are not in the trash, for queries that do not join the todos themselves.
+	todoNotTrashed = "NOT EXISTS (SELECT 1 FROM " + todosTable + " WHERE id = ut.todo_id AND deleted_at IS NOT NULL)"
+	// todoStateColumns are the columns of a todo tracked by its history, and
+	// todoChangeColumns are returned by the statements that change them.
+	todoStateColumns  = "td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, td.deleted_at"
+	todoChangeColumns = "td.id, " + todoStateColumns + ", " + todoUserIDs
+)
+
+var (
+	canEditTodo = fmt.Sprintf("ut.role IN ('%s', '%s')", core.RoleOwner, core.RoleEditor)
+	ownsTodo    = fmt.Sprintf("ut.role = '%s'", core.RoleOwner)
+
+	todoSortColumns = map[core.TodoSortField]string{
+		core.SortByCreatedAt: "td.created_at",
+		core.SortByUpdatedAt: "td.updated_at",
+		core.SortByPriority:  "td.priority",
+		core.SortByDueAt:     "td.due_at",
+	}
 )
 
 type TodoPostgres struct {
 	db *sql.DB
+	// tx is set on the repositories passed to InTransaction, which then nest
+	// their own transactions in savepoints.
+	tx         *sql.Tx
+	savepoints int
 }
 
 func NewTodoPostgres(db *sql.DB) *TodoPostgres {
@@ -19,56 +55,73 @@ func NewTodoPostgres(db *sql.DB) *TodoPostgres {
 	}
 }
 
-func (r *TodoPostgres) Create(ctx context.Context, userID uint, todo core.Todo) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+func (r *TodoPostgres) Create(ctx context.Context, userID uint, todo core.Todo) (uint, error) {
+	tx, err := r.begin(ctx)
 	if err != nil {
-		return fmt.Errorf("could not begin transaction: %s", err.Error())
+		return 0, fmt.Errorf("could not begin transaction: %s", err.Error())
 	}
 	defer tx.Rollback()
 
 	query := fmt.Sprintf(`
-INSERT INTO %s (title, description, completed)
-VALUES ($1, $2, $3)
+INSERT INTO %s (title, description, completed, priority, due_at, auto_complete, recurrence)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING id;
 	`, todosTable)
 
 	var todoID uint
-	row := tx.QueryRowContext(ctx, query, todo.Title, todo.Description, todo.Completed)
+	row := tx.QueryRowContext(ctx, query,
+		todo.Title, todo.Description, todo.Completed, todo.Priority, todo.DueAt, todo.AutoComplete,
+		todo.Recurrence)
 	if err := row.Scan(&todoID); err != nil {
-		return fmt.Errorf("could not scan row: %s", err.Error())
+		return 0, fmt.Errorf("could not scan row: %s", err.Error())
 	}
 
 	query = fmt.Sprintf(`
-INSERT INTO %s (user_id, todo_id)
-VALUES ($1, $2);
-	`, usersTodosTable)
+INSERT INTO %s (user_id, todo_id, project_id)
+SELECT $1, $2, $3::INTEGER
+WHERE $3::INTEGER IS NULL
+    OR EXISTS (SELECT 1 FROM %s WHERE id = $3::INTEGER AND user_id = $1);
+	`, usersTodosTable, projectsTable)
 
-	if _, err := tx.ExecContext(ctx, query, userID, todoID); err != nil {
-		return fmt.Errorf("could not execute query: %s", err.Error())
+	result, err := tx.ExecContext(ctx, query, userID, todoID, todo.ProjectID)
+	if err != nil {
+		return 0, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return 0, ErrNotFound
+	}
+
+	if len(todo.Tags) != 0 {
+		if err := tagTodo(ctx, tx, userID, todoID, todo.Tags); err != nil {
+			return 0, err
+		}
+	}
+
+	todo.ID = todoID
+	if err := recordTodoEvent(ctx, tx, userID, core.TodoCreated, core.Todo{}, todo); err != nil {
+		return 0, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("could not commit transaction: %s", err.Error())
+		return 0, fmt.Errorf("could not commit transaction: %s", err.Error())
 	}
 
-	return nil
+	return todoID, nil
 }
 
 func (r *TodoPostgres) GetByID(ctx context.Context, userID uint, todoID uint) (core.Todo, error) {
 	query := fmt.Sprintf(`
-SELECT td.id, td.title, td.description, td.completed, td.created_at, td.updated_at
+SELECT %s
 FROM %s td
-INNER JOIN (SELECT todo_id FROM %s WHERE user_id = $1) AS ut
+INNER JOIN (SELECT todo_id, project_id FROM %s WHERE user_id = $1) AS ut
 ON ut.todo_id = td.id
 WHERE td.id = $2
+    AND td.deleted_at IS NULL
 LIMIT 1;
-`, todosTable, usersTodosTable)
-
-	var todo core.Todo
-	row := r.db.QueryRowContext(ctx, query, userID, todoID)
-	err := row.Scan(
-		&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.CreatedAt, &todo.UpdatedAt)
+`, todoColumns(1), todosTable, usersTodosTable)
 
+	row := r.conn().QueryRowContext(ctx, query, userID, todoID)
+	todo, err := scanTodo(row)
 	if err != nil {
 		return core.Todo{}, fmt.Errorf("could not scan row: %s", err.Error())
 	}
@@ -76,179 +129,1161 @@ LIMIT 1;
 	return todo, nil
 }
 
-func (r *TodoPostgres) GetByCompletion(ctx context.Context, userID uint, completed bool) ([]core.Todo, error) {
+func (r *TodoPostgres) GetByCompletion(ctx context.Context, userID uint, completed bool,
+	sort core.TodoSort, page core.TodoPage) ([]core.Todo, error) {
+
+	orderBy, err := todoOrderBy(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	whereStatements := []string{"td.completed = $2", "td.deleted_at IS NULL"}
+
+	seekQuery, args, err := todoSeek(sort, page, []any{userID, completed})
+	if err != nil {
+		return nil, err
+	}
+	if len(seekQuery) != 0 {
+		whereStatements = append(whereStatements, seekQuery)
+	}
+
 	query := fmt.Sprintf(`
-SELECT td.id, td.title, td.description, td.completed, td.created_at, td.updated_at
+SELECT %s
 FROM %s td
-INNER JOIN (SELECT todo_id FROM %s WHERE user_id = $1) AS ut
+INNER JOIN (SELECT todo_id, project_id FROM %s WHERE user_id = $1) AS ut
 ON ut.todo_id = td.id
-WHERE td.completed = $2
-`, todosTable, usersTodosTable)
+WHERE %s
+ORDER BY %s
+LIMIT $%d;
+`, todoColumns(1), todosTable, usersTodosTable, strings.Join(whereStatements, " AND "), orderBy, len(args)+1)
 
-	rows, err := r.db.QueryContext(ctx, query, userID, completed)
+	rows, err := r.conn().QueryContext(ctx, query, append(args, page.Limit)...)
 	if err != nil {
 		return nil, fmt.Errorf("could not execute query: %s", err.Error())
 	}
 
-	var todos []core.Todo
-	for rows.Next() {
-		var todo core.Todo
-		err := rows.Scan(
-			&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.CreatedAt, &todo.UpdatedAt)
-		if err != nil {
-			return nil, fmt.Errorf("could not scan row: %s", err.Error())
+	return scanTodos(rows)
+}
+
+func (r *TodoPostgres) GetAll(ctx context.Context, userID uint, filter core.TodoFilter,
+	sort core.TodoSort, page core.TodoPage) ([]core.Todo, error) {
+
+	orderBy, err := todoOrderBy(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	whereStatements := []string{"td.deleted_at IS NULL"}
+	args := []any{userID}
+	argID := 2
+
+	if len(filter.Tags) != 0 {
+		tagQuery := fmt.Sprintf(`SELECT COUNT(*)
+    FROM %s tt
+    INNER JOIN %s tg
+    ON tg.id = tt.tag_id
+    WHERE tt.todo_id = td.id
+        AND tg.user_id = $1
+        AND tg.name = ANY($%d)`, todosTagsTable, tagsTable, argID)
+		args = append(args, pq.Array(filter.Tags))
+		argID++
+
+		switch filter.TagMatch {
+		case core.MatchAnyTag:
+			whereStatements = append(whereStatements, fmt.Sprintf("(%s) > 0", tagQuery))
+		case core.MatchAllTags:
+			whereStatements = append(whereStatements, fmt.Sprintf("(%s) = $%d", tagQuery, argID))
+			args = append(args, len(filter.Tags))
+			argID++
+		default:
+			return nil, fmt.Errorf("could not match tags by %q", filter.TagMatch)
 		}
-		todos = append(todos, todo)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("could not iterate through rows: %s", err.Error())
+	seekQuery, args, err := todoSeek(sort, page, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(seekQuery) != 0 {
+		whereStatements = append(whereStatements, seekQuery)
 	}
 
-	return todos, nil
+	query := fmt.Sprintf(`
+SELECT %s
+FROM %s td
+INNER JOIN (SELECT todo_id, project_id FROM %s WHERE user_id = $1) AS ut
+ON ut.todo_id = td.id
+WHERE %s
+ORDER BY %s
+LIMIT $%d;
+`, todoColumns(1), todosTable, usersTodosTable, strings.Join(whereStatements, " AND "), orderBy, len(args)+1)
+	args = append(args, page.Limit)
+
+	rows, err := r.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return scanTodos(rows)
+}
+
+func (r *TodoPostgres) GetByProject(ctx context.Context, userID uint, projectID uint,
+	sort core.TodoSort) ([]core.Todo, error) {
+
+	orderBy, err := todoOrderBy(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+SELECT %s
+FROM %s td
+INNER JOIN (SELECT todo_id, project_id FROM %s WHERE user_id = $1) AS ut
+ON ut.todo_id = td.id
+WHERE ut.project_id = $2
+    AND td.deleted_at IS NULL
+ORDER BY %s;
+`, todoColumns(1), todosTable, usersTodosTable, orderBy)
+
+	rows, err := r.conn().QueryContext(ctx, query, userID, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return scanTodos(rows)
 }
 
-func (r *TodoPostgres) GetAll(ctx context.Context, userID uint) ([]core.Todo, error) {
+func (r *TodoPostgres) GetOverdue(ctx context.Context, userID uint, now time.Time) ([]core.Todo, error) {
 	query := fmt.Sprintf(`
-SELECT td.id, td.title, td.description, td.completed, td.created_at, td.updated_at
+SELECT %s
 FROM %s td
-INNER JOIN (SELECT todo_id FROM %s WHERE user_id = $1) AS ut
-ON ut.todo_id = td.id;
-`, todosTable, usersTodosTable)
+INNER JOIN (SELECT todo_id, project_id FROM %s WHERE user_id = $1) AS ut
+ON ut.todo_id = td.id
+WHERE td.completed = FALSE
+    AND td.deleted_at IS NULL
+    AND td.due_at < $2
+ORDER BY td.due_at, td.id;
+`, todoColumns(1), todosTable, usersTodosTable)
 
-	rows, err := r.db.QueryContext(ctx, query, userID)
+	rows, err := r.conn().QueryContext(ctx, query, userID, now)
 	if err != nil {
 		return nil, fmt.Errorf("could not execute query: %s", err.Error())
 	}
 
-	var todos []core.Todo
+	return scanTodos(rows)
+}
+
+func (r *TodoPostgres) GetDueBetween(ctx context.Context, userID uint, from, to time.Time) ([]core.Todo, error) {
+	query := fmt.Sprintf(`
+SELECT %s
+FROM %s td
+INNER JOIN (SELECT todo_id, project_id FROM %s WHERE user_id = $1) AS ut
+ON ut.todo_id = td.id
+WHERE td.completed = FALSE
+    AND td.deleted_at IS NULL
+    AND td.due_at >= $2
+    AND td.due_at < $3
+ORDER BY td.due_at, td.id;
+`, todoColumns(1), todosTable, usersTodosTable)
+
+	rows, err := r.conn().QueryContext(ctx, query, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return scanTodos(rows)
+}
+
+// Search matches the query in websearch syntax and highlights the matches.
+func (r *TodoPostgres) Search(ctx context.Context, userID uint, query string,
+	limit uint) ([]core.TodoSearchResult, error) {
+
+	searchQuery := fmt.Sprintf(`
+SELECT %s,
+    ts_rank(td.search, query) AS rank,
+    ts_headline('english', %s, query, '%s') AS title_snippet,
+    ts_headline('english', %s, query, '%s') AS description_snippet
+FROM %s td
+INNER JOIN (SELECT todo_id, project_id FROM %s WHERE user_id = $1) AS ut
+ON ut.todo_id = td.id
+CROSS JOIN websearch_to_tsquery('english', $2) AS query
+WHERE td.search @@ query
+    AND td.deleted_at IS NULL
+ORDER BY rank DESC, td.id DESC
+LIMIT $3;
+`, todoColumns(1), escapeHTML("td.title"), titleHeadlineOptions, escapeHTML("td.description"),
+		descriptionHeadlineOptions, todosTable, usersTodosTable)
+
+	rows, err := r.conn().QueryContext(ctx, searchQuery, userID, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var results []core.TodoSearchResult
 	for rows.Next() {
-		var todo core.Todo
-		err := rows.Scan(
-			&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.CreatedAt, &todo.UpdatedAt)
-		if err != nil {
+		var result core.TodoSearchResult
+		dest := append(todoFields(&result.Todo), &result.Rank, &result.TitleSnippet, &result.DescriptionSnippet)
+		if err := rows.Scan(dest...); err != nil {
 			return nil, fmt.Errorf("could not scan row: %s", err.Error())
 		}
-		todos = append(todos, todo)
+		results = append(results, result)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("could not scan rows: %s", err.Error())
+		return nil, fmt.Errorf("could not iterate through rows: %s", err.Error())
 	}
 
-	return todos, nil
+	return results, nil
 }
 
-func (r *TodoPostgres) UpdateByID(ctx context.Context, userID uint, todoID uint, todo core.Todo) (uint, error) {
+// UpdateByID replaces the todo, provided that it is still at todo.Version
+// unless the version is zero. The same goes for PatchByID.
+func (r *TodoPostgres) UpdateByID(ctx context.Context, userID uint, todoID uint, todo core.Todo) ([]uint, error) {
 	query := fmt.Sprintf(`
+WITH ut AS (%s)
 UPDATE %s td
 SET title = $1,
     description = $2,
-    completed = $3
-FROM %s ut
-WHERE ut.user_id = $4
-    AND ut.todo_id = td.id
-    AND td.id = $5
-RETURNING id;
-`, todosTable, usersTodosTable)
-
-	var id uint
-	row := r.db.QueryRowContext(ctx, query, todo.Title, todo.Description, todo.Completed, userID, todoID)
-	if err := row.Scan(&id); err != nil {
-		return 0, fmt.Errorf("could not scan row: %s", err.Error())
-	}
+    completed = $3,
+    priority = $4,
+    due_at = $5,
+    auto_complete = $6,
+    recurrence = $7
+FROM ut
+WHERE ut.todo_id = td.id
+    AND %s
+RETURNING %s;
+`, moveTodoQuery(8, 9, 10), todosTable, todoVersionMatches(11), todoChangeColumns)
 
-	return id, nil
+	return r.changeTodo(ctx, userID, todoID, todo.Version, core.TodoUpdated, query,
+		todo.Title, todo.Description, todo.Completed, todo.Priority, todo.DueAt, todo.AutoComplete,
+		todo.Recurrence, todo.ProjectID, userID, todoID, todo.Version)
 }
 
-func (r *TodoPostgres) PatchByID(ctx context.Context, userID uint, todoID uint, todo core.Todo) (uint, error) {
+// PatchByID changes only the fields set in the patch.
+func (r *TodoPostgres) PatchByID(ctx context.Context, userID uint, todoID uint, patch core.TodoPatch) ([]uint, error) {
 	setStatements := make([]string, 0)
 	args := make([]any, 0)
 	argID := 1
 
-	if len(todo.Title) != 0 {
-		setStatements = append(setStatements, fmt.Sprintf("title = $%d", argID))
-		args = append(args, todo.Title)
+	set := func(column string, value any) {
+		setStatements = append(setStatements, fmt.Sprintf("%s = $%d", column, argID))
+		args = append(args, value)
 		argID++
 	}
-	if len(todo.Description) != 0 {
-		setStatements = append(setStatements, fmt.Sprintf("description = $%d", argID))
-		args = append(args, todo.Description)
-		argID++
+
+	if patch.Title.Set {
+		set("title", patch.Title.Value)
+	}
+	if patch.Description.Set {
+		set("description", patch.Description.Value)
+	}
+	if patch.Completed.Set {
+		set("completed", patch.Completed.Value)
+	}
+	if patch.Priority.Set {
+		set("priority", patch.Priority.Value)
+	}
+	if patch.DueAt.Set {
+		set("due_at", patch.DueAt.Value)
+	}
+	if patch.AutoComplete.Set {
+		set("auto_complete", patch.AutoComplete.Value)
+	}
+	if patch.Recurrence.Set {
+		set("recurrence", patch.Recurrence.Value)
+	}
+
+	// An empty patch still checks access and the version.
+	if len(setStatements) == 0 {
+		setStatements = append(setStatements, "version = td.version")
 	}
-	setStatements = append(setStatements, fmt.Sprintf("completed = $%d", argID))
-	args = append(args, todo.Completed)
-	argID++
 
 	setQuery := strings.Join(setStatements, ", ")
 
-	query := fmt.Sprintf(`
+	var query string
+	if patch.ProjectID.Set {
+		query = fmt.Sprintf(`
+WITH ut AS (%s)
+UPDATE %s td
+SET %s
+FROM ut
+WHERE ut.todo_id = td.id
+    AND %s
+RETURNING %s;
+`, moveTodoQuery(argID, argID+1, argID+2), todosTable, setQuery, todoVersionMatches(argID+3),
+			todoChangeColumns)
+
+		args = append(args, patch.ProjectID.Value)
+	} else {
+		query = fmt.Sprintf(`
 UPDATE %s td
 SET %s
 FROM %s ut
 WHERE ut.user_id = $%d
     AND ut.todo_id = td.id
     AND td.id = $%d
-RETURNING id;
-`, todosTable, setQuery, usersTodosTable, argID, argID+1)
-
-	args = append(args, userID, todoID)
-
-	var id uint
-	row := r.db.QueryRowContext(ctx, query, args...)
-	if err := row.Scan(&id); err != nil {
-		return 0, fmt.Errorf("could not scan row: %s", err.Error())
+    AND td.deleted_at IS NULL
+    AND %s
+    AND %s
+RETURNING %s;
+`, todosTable, setQuery, usersTodosTable, argID, argID+1, canEditTodo, todoVersionMatches(argID+2),
+			todoChangeColumns)
 	}
 
-	return id, nil
+	args = append(args, userID, todoID, patch.Version)
+
+	return r.changeTodo(ctx, userID, todoID, patch.Version, core.TodoUpdated, query, args...)
 }
 
-func (r *TodoPostgres) DeleteByID(ctx context.Context, userID uint, todoID uint) (uint, error) {
+func (r *TodoPostgres) DeleteByID(ctx context.Context, userID uint, todoID uint, version uint) ([]uint, error) {
 	query := fmt.Sprintf(`
-DELETE FROM %s td
-USING %s ut
+UPDATE %s td
+SET deleted_at = NOW()
+FROM %s ut
 WHERE ut.user_id = $1
     AND ut.todo_id = td.id
     AND td.id = $2
-RETURNING id;
-`, todosTable, usersTodosTable)
-
-	var id uint
-	row := r.db.QueryRowContext(ctx, query, userID, todoID)
-	if err := row.Scan(&id); err != nil {
-		return 0, fmt.Errorf("could not scan row: %s", err.Error())
-	}
+    AND td.deleted_at IS NULL
+    AND %s
+    AND %s
+RETURNING %s;
+`, todosTable, usersTodosTable, ownsTodo, todoVersionMatches(3), todoChangeColumns)
 
-	return id, nil
+	return r.changeTodo(ctx, userID, todoID, version, core.TodoDeleted, query, userID, todoID, version)
 }
 
 func (r *TodoPostgres) DeleteByCompletion(ctx context.Context, userID uint, completed bool) ([]uint, error) {
 	query := fmt.Sprintf(`
-DELETE FROM %s td
-USING %s ut
+UPDATE %s td
+SET deleted_at = NOW()
+FROM %s ut
 WHERE ut.user_id = $1
     AND ut.todo_id = td.id
     AND td.completed = $2
-RETURNING id;
-`, todosTable, usersTodosTable)
+    AND td.deleted_at IS NULL
+    AND %s
+RETURNING %s;
+`, todosTable, usersTodosTable, ownsTodo, todoChangeColumns)
+
+	tx, err := r.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, userID, completed)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	userIDs, err := recordTrashEvents(ctx, tx, userID, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return append([]uint{userID}, userIDs...), nil
+}
+
+// Export calls fn with every todo the user has access to, outside the trash,
+// as the rows are read, so that they are never all held in memory.
+func (r *TodoPostgres) Export(ctx context.Context, userID uint, fn func(todo core.Todo) error) error {
+	query := fmt.Sprintf(`
+SELECT %s
+FROM %s td
+INNER JOIN %s ut
+ON ut.todo_id = td.id
+WHERE ut.user_id = $1
+    AND td.deleted_at IS NULL
+ORDER BY td.id;
+`, todoColumns(1), todosTable, usersTodosTable)
+
+	rows, err := r.conn().QueryContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return fmt.Errorf("could not scan row: %s", err.Error())
+		}
+		if err := fn(todo); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("could not iterate through rows: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (r *TodoPostgres) ExportCalendar(ctx context.Context, userID uint,
+	fn func(obj core.CalendarObject) error) error {
+
+	query := fmt.Sprintf(`
+SELECT %s, COALESCE(ut.calendar_name, ''), COALESCE(ut.calendar_uid, '')
+FROM %s td
+INNER JOIN %s ut
+ON ut.todo_id = td.id
+WHERE ut.user_id = $1
+    AND td.deleted_at IS NULL
+ORDER BY td.id;
+`, todoColumns(1), todosTable, usersTodosTable)
+
+	rows, err := r.conn().QueryContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var obj core.CalendarObject
+		if err := rows.Scan(append(todoFields(&obj.Todo), &obj.Name, &obj.UID)...); err != nil {
+			return fmt.Errorf("could not scan row: %s", err.Error())
+		}
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("could not iterate through rows: %s", err.Error())
+	}
+
+	return nil
+}
+
+// GetCalendarObject finds the todo by the resource name it was created with
+// over CalDAV, or by its id when it was created otherwise.
+func (r *TodoPostgres) GetCalendarObject(ctx context.Context, userID uint, name string,
+	todoID uint) (core.CalendarObject, error) {
+
+	query := fmt.Sprintf(`
+SELECT %s, COALESCE(ut.calendar_name, ''), COALESCE(ut.calendar_uid, '')
+FROM %s td
+INNER JOIN %s ut
+ON ut.todo_id = td.id
+WHERE ut.user_id = $1
+    AND td.deleted_at IS NULL
+    AND (ut.calendar_name = $2 OR (ut.calendar_name IS NULL AND td.id = $3))
+LIMIT 1;
+`, todoColumns(1), todosTable, usersTodosTable)
+
+	var obj core.CalendarObject
+	row := r.conn().QueryRowContext(ctx, query, userID, name, todoID)
+	if err := row.Scan(append(todoFields(&obj.Todo), &obj.Name, &obj.UID)...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return core.CalendarObject{}, ErrNotFound
+		}
+		return core.CalendarObject{}, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return obj, nil
+}
+
+func (r *TodoPostgres) SetCalendarName(ctx context.Context, userID uint, todoID uint, name, uid string) error {
+	query := fmt.Sprintf(`
+UPDATE %s
+SET calendar_name = $3,
+    calendar_uid = $4
+WHERE user_id = $1
+    AND todo_id = $2;
+`, usersTodosTable)
+
+	result, err := r.conn().ExecContext(ctx, query, userID, todoID, name, uid)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrAlreadyExists
+		}
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
 
-	rows, err := r.db.QueryContext(ctx, query, userID, completed)
+func (r *TodoPostgres) GetTrash(ctx context.Context, userID uint) ([]core.Todo, error) {
+	query := fmt.Sprintf(`
+SELECT %s, td.deleted_at
+FROM %s td
+INNER JOIN %s ut
+ON ut.todo_id = td.id
+WHERE ut.user_id = $1
+    AND td.deleted_at IS NOT NULL
+    AND %s
+ORDER BY td.deleted_at DESC, td.id DESC;
+`, todoColumns(1), todosTable, usersTodosTable, ownsTodo)
+
+	rows, err := r.conn().QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("could not execute query: %s", err.Error())
 	}
+	defer rows.Close()
 
-	var ids []uint
+	var todos []core.Todo
 	for rows.Next() {
-		var id uint
-		if err := rows.Scan(&id); err != nil {
-			return nil, fmt.Errorf("could not scan row")
+		var todo core.Todo
+		if err := rows.Scan(append(todoFields(&todo), &todo.DeletedAt)...); err != nil {
+			return nil, fmt.Errorf("could not scan row: %s", err.Error())
 		}
-		ids = append(ids, id)
+		todos = append(todos, todo)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("could not scan rows: %s", err.Error())
+		return nil, fmt.Errorf("could not iterate through rows: %s", err.Error())
+	}
+
+	return todos, nil
+}
+
+func (r *TodoPostgres) Restore(ctx context.Context, userID uint, todoID uint) ([]uint, error) {
+	query := fmt.Sprintf(`
+UPDATE %s td
+SET deleted_at = NULL
+FROM %s ut
+WHERE ut.user_id = $1
+    AND ut.todo_id = td.id
+    AND td.id = $2
+    AND td.deleted_at IS NOT NULL
+    AND %s
+RETURNING %s;
+`, todosTable, usersTodosTable, ownsTodo, todoChangeColumns)
+
+	return r.changeTodo(ctx, userID, todoID, 0, core.TodoRestored, query, userID, todoID)
+}
+
+func (r *TodoPostgres) EmptyTrash(ctx context.Context, userID uint) error {
+	query := fmt.Sprintf(`
+DELETE FROM %s td
+USING %s ut
+WHERE ut.user_id = $1
+    AND ut.todo_id = td.id
+    AND td.deleted_at IS NOT NULL
+    AND %s;
+`, todosTable, usersTodosTable, ownsTodo)
+
+	if _, err := r.conn().ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (r *TodoPostgres) Purge(ctx context.Context, before time.Time) ([]uint, error) {
+	query := fmt.Sprintf(`
+DELETE FROM %s td
+WHERE td.deleted_at < $1
+RETURNING %s;
+`, todosTable, todoUserIDs)
+
+	rows, err := r.conn().QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var userIDs []uint
+	for rows.Next() {
+		ids, err := scanUserIDs(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan row: %s", err.Error())
+		}
+		userIDs = append(userIDs, ids...)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not iterate through rows: %s", err.Error())
+	}
+
+	return userIDs, nil
+}
+
+func (r *TodoPostgres) Share(ctx context.Context, ownerID uint, todoID uint, username string,
+	role core.Role) (uint, error) {
+
+	query := fmt.Sprintf(`
+INSERT INTO %s (user_id, todo_id, role)
+SELECT us.id, ut.todo_id, $4
+FROM %s us
+INNER JOIN %s ut
+ON ut.user_id = $1
+    AND ut.todo_id = $2
+    AND %s
+    AND %s
+WHERE us.username = $3
+ON CONFLICT (user_id, todo_id) DO UPDATE
+SET role = EXCLUDED.role
+WHERE %s.role <> '%s'
+RETURNING user_id;
+`, usersTodosTable, usersTable, usersTodosTable, ownsTodo, todoNotTrashed, usersTodosTable, core.RoleOwner)
+
+	var collaboratorID uint
+	row := r.conn().QueryRowContext(ctx, query, ownerID, todoID, username, role)
+	if err := row.Scan(&collaboratorID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNotFound
+		}
+		return 0, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return collaboratorID, nil
+}
+
+func (r *TodoPostgres) GetCollaborators(ctx context.Context, userID uint, todoID uint) ([]core.Collaborator, error) {
+	query := fmt.Sprintf(`
+SELECT us.id, us.username, ut.role
+FROM %s ut
+INNER JOIN %s us
+ON us.id = ut.user_id
+WHERE ut.todo_id = $2
+    AND EXISTS (SELECT 1 FROM %s WHERE user_id = $1 AND todo_id = $2)
+    AND %s
+ORDER BY ut.role = '%s' DESC, us.username;
+`, usersTodosTable, usersTable, usersTodosTable, todoNotTrashed, core.RoleOwner)
+
+	rows, err := r.conn().QueryContext(ctx, query, userID, todoID)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var collaborators []core.Collaborator
+	for rows.Next() {
+		var collaborator core.Collaborator
+		if err := rows.Scan(&collaborator.UserID, &collaborator.Username, &collaborator.Role); err != nil {
+			return nil, fmt.Errorf("could not scan row: %s", err.Error())
+		}
+		collaborators = append(collaborators, collaborator)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not iterate through rows: %s", err.Error())
+	}
+
+	return collaborators, nil
+}
+
+// RevokeAccess lets the owner remove any collaborator and a collaborator
+// remove themselves. The owner's own access cannot be revoked.
+func (r *TodoPostgres) RevokeAccess(ctx context.Context, userID uint, todoID uint, collaboratorID uint) error {
+	query := fmt.Sprintf(`
+DELETE FROM %s cl
+WHERE cl.todo_id = $2
+    AND cl.user_id = $3
+    AND cl.role <> '%s'
+    AND ($1::INTEGER = $3::INTEGER OR EXISTS (
+        SELECT 1 FROM %s ut WHERE ut.user_id = $1 AND ut.todo_id = $2 AND %s))
+RETURNING cl.user_id;
+`, usersTodosTable, core.RoleOwner, usersTodosTable, ownsTodo)
+
+	var id uint
+	row := r.conn().QueryRowContext(ctx, query, userID, todoID, collaboratorID)
+	if err := row.Scan(&id); err != nil {
+		return fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return nil
+}
+
+// GetHistory lists the events of a todo the user has access to, the latest
+// first. The page cursor is the id of the last event of the previous page.
+func (r *TodoPostgres) GetHistory(ctx context.Context, userID uint, todoID uint,
+	page core.TodoPage) ([]core.TodoEvent, error) {
+
+	query := fmt.Sprintf(`
+SELECT EXISTS (
+    SELECT 1
+    FROM %s ut
+    WHERE ut.user_id = $1
+        AND ut.todo_id = $2
+        AND %s
+);
+`, usersTodosTable, todoNotTrashed)
+
+	var exists bool
+	if err := r.conn().QueryRowContext(ctx, query, userID, todoID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	args := []any{todoID}
+	seek := ""
+	if page.After != 0 {
+		args = append(args, page.After)
+		seek = fmt.Sprintf("AND ev.id < $%d", len(args))
+	}
+	args = append(args, page.Limit)
+
+	query = fmt.Sprintf(`
+SELECT ev.id, ev.todo_id, ev.user_id, us.username, ev.action, ev.changes, ev.created_at
+FROM %s ev
+INNER JOIN %s us
+ON us.id = ev.user_id
+WHERE ev.todo_id = $1
+    %s
+ORDER BY ev.id DESC
+LIMIT $%d;
+`, todoEventsTable, usersTable, seek, len(args))
+
+	rows, err := r.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var events []core.TodoEvent
+	for rows.Next() {
+		var (
+			event   core.TodoEvent
+			changes []byte
+		)
+		err := rows.Scan(&event.ID, &event.TodoID, &event.UserID, &event.Username, &event.Action, &changes,
+			&event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan row: %s", err.Error())
+		}
+		if err := json.Unmarshal(changes, &event.Changes); err != nil {
+			return nil, fmt.Errorf("could not unmarshal changes: %s", err.Error())
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not iterate through rows: %s", err.Error())
+	}
+
+	return events, nil
+}
+
+// InTransaction makes each change within its own savepoint, so one that
+// fails can be left out without aborting the rest.
+func (r *TodoPostgres) InTransaction(ctx context.Context, fn func(repo TodoRepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	if err := fn(&TodoPostgres{db: r.db, tx: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return nil
+}
+
+// changeTodo locks the todo, runs a statement returning todoChangeColumns
+// and records the change. A non-zero version is checked before.
+func (r *TodoPostgres) changeTodo(ctx context.Context, userID uint, todoID uint, version uint,
+	action core.TodoAction, query string, args ...any) ([]uint, error) {
+
+	tx, err := r.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	lockQuery := fmt.Sprintf(`
+SELECT %s
+FROM %s td
+INNER JOIN %s ut
+ON ut.todo_id = td.id
+WHERE td.id = $1
+    AND ut.user_id = $2
+FOR UPDATE OF td;
+`, todoStateColumns, todosTable, usersTodosTable)
+
+	var before core.Todo
+	row := tx.QueryRowContext(ctx, lockQuery, todoID, userID)
+	if err := row.Scan(todoStateFields(&before)...); err != nil {
+		return nil, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	userIDs, after, err := scanTodoChange(tx.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) && version != 0 {
+		mismatch, err := hasOtherVersion(ctx, tx, userID, todoID, version)
+		if err != nil {
+			return nil, err
+		}
+		if mismatch {
+			return nil, ErrVersionMismatch
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	if err := recordTodoEvent(ctx, tx, userID, action, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return userIDs, nil
+}
+
+func hasOtherVersion(ctx context.Context, tx querier, userID uint, todoID uint, version uint) (bool, error) {
+	query := fmt.Sprintf(`
+SELECT td.version
+FROM %s td
+INNER JOIN %s ut
+ON ut.todo_id = td.id
+WHERE ut.user_id = $1
+    AND td.id = $2
+    AND td.deleted_at IS NULL;
+`, todosTable, usersTodosTable)
+
+	var current uint
+	err := tx.QueryRowContext(ctx, query, userID, todoID).Scan(&current)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return current != version, nil
+}
+
+// recordTrashEvents records the deletion of the todos returned by a statement
+// that moves them to the trash, and returns the users they are shared with.
+func recordTrashEvents(ctx context.Context, tx querier, userID uint, rows *sql.Rows) ([]uint, error) {
+	var (
+		userIDs []uint
+		todos   []core.Todo
+	)
+
+	for rows.Next() {
+		ids, todo, err := scanTodoChange(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("could not scan row: %s", err.Error())
+		}
+		userIDs = append(userIDs, ids...)
+		todos = append(todos, todo)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not iterate through rows: %s", err.Error())
+	}
+
+	for _, todo := range todos {
+		before := todo
+		before.DeletedAt = nil
+		if err := recordTodoEvent(ctx, tx, userID, core.TodoDeleted, before, todo); err != nil {
+			return nil, err
+		}
+	}
+
+	return userIDs, nil
+}
+
+// recordTodoEvent appends the change the user made to the history of the
+// todo. Changes that leave every tracked field as it was are not recorded.
+func recordTodoEvent(ctx context.Context, tx querier, userID uint, action core.TodoAction,
+	before, after core.Todo) error {
+
+	changes := core.DiffTodos(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("could not marshal changes: %s", err.Error())
+	}
+
+	query := fmt.Sprintf(`
+INSERT INTO %s (todo_id, user_id, action, changes)
+VALUES ($1, $2, $3, $4);
+`, todoEventsTable)
+
+	if _, err := tx.ExecContext(ctx, query, after.ID, userID, action, string(data)); err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return nil
+}
+
+func tagTodo(ctx context.Context, tx querier, userID uint, todoID uint, names []string) error {
+	query := fmt.Sprintf(`
+WITH names AS (
+    SELECT DISTINCT unnest($2::VARCHAR[]) AS name
+), created AS (
+    INSERT INTO %s (user_id, name)
+    SELECT $1, name FROM names
+    ON CONFLICT (user_id, name) DO NOTHING
+    RETURNING id
+)
+INSERT INTO %s (todo_id, tag_id)
+SELECT $3, id FROM created
+UNION
+SELECT $3, tg.id
+FROM %s tg
+INNER JOIN names
+ON names.name = tg.name
+WHERE tg.user_id = $1;
+`, tagsTable, todosTagsTable, tagsTable)
+
+	if _, err := tx.ExecContext(ctx, query, userID, pq.Array(names), todoID); err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return nil
+}
+
+// moveTodoQuery is a common table expression moving a todo the user can edit
+// to a project they own, or to the inbox when the project is NULL.
+func moveTodoQuery(projectArg, userArg, todoArg int) string {
+	return fmt.Sprintf(`UPDATE %s ut
+    SET project_id = $%d::INTEGER
+    WHERE ut.user_id = $%d
+        AND ut.todo_id = $%d
+        AND %s
+        AND %s
+        AND ($%d::INTEGER IS NULL
+            OR EXISTS (SELECT 1 FROM %s WHERE id = $%d::INTEGER AND user_id = $%d))
+    RETURNING ut.todo_id`,
+		usersTodosTable, projectArg, userArg, todoArg, canEditTodo, todoNotTrashed,
+		projectArg, projectsTable, projectArg, userArg)
+}
+
+// todoColumns lists the columns scanned by todoFields, with the tags of the
+// user whose id is passed as the argument.
+func todoColumns(userArg int) string {
+	return fmt.Sprintf("td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, "+
+		"td.recurrence, ut.project_id, td.created_at, td.updated_at, "+
+		"ARRAY(SELECT tg.name FROM %s tt INNER JOIN %s tg ON tg.id = tt.tag_id "+
+		"WHERE tt.todo_id = td.id AND tg.user_id = $%d ORDER BY tg.name) AS tags, td.version",
+		todosTagsTable, tagsTable, userArg)
+}
+
+// escapeHTML escapes the markup in the text column, so that the snippets
+// highlighted by ts_headline hold no markup but their marks.
+func escapeHTML(column string) string {
+	return fmt.Sprintf(`replace(replace(replace(replace(replace(%s, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), `+
+		`'"', '&quot;'), '''', '&#39;')`, column)
+}
+
+// todoVersionMatches holds when the todo, aliased td, is at the version passed
+// as the argument, or when the argument is zero.
+func todoVersionMatches(versionArg int) string {
+	return fmt.Sprintf("($%d::INTEGER = 0 OR td.version = $%d::INTEGER)", versionArg, versionArg)
+}
+
+// querier is satisfied by *sql.DB, *sql.Tx and todoTx alike.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *TodoPostgres) conn() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+
+	return r.db
+}
+
+type todoTx struct {
+	*sql.Tx
+	savepoint string
+	done      bool
+}
+
+func (r *TodoPostgres) begin(ctx context.Context) (*todoTx, error) {
+	if r.tx == nil {
+		tx, err := r.db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &todoTx{Tx: tx}, nil
+	}
+
+	r.savepoints++
+	savepoint := fmt.Sprintf("todo_%d", r.savepoints)
+	if _, err := r.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return nil, err
+	}
+
+	return &todoTx{Tx: r.tx, savepoint: savepoint}, nil
+}
+
+func (tx *todoTx) Commit() error {
+	if len(tx.savepoint) == 0 {
+		return tx.Tx.Commit()
+	}
+	if tx.done {
+		return sql.ErrTxDone
+	}
+
+	tx.done = true
+	_, err := tx.Exec("RELEASE SAVEPOINT " + tx.savepoint)
+
+	return err
+}
+
+func (tx *todoTx) Rollback() error {
+	if len(tx.savepoint) == 0 {
+		return tx.Tx.Rollback()
+	}
+	if tx.done {
+		return sql.ErrTxDone
+	}
+
+	tx.done = true
+	_, err := tx.Exec("ROLLBACK TO SAVEPOINT " + tx.savepoint)
+
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTodo(row rowScanner) (core.Todo, error) {
+	var todo core.Todo
+	err := row.Scan(todoFields(&todo)...)
+
+	return todo, err
+}
+
+// todoFields returns the scan destinations matching todoColumns.
+func todoFields(todo *core.Todo) []any {
+	return []any{
+		&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.Priority, &todo.DueAt,
+		&todo.AutoComplete, &todo.Recurrence, &todo.ProjectID, &todo.CreatedAt, &todo.UpdatedAt, pq.Array(&todo.Tags),
+		&todo.Version,
+	}
+}
+
+// todoStateFields returns the scan destinations matching todoStateColumns.
+func todoStateFields(todo *core.Todo) []any {
+	return []any{
+		&todo.Title, &todo.Description, &todo.Completed, &todo.Priority, &todo.DueAt, &todo.AutoComplete,
+		&todo.Recurrence, &todo.DeletedAt,
+	}
+}
+
+func scanTodoChange(row rowScanner) ([]uint, core.Todo, error) {
+	var (
+		todo core.Todo
+		ids  []int64
+	)
+
+	fields := append([]any{&todo.ID}, todoStateFields(&todo)...)
+	if err := row.Scan(append(fields, pq.Array(&ids))...); err != nil {
+		return nil, core.Todo{}, err
+	}
+
+	return toUserIDs(ids), todo, nil
+}
+
+func scanUserIDs(row rowScanner) ([]uint, error) {
+	var ids []int64
+	if err := row.Scan(pq.Array(&ids)); err != nil {
+		return nil, err
+	}
+
+	return toUserIDs(ids), nil
+}
+
+func toUserIDs(ids []int64) []uint {
+	userIDs := make([]uint, len(ids))
+	for i, id := range ids {
+		userIDs[i] = uint(id)
+	}
+
+	return userIDs
+}
+
+func scanTodos(rows *sql.Rows) ([]core.Todo, error) {
+	defer rows.Close()
+
+	var todos []core.Todo
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan row: %s", err.Error())
+		}
+		todos = append(todos, todo)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not iterate through rows: %s", err.Error())
+	}
+
+	return todos, nil
+}
+
+// todoSeek returns the condition keeping the todos after the page cursor.
+// todoOrderBy puts NULL values last, so they are only followed by each other.
+func todoSeek(sort core.TodoSort, page core.TodoPage, args []any) (string, []any, error) {
+	if page.After == 0 {
+		return "", args, nil
+	}
+
+	column, ok := todoSortColumns[sort.Field]
+	if !ok {
+		return "", nil, fmt.Errorf("could not sort by %q", sort.Field)
+	}
+
+	operator := ">"
+	if sort.Descending {
+		operator = "<"
+	}
+
+	if page.AfterNull {
+		args = append(args, page.After)
+		return fmt.Sprintf("(%s IS NULL AND td.id %s $%d)", column, operator, len(args)), args, nil
+	}
+
+	var value any = time.UnixMicro(page.AfterValue).UTC()
+	if sort.Field == core.SortByPriority {
+		value = page.AfterValue
+	}
+
+	args = append(args, value, page.After)
+	valueArg, idArg := len(args)-1, len(args)
+
+	return fmt.Sprintf("(%s %s $%d OR (%s = $%d AND td.id %s $%d) OR %s IS NULL)",
+		column, operator, valueArg, column, valueArg, operator, idArg, column), args, nil
+}
+
+func todoOrderBy(sort core.TodoSort) (string, error) {
+	column, ok := todoSortColumns[sort.Field]
+	if !ok {
+		return "", fmt.Errorf("could not sort by %q", sort.Field)
+	}
+
+	direction := "ASC"
+	if sort.Descending {
+		direction = "DESC"
 	}
 
-	return ids, nil
+	return fmt.Sprintf("%s %s NULLS LAST, td.id %s", column, direction, direction), nil
 }
@@ -2,22 +2,29 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestTodoPostgres_Create(t *testing.T) {
 	const (
-		id          = 1
-		title       = "t"
-		description = "d"
-		completed   = true
+		id           = 1
+		title        = "t"
+		description  = "d"
+		completed    = true
+		priority     = core.PriorityHigh
+		tag          = "tag"
+		autoComplete = true
+		recurrence   = "FREQ=DAILY"
 	)
+	var projectID uint = 2
 
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -29,6 +36,7 @@ func TestTodoPostgres_Create(t *testing.T) {
 		name      string
 		mock      func(m sqlmock.Sqlmock)
 		input     core.Todo
+		want      uint
 		errAssert assert.ErrorAssertionFunc
 	}{
 		{
@@ -39,22 +47,95 @@ func TestTodoPostgres_Create(t *testing.T) {
 				rows := sqlmock.NewRows([]string{"id"}).
 					AddRow(id)
 				m.ExpectQuery("INSERT INTO "+todosTable).
-					WithArgs(title, description, completed).
+					WithArgs(title, description, completed, priority, nil, autoComplete, recurrence).
 					WillReturnRows(rows)
 
 				m.ExpectExec("INSERT INTO "+usersTodosTable).
-					WithArgs(id, id).
+					WithArgs(id, id, nil).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoCreated,
+						`{"autoComplete":{"before":false,"after":true},`+
+							`"completed":{"before":false,"after":true},`+
+							`"description":{"before":"","after":"d"},`+
+							`"priority":{"before":"none","after":"high"},`+
+							`"recurrence":{"before":"","after":"FREQ=DAILY"},`+
+							`"title":{"before":"","after":"t"}}`).
 					WillReturnResult(sqlmock.NewResult(id, 1))
 
 				m.ExpectCommit()
 			},
 			input: core.Todo{
-				Title:       title,
-				Description: description,
-				Completed:   completed,
+				Title:        title,
+				Description:  description,
+				Completed:    completed,
+				Priority:     priority,
+				AutoComplete: autoComplete,
+				Recurrence:   recurrence,
+			},
+			want:      id,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok with tags",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+
+				rows := sqlmock.NewRows([]string{"id"}).
+					AddRow(id)
+				m.ExpectQuery("INSERT INTO "+todosTable).
+					WithArgs(title, "", false, core.PriorityNone, nil, false, "").
+					WillReturnRows(rows)
+
+				m.ExpectExec("INSERT INTO "+usersTodosTable).
+					WithArgs(id, id, nil).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+
+				m.ExpectExec("WITH names AS (.+) INSERT INTO "+tagsTable+"(.+) INSERT INTO "+todosTagsTable).
+					WithArgs(id, `{"tag"}`, id).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoCreated, `{"title":{"before":"","after":"t"}}`).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+
+				m.ExpectCommit()
 			},
+			input:     core.Todo{Title: title, Tags: []string{tag}},
+			want:      id,
 			errAssert: assert.NoError,
 		},
+		{
+			name: "project not found",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+
+				rows := sqlmock.NewRows([]string{"id"}).
+					AddRow(id)
+				m.ExpectQuery("INSERT INTO "+todosTable).
+					WithArgs(title, description, completed, priority, nil, autoComplete, recurrence).
+					WillReturnRows(rows)
+
+				m.ExpectExec("INSERT INTO "+usersTodosTable).
+					WithArgs(id, id, projectID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+
+				m.ExpectRollback()
+			},
+			input: core.Todo{
+				Title:        title,
+				Description:  description,
+				Completed:    completed,
+				Priority:     priority,
+				AutoComplete: autoComplete,
+				Recurrence:   recurrence,
+				ProjectID:    &projectID,
+			},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrNotFound)
+			},
+		},
 		{
 			name: "fail to insert",
 			mock: func(m sqlmock.Sqlmock) {
@@ -62,33 +143,41 @@ func TestTodoPostgres_Create(t *testing.T) {
 
 				rows := sqlmock.NewRows([]string{"id"})
 				m.ExpectQuery("INSERT INTO "+todosTable).
-					WithArgs(title, description, completed).
+					WithArgs(title, description, completed, priority, nil, autoComplete, recurrence).
 					WillReturnRows(rows)
 
 				m.ExpectRollback()
 			},
 			input: core.Todo{
-				Title:       title,
-				Description: description,
-				Completed:   completed,
+				Title:        title,
+				Description:  description,
+				Completed:    completed,
+				Priority:     priority,
+				AutoComplete: autoComplete,
+				Recurrence:   recurrence,
 			},
 			errAssert: assert.Error,
 		},
 	}
 	for _, tt := range tests {
 		tt.mock(mock)
-		err := r.Create(context.Background(), id, tt.input)
+		got, err := r.Create(context.Background(), id, tt.input)
 		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	}
 }
 
 func TestTodoPostgres_GetByID(t *testing.T) {
 	const (
-		id          = 1
-		title       = "t"
-		description = "d"
-		completed   = true
+		id           = 1
+		title        = "t"
+		description  = "d"
+		completed    = true
+		priority     = core.PriorityHigh
+		tag          = "tag"
+		autoComplete = true
+		recurrence   = "FREQ=DAILY"
 	)
 	now := time.Now()
 
@@ -110,21 +199,27 @@ func TestTodoPostgres_GetByID(t *testing.T) {
 			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
 				rows := sqlmock.NewRows([]string{
-					"id", "title", "description", "completed", "created_at", "updated_at"}).
-					AddRow(id, title, description, completed, now, now)
-				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.created_at, td.updated_at FROM "+todosTable).
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"}).
+					AddRow(id, title, description, completed, priority, now, autoComplete, recurrence, nil, now, now, "{"+tag+"}", 1)
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
 					WithArgs(id, id).
 					WillReturnRows(rows)
 			},
 			userID: id,
 			todoID: id,
 			want: core.Todo{
-				ID:          id,
-				Title:       title,
-				Description: description,
-				Completed:   completed,
-				CreatedAt:   now,
-				UpdatedAt:   now,
+				ID:           id,
+				Title:        title,
+				Description:  description,
+				Completed:    completed,
+				Priority:     priority,
+				DueAt:        &now,
+				AutoComplete: autoComplete,
+				Recurrence:   recurrence,
+				Tags:         []string{tag},
+				CreatedAt:    now,
+				UpdatedAt:    now,
+				Version:      1,
 			},
 			errAssert: assert.NoError,
 		},
@@ -132,8 +227,8 @@ func TestTodoPostgres_GetByID(t *testing.T) {
 			name: "no user",
 			mock: func(m sqlmock.Sqlmock) {
 				rows := sqlmock.NewRows([]string{
-					"id", "title", "description", "completed", "created_at", "updated_at"})
-				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.created_at, td.updated_at FROM "+todosTable).
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"})
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
 					WithArgs(0, id).
 					WillReturnRows(rows)
 			},
@@ -146,8 +241,8 @@ func TestTodoPostgres_GetByID(t *testing.T) {
 			name: "no todo",
 			mock: func(m sqlmock.Sqlmock) {
 				rows := sqlmock.NewRows([]string{
-					"id", "title", "description", "completed", "created_at", "updated_at"})
-				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.created_at, td.updated_at FROM "+todosTable).
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"})
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
 					WithArgs(id, 0).
 					WillReturnRows(rows)
 			},
@@ -168,10 +263,15 @@ func TestTodoPostgres_GetByID(t *testing.T) {
 
 func TestTodoPostgres_GetByCompletion(t *testing.T) {
 	const (
-		id          = 1
-		title       = "t"
-		description = "d"
-		completed   = true
+		id           = 1
+		title        = "t"
+		description  = "d"
+		completed    = true
+		priority     = core.PriorityHigh
+		tag          = "tag"
+		autoComplete = true
+		recurrence   = "FREQ=DAILY"
+		limit        = 10
 	)
 	now := time.Now()
 
@@ -186,6 +286,7 @@ func TestTodoPostgres_GetByCompletion(t *testing.T) {
 		mock      func(m sqlmock.Sqlmock)
 		userID    uint
 		input     bool
+		sort      core.TodoSort
 		want      []core.Todo
 		errAssert assert.ErrorAssertionFunc
 	}{
@@ -193,22 +294,29 @@ func TestTodoPostgres_GetByCompletion(t *testing.T) {
 			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
 				rows := sqlmock.NewRows([]string{
-					"id", "title", "description", "completed", "created_at", "updated_at"}).
-					AddRow(id, title, description, completed, now, now)
-				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.created_at, td.updated_at FROM "+todosTable).
-					WithArgs(id, completed).
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"}).
+					AddRow(id, title, description, completed, priority, now, autoComplete, recurrence, nil, now, now, "{"+tag+"}", 1)
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
+					WithArgs(id, completed, limit).
 					WillReturnRows(rows)
 			},
 			userID: id,
+			sort:   core.TodoSort{Field: core.SortByCreatedAt},
 			input:  completed,
 			want: []core.Todo{
 				{
-					ID:          id,
-					Title:       title,
-					Description: description,
-					Completed:   completed,
-					CreatedAt:   now,
-					UpdatedAt:   now,
+					ID:           id,
+					Title:        title,
+					Description:  description,
+					Completed:    completed,
+					Priority:     priority,
+					DueAt:        &now,
+					AutoComplete: autoComplete,
+					Recurrence:   recurrence,
+					Tags:         []string{tag},
+					CreatedAt:    now,
+					UpdatedAt:    now,
+					Version:      1,
 				},
 			},
 			errAssert: assert.NoError,
@@ -217,12 +325,13 @@ func TestTodoPostgres_GetByCompletion(t *testing.T) {
 			name: "no user",
 			mock: func(m sqlmock.Sqlmock) {
 				rows := sqlmock.NewRows([]string{
-					"id", "title", "description", "completed", "created_at", "updated_at"})
-				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.created_at, td.updated_at FROM "+todosTable).
-					WithArgs(0, completed).
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"})
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
+					WithArgs(0, completed, limit).
 					WillReturnRows(rows)
 			},
 			userID:    0,
+			sort:      core.TodoSort{Field: core.SortByCreatedAt},
 			input:     completed,
 			want:      nil,
 			errAssert: assert.NoError,
@@ -231,12 +340,13 @@ func TestTodoPostgres_GetByCompletion(t *testing.T) {
 			name: "no results",
 			mock: func(m sqlmock.Sqlmock) {
 				rows := sqlmock.NewRows([]string{
-					"id", "title", "description", "completed", "created_at", "updated_at"})
-				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.created_at, td.updated_at FROM "+todosTable).
-					WithArgs(id, completed).
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"})
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
+					WithArgs(id, completed, limit).
 					WillReturnRows(rows)
 			},
 			userID:    id,
+			sort:      core.TodoSort{Field: core.SortByCreatedAt},
 			input:     completed,
 			want:      nil,
 			errAssert: assert.NoError,
@@ -244,7 +354,7 @@ func TestTodoPostgres_GetByCompletion(t *testing.T) {
 	}
 	for _, tt := range tests {
 		tt.mock(mock)
-		got, err := r.GetByCompletion(context.Background(), tt.userID, tt.input)
+		got, err := r.GetByCompletion(context.Background(), tt.userID, tt.input, tt.sort, core.TodoPage{Limit: limit})
 		tt.errAssert(t, err)
 		assert.Equal(t, tt.want, got)
 		assert.NoError(t, mock.ExpectationsWereMet())
@@ -253,10 +363,15 @@ func TestTodoPostgres_GetByCompletion(t *testing.T) {
 
 func TestTodoPostgres_GetAll(t *testing.T) {
 	const (
-		id          = 1
-		title       = "t"
-		description = "d"
-		completed   = true
+		id           = 1
+		title        = "t"
+		description  = "d"
+		completed    = true
+		priority     = core.PriorityHigh
+		tag          = "tag"
+		autoComplete = true
+		recurrence   = "FREQ=DAILY"
+		limit        = 10
 	)
 	now := time.Now()
 
@@ -270,6 +385,8 @@ func TestTodoPostgres_GetAll(t *testing.T) {
 		name      string
 		mock      func(m sqlmock.Sqlmock)
 		userID    uint
+		filter    core.TodoFilter
+		sort      core.TodoSort
 		want      []core.Todo
 		errAssert assert.ErrorAssertionFunc
 	}{
@@ -277,21 +394,28 @@ func TestTodoPostgres_GetAll(t *testing.T) {
 			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
 				rows := sqlmock.NewRows([]string{
-					"id", "title", "description", "completed", "created_at", "updated_at"}).
-					AddRow(id, title, description, completed, now, now)
-				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.created_at, td.updated_at FROM " + todosTable).
-					WithArgs(id).
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"}).
+					AddRow(id, title, description, completed, priority, now, autoComplete, recurrence, nil, now, now, "{"+tag+"}", 1)
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
+					WithArgs(id, limit).
 					WillReturnRows(rows)
 			},
 			userID: id,
+			sort:   core.TodoSort{Field: core.SortByCreatedAt},
 			want: []core.Todo{
 				{
-					ID:          id,
-					Title:       title,
-					Description: description,
-					Completed:   completed,
-					CreatedAt:   now,
-					UpdatedAt:   now,
+					ID:           id,
+					Title:        title,
+					Description:  description,
+					Completed:    completed,
+					Priority:     priority,
+					DueAt:        &now,
+					AutoComplete: autoComplete,
+					Recurrence:   recurrence,
+					Tags:         []string{tag},
+					CreatedAt:    now,
+					UpdatedAt:    now,
+					Version:      1,
 				},
 			},
 			errAssert: assert.NoError,
@@ -300,12 +424,13 @@ func TestTodoPostgres_GetAll(t *testing.T) {
 			name: "no user",
 			mock: func(m sqlmock.Sqlmock) {
 				rows := sqlmock.NewRows([]string{
-					"id", "title", "description", "completed", "created_at", "updated_at"})
-				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.created_at, td.updated_at FROM " + todosTable).
-					WithArgs(0).
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"})
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
+					WithArgs(0, limit).
 					WillReturnRows(rows)
 			},
 			userID:    0,
+			sort:      core.TodoSort{Field: core.SortByCreatedAt},
 			want:      nil,
 			errAssert: assert.NoError,
 		},
@@ -313,32 +438,126 @@ func TestTodoPostgres_GetAll(t *testing.T) {
 			name: "no results",
 			mock: func(m sqlmock.Sqlmock) {
 				rows := sqlmock.NewRows([]string{
-					"id", "title", "description", "completed", "created_at", "updated_at"})
-				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.created_at, td.updated_at FROM " + todosTable).
-					WithArgs(id).
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"})
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
+					WithArgs(id, limit).
+					WillReturnRows(rows)
+			},
+			userID:    id,
+			sort:      core.TodoSort{Field: core.SortByCreatedAt},
+			want:      nil,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok sorted by priority descending",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"}).
+					AddRow(id, title, description, completed, priority, now, autoComplete, recurrence, nil, now, now, "{"+tag+"}", 1)
+				m.ExpectQuery("ORDER BY td.priority DESC NULLS LAST, td.id DESC").
+					WithArgs(id, limit).
+					WillReturnRows(rows)
+			},
+			userID: id,
+			sort:   core.TodoSort{Field: core.SortByPriority, Descending: true},
+			want: []core.Todo{
+				{
+					ID:           id,
+					Title:        title,
+					Description:  description,
+					Completed:    completed,
+					Priority:     priority,
+					DueAt:        &now,
+					AutoComplete: autoComplete,
+					Recurrence:   recurrence,
+					Tags:         []string{tag},
+					CreatedAt:    now,
+					UpdatedAt:    now,
+					Version:      1,
+				},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "unknown sort field",
+			mock:      func(m sqlmock.Sqlmock) {},
+			userID:    id,
+			sort:      core.TodoSort{Field: "title"},
+			want:      nil,
+			errAssert: assert.Error,
+		},
+		{
+			name: "ok any of tags",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"}).
+					AddRow(id, title, description, completed, priority, now, autoComplete, recurrence, nil, now, now, "{"+tag+"}", 1)
+				m.ExpectQuery("WHERE td.deleted_at IS NULL AND \\(SELECT COUNT\\(\\*\\) (.+)\\) > 0 ORDER BY").
+					WithArgs(id, pq.Array([]string{tag}), limit).
+					WillReturnRows(rows)
+			},
+			userID: id,
+			filter: core.TodoFilter{Tags: []string{tag}, TagMatch: core.MatchAnyTag},
+			sort:   core.TodoSort{Field: core.SortByCreatedAt},
+			want: []core.Todo{
+				{
+					ID:           id,
+					Title:        title,
+					Description:  description,
+					Completed:    completed,
+					Priority:     priority,
+					DueAt:        &now,
+					AutoComplete: autoComplete,
+					Recurrence:   recurrence,
+					Tags:         []string{tag},
+					CreatedAt:    now,
+					UpdatedAt:    now,
+					Version:      1,
+				},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok all of tags",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"})
+				m.ExpectQuery("WHERE td.deleted_at IS NULL AND \\(SELECT COUNT\\(\\*\\) (.+)\\) = \\$3 ORDER BY").
+					WithArgs(id, pq.Array([]string{tag, "other"}), 2, limit).
 					WillReturnRows(rows)
 			},
 			userID:    id,
+			filter:    core.TodoFilter{Tags: []string{tag, "other"}, TagMatch: core.MatchAllTags},
+			sort:      core.TodoSort{Field: core.SortByCreatedAt},
 			want:      nil,
 			errAssert: assert.NoError,
 		},
+		{
+			name:      "unknown tag match",
+			mock:      func(m sqlmock.Sqlmock) {},
+			userID:    id,
+			filter:    core.TodoFilter{Tags: []string{tag}, TagMatch: "none"},
+			sort:      core.TodoSort{Field: core.SortByCreatedAt},
+			want:      nil,
+			errAssert: assert.Error,
+		},
 	}
 	for _, tt := range tests {
 		tt.mock(mock)
-		got, err := r.GetAll(context.Background(), tt.userID)
+		got, err := r.GetAll(context.Background(), tt.userID, tt.filter, tt.sort, core.TodoPage{Limit: limit})
 		tt.errAssert(t, err)
 		assert.Equal(t, tt.want, got)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	}
 }
 
-func TestTodoPostgres_UpdateByID(t *testing.T) {
+func TestTodoPostgres_GetAllAfterCursor(t *testing.T) {
 	const (
-		id          = 1
-		title       = "t"
-		description = "d"
-		completed   = true
+		id     = 1
+		cursor = 2
+		limit  = 10
 	)
+	now := time.Now().UTC().Truncate(time.Microsecond)
 
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -346,87 +565,182 @@ func TestTodoPostgres_UpdateByID(t *testing.T) {
 
 	r := NewTodoPostgres(db)
 
+	columns := []string{
+		"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"}
+
 	tests := []struct {
 		name      string
 		mock      func(m sqlmock.Sqlmock)
-		userID    uint
-		todoID    uint
-		input     core.Todo
-		want      uint
+		sort      core.TodoSort
+		page      core.TodoPage
+		want      []core.Todo
 		errAssert assert.ErrorAssertionFunc
 	}{
 		{
 			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"}).
-					AddRow(id)
-				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(title, description, completed, id, id).
+				rows := sqlmock.NewRows(columns).
+					AddRow(3, "t", "", false, core.PriorityNone, nil, false, "", nil, now, now, "{}", 1)
+				m.ExpectQuery(`AND \(td.created_at > \$2 OR \(td.created_at = \$2 AND td.id > \$3\) OR td.created_at IS NULL\) ORDER BY`).
+					WithArgs(id, now, cursor, limit).
 					WillReturnRows(rows)
 			},
-			userID: id,
-			todoID: id,
-			input: core.Todo{
-				Title:       title,
-				Description: description,
-				Completed:   completed,
+			sort: core.TodoSort{Field: core.SortByCreatedAt},
+			page: core.TodoPage{After: cursor, AfterValue: now.UnixMicro(), Limit: limit},
+			want: []core.Todo{
+				{
+					ID:        3,
+					Title:     "t",
+					Tags:      []string{},
+					CreatedAt: now,
+					UpdatedAt: now,
+					Version:   1,
+				},
 			},
-			want:      id,
 			errAssert: assert.NoError,
 		},
 		{
-			name: "no user",
+			name: "ok descending",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"})
-				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(title, description, completed, 0, id).
-					WillReturnRows(rows)
+				m.ExpectQuery(`AND \(td.priority < \$2 OR \(td.priority = \$2 AND td.id < \$3\) OR td.priority IS NULL\) ORDER BY`).
+					WithArgs(id, core.PriorityHigh, cursor, limit).
+					WillReturnRows(sqlmock.NewRows(columns))
 			},
-			userID: 0,
-			todoID: id,
-			input: core.Todo{
-				Title:       title,
-				Description: description,
-				Completed:   completed,
+			sort:      core.TodoSort{Field: core.SortByPriority, Descending: true},
+			page:      core.TodoPage{After: cursor, AfterValue: int64(core.PriorityHigh), Limit: limit},
+			want:      nil,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok cursor without due date",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`AND \(td.due_at IS NULL AND td.id > \$2\) ORDER BY`).
+					WithArgs(id, cursor, limit).
+					WillReturnRows(sqlmock.NewRows(columns))
 			},
-			want:      0,
-			errAssert: assert.Error,
+			sort:      core.TodoSort{Field: core.SortByDueAt},
+			page:      core.TodoPage{After: cursor, AfterNull: true, Limit: limit},
+			want:      nil,
+			errAssert: assert.NoError,
 		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetAll(context.Background(), id, core.TodoFilter{}, tt.sort, tt.page)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestTodoPostgres_GetByProject(t *testing.T) {
+	const (
+		id           = 1
+		title        = "t"
+		description  = "d"
+		completed    = true
+		priority     = core.PriorityHigh
+		tag          = "tag"
+		autoComplete = true
+		recurrence   = "FREQ=DAILY"
+	)
+	var projectID uint = 2
+	now := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		projectID uint
+		sort      core.TodoSort
+		want      []core.Todo
+		errAssert assert.ErrorAssertionFunc
+	}{
 		{
-			name: "no todo",
+			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"})
-				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(title, description, completed, id, 0).
+				rows := sqlmock.NewRows([]string{
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"}).
+					AddRow(id, title, description, completed, priority, now, autoComplete, recurrence, projectID, now, now, "{"+tag+"}", 1)
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable+"(.+)WHERE ut.project_id = \\$2").
+					WithArgs(id, projectID).
 					WillReturnRows(rows)
 			},
-			userID: id,
-			todoID: 0,
-			input: core.Todo{
-				Title:       title,
-				Description: description,
-				Completed:   completed,
+			userID:    id,
+			projectID: projectID,
+			sort:      core.TodoSort{Field: core.SortByCreatedAt},
+			want: []core.Todo{
+				{
+					ID:           id,
+					Title:        title,
+					Description:  description,
+					Completed:    completed,
+					Priority:     priority,
+					DueAt:        &now,
+					AutoComplete: autoComplete,
+					Recurrence:   recurrence,
+					ProjectID:    &projectID,
+					Tags:         []string{tag},
+					CreatedAt:    now,
+					UpdatedAt:    now,
+					Version:      1,
+				},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no results",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"})
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
+					WithArgs(id, 0).
+					WillReturnRows(rows)
 			},
-			want:      0,
+			userID:    id,
+			projectID: 0,
+			sort:      core.TodoSort{Field: core.SortByCreatedAt},
+			want:      nil,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "unknown sort field",
+			mock:      func(m sqlmock.Sqlmock) {},
+			userID:    id,
+			projectID: projectID,
+			sort:      core.TodoSort{Field: "unknown"},
+			want:      nil,
 			errAssert: assert.Error,
 		},
 	}
 	for _, tt := range tests {
 		tt.mock(mock)
-		got, err := r.UpdateByID(context.Background(), tt.userID, tt.todoID, tt.input)
+		got, err := r.GetByProject(context.Background(), tt.userID, tt.projectID, tt.sort)
 		tt.errAssert(t, err)
 		assert.Equal(t, tt.want, got)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	}
 }
 
-func TestTodoPostgres_PatchID(t *testing.T) {
+func TestTodoPostgres_GetOverdue(t *testing.T) {
 	const (
-		id          = 1
-		title       = "t"
-		description = "d"
-		completed   = true
+		id           = 1
+		title        = "t"
+		description  = "d"
+		completed    = false
+		priority     = core.PriorityHigh
+		tag          = "tag"
+		autoComplete = true
+		recurrence   = "FREQ=DAILY"
 	)
+	now := time.Now()
+	dueAt := now.Add(-time.Hour)
 
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -438,197 +752,1648 @@ func TestTodoPostgres_PatchID(t *testing.T) {
 		name      string
 		mock      func(m sqlmock.Sqlmock)
 		userID    uint
-		todoID    uint
-		input     core.Todo
-		want      uint
+		input     time.Time
+		want      []core.Todo
 		errAssert assert.ErrorAssertionFunc
 	}{
 		{
-			name: "ok all fields",
+			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"}).
-					AddRow(id)
-				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(title, description, completed, id, id).
+				rows := sqlmock.NewRows([]string{
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"}).
+					AddRow(id, title, description, completed, priority, dueAt, autoComplete, recurrence, nil, now, now, "{"+tag+"}", 1)
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
+					WithArgs(id, now).
 					WillReturnRows(rows)
 			},
 			userID: id,
-			todoID: id,
-			input: core.Todo{
-				Title:       title,
-				Description: description,
-				Completed:   completed,
+			input:  now,
+			want: []core.Todo{
+				{
+					ID:           id,
+					Title:        title,
+					Description:  description,
+					Completed:    completed,
+					Priority:     priority,
+					DueAt:        &dueAt,
+					AutoComplete: autoComplete,
+					Recurrence:   recurrence,
+					Tags:         []string{tag},
+					CreatedAt:    now,
+					UpdatedAt:    now,
+					Version:      1,
+				},
 			},
-			want:      id,
 			errAssert: assert.NoError,
 		},
 		{
-			name: "ok no title",
+			name: "no user",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"}).
-					AddRow(id)
-				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(description, completed, id, id).
+				rows := sqlmock.NewRows([]string{
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"})
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
+					WithArgs(0, now).
 					WillReturnRows(rows)
 			},
-			userID: id,
-			todoID: id,
-			input: core.Todo{
-				Description: description,
-				Completed:   completed,
-			},
-			want:      id,
+			userID:    0,
+			input:     now,
+			want:      nil,
 			errAssert: assert.NoError,
 		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetOverdue(context.Background(), tt.userID, tt.input)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTodoPostgres_GetDueBetween(t *testing.T) {
+	const (
+		id           = 1
+		title        = "t"
+		description  = "d"
+		completed    = false
+		priority     = core.PriorityHigh
+		tag          = "tag"
+		autoComplete = true
+		recurrence   = "FREQ=DAILY"
+	)
+	now := time.Now()
+	from, to := now, now.AddDate(0, 0, 1)
+	dueAt := now.Add(time.Hour)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		from      time.Time
+		to        time.Time
+		want      []core.Todo
+		errAssert assert.ErrorAssertionFunc
+	}{
 		{
-			name: "ok no description",
+			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"}).
-					AddRow(id)
-				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(title, completed, id, id).
+				rows := sqlmock.NewRows([]string{
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"}).
+					AddRow(id, title, description, completed, priority, dueAt, autoComplete, recurrence, nil, now, now, "{"+tag+"}", 1)
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
+					WithArgs(id, from, to).
 					WillReturnRows(rows)
 			},
 			userID: id,
-			todoID: id,
-			input: core.Todo{
-				Title:     title,
-				Completed: completed,
+			from:   from,
+			to:     to,
+			want: []core.Todo{
+				{
+					ID:           id,
+					Title:        title,
+					Description:  description,
+					Completed:    completed,
+					Priority:     priority,
+					DueAt:        &dueAt,
+					AutoComplete: autoComplete,
+					Recurrence:   recurrence,
+					Tags:         []string{tag},
+					CreatedAt:    now,
+					UpdatedAt:    now,
+					Version:      1,
+				},
 			},
-			want:      id,
 			errAssert: assert.NoError,
 		},
 		{
-			name: "ok no completed",
+			name: "no results",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"}).
-					AddRow(id)
-				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(title, description, false, id, id).
+				rows := sqlmock.NewRows([]string{
+					"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"})
+				m.ExpectQuery("SELECT td.id, td.title, td.description, td.completed, td.priority, td.due_at, td.auto_complete, td.recurrence, ut.project_id, td.created_at, td.updated_at, ARRAY(.+) AS tags, td.version FROM "+todosTable).
+					WithArgs(id, from, to).
 					WillReturnRows(rows)
 			},
-			userID: id,
-			todoID: id,
-			input: core.Todo{
-				Title:       title,
-				Description: description,
-			},
-			want:      id,
-			errAssert: assert.NoError,
+			userID:    id,
+			from:      from,
+			to:        to,
+			want:      nil,
+			errAssert: assert.NoError,
 		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetDueBetween(context.Background(), tt.userID, tt.from, tt.to)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTodoPostgres_Search(t *testing.T) {
+	const (
+		id      = 1
+		title   = "pay rent"
+		query   = "rent"
+		limit   = 10
+		rank    = float32(0.6)
+		snippet = "pay <mark>rent</mark>"
+	)
+	now := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	columns := []string{
+		"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version",
+		"rank", "title_snippet", "description_snippet"}
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		want      []core.TodoSearchResult
+		errAssert assert.ErrorAssertionFunc
+	}{
 		{
-			name: "ok only title",
+			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"}).
-					AddRow(id)
+				rows := sqlmock.NewRows(columns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, now, now, "{}", 1, rank, snippet, "")
+				m.ExpectQuery("ts_headline\\('english', replace\\((.+)td.title, '&', '&amp;'\\)(.+)FROM "+todosTable+
+					"(.+)websearch_to_tsquery(.+) ORDER BY rank DESC").
+					WithArgs(id, query, limit).
+					WillReturnRows(rows)
+			},
+			want: []core.TodoSearchResult{
+				{
+					Todo: core.Todo{
+						ID:        id,
+						Title:     title,
+						Tags:      []string{},
+						CreatedAt: now,
+						UpdatedAt: now,
+						Version:   1,
+					},
+					Rank:         rank,
+					TitleSnippet: snippet,
+				},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no results",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable).
+					WithArgs(id, query, limit).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			want:      nil,
+			errAssert: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.Search(context.Background(), id, query, limit)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestTodoPostgres_UpdateByID(t *testing.T) {
+	const (
+		id           = 1
+		title        = "t"
+		description  = "d"
+		completed    = true
+		priority     = core.PriorityHigh
+		tag          = "tag"
+		autoComplete = true
+		recurrence   = "FREQ=DAILY"
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	stateColumns := []string{
+		"title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "deleted_at"}
+	changeColumns := append(append([]string{"id"}, stateColumns...), "user_ids")
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		todoID    uint
+		input     core.Todo
+		want      []uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("old", description, false, priority, nil, autoComplete, recurrence, nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, description, completed, priority, nil, autoComplete, recurrence, nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(title, description, completed, priority, nil, autoComplete, recurrence, nil, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated,
+						`{"completed":{"before":false,"after":true},"title":{"before":"old","after":"t"}}`).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.Todo{
+				Title:        title,
+				Description:  description,
+				Completed:    completed,
+				Priority:     priority,
+				AutoComplete: autoComplete,
+				Recurrence:   recurrence,
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok no changes",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow(title, description, completed, priority, nil, autoComplete, recurrence, nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, description, completed, priority, nil, autoComplete, recurrence, nil, "{1,2}")
 				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(title, false, id, id).
+					WithArgs(title, description, completed, priority, nil, autoComplete, recurrence, nil, id, id, 0).
 					WillReturnRows(rows)
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.Todo{
+				Title:        title,
+				Description:  description,
+				Completed:    completed,
+				Priority:     priority,
+				AutoComplete: autoComplete,
+				Recurrence:   recurrence,
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "version mismatch",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow(title, description, completed, priority, nil, autoComplete, recurrence, nil))
+				m.ExpectQuery("UPDATE "+todosTable+"(.+)td.version = \\$11::INTEGER").
+					WithArgs(title, description, completed, priority, nil, autoComplete, recurrence, nil, id, id, 2).
+					WillReturnRows(sqlmock.NewRows(changeColumns))
+				m.ExpectQuery("SELECT td.version FROM "+todosTable).
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+				m.ExpectRollback()
 			},
 			userID: id,
 			todoID: id,
 			input: core.Todo{
-				Title: title,
+				Title:        title,
+				Description:  description,
+				Completed:    completed,
+				Priority:     priority,
+				AutoComplete: autoComplete,
+				Recurrence:   recurrence,
+				Version:      2,
+			},
+			want: nil,
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrVersionMismatch)
+			},
+		},
+		{
+			name: "no user",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, 0).
+					WillReturnRows(sqlmock.NewRows(stateColumns))
+				m.ExpectRollback()
+			},
+			userID: 0,
+			todoID: id,
+			input: core.Todo{
+				Title:        title,
+				Description:  description,
+				Completed:    completed,
+				Priority:     priority,
+				AutoComplete: autoComplete,
+				Recurrence:   recurrence,
+			},
+			want:      nil,
+			errAssert: assert.Error,
+		},
+		{
+			name: "no todo",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(0, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns))
+				m.ExpectRollback()
+			},
+			userID: id,
+			todoID: 0,
+			input: core.Todo{
+				Title:        title,
+				Description:  description,
+				Completed:    completed,
+				Priority:     priority,
+				AutoComplete: autoComplete,
+				Recurrence:   recurrence,
+			},
+			want:      nil,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.UpdateByID(context.Background(), tt.userID, tt.todoID, tt.input)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTodoPostgres_PatchID(t *testing.T) {
+	const (
+		id           = 1
+		title        = "t"
+		description  = "d"
+		completed    = true
+		priority     = core.PriorityHigh
+		tag          = "tag"
+		autoComplete = true
+		recurrence   = "FREQ=DAILY"
+	)
+	var projectID uint = 2
+	dueAt := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	stateColumns := []string{
+		"title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "deleted_at"}
+	changeColumns := append(append([]string{"id"}, stateColumns...), "user_ids")
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		todoID    uint
+		input     core.TodoPatch
+		want      []uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok all fields",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(title, description, completed, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				Title:       core.Some(title),
+				Description: core.Some(description),
+				Completed:   core.Some(completed),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok no title",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(description, completed, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				Description: core.Some(description),
+				Completed:   core.Some(completed),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok no description",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(title, completed, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				Title:     core.Some(title),
+				Completed: core.Some(completed),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok no completed",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(title, description, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				Title:       core.Some(title),
+				Description: core.Some(description),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok only title",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(title, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				Title: core.Some(title),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok only description",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(description, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				Description: core.Some(description),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok only completed",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(completed, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				Completed: core.Some(completed),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok only due date",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(dueAt, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				DueAt: core.Some(&dueAt),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok only priority",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(priority, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				Priority: core.Some(priority),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok only project",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("WITH ut AS \\(UPDATE "+usersTodosTable+"(.+)UPDATE "+todosTable).
+					WithArgs(projectID, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				ProjectID: core.Some(&projectID),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok only auto complete",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(autoComplete, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				AutoComplete: core.Some(autoComplete),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok only recurrence",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(recurrence, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				Recurrence: core.Some(recurrence),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok clear due date",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, dueAt, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(nil, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				DueAt: core.Some[*time.Time](nil),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok move to inbox",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+usersTodosTable+"(.+)UPDATE "+todosTable+"(.+)SET version = td.version").
+					WithArgs(nil, id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID: id,
+			todoID: id,
+			input: core.TodoPatch{
+				ProjectID: core.Some[*uint](nil),
+			},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok empty",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoUpdated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID:    id,
+			todoID:    id,
+			input:     core.TodoPatch{},
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no user",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, 0).
+					WillReturnRows(sqlmock.NewRows(stateColumns))
+				m.ExpectRollback()
+			},
+			userID: 0,
+			todoID: id,
+			input: core.TodoPatch{
+				Title:       core.Some(title),
+				Description: core.Some(description),
+				Completed:   core.Some(completed),
+			},
+			want:      nil,
+			errAssert: assert.Error,
+		},
+		{
+			name: "no todo",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(0, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns))
+				m.ExpectRollback()
+			},
+			userID: id,
+			todoID: 0,
+			input: core.TodoPatch{
+				Title:       core.Some(title),
+				Description: core.Some(description),
+				Completed:   core.Some(completed),
+			},
+			want:      nil,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.PatchByID(context.Background(), tt.userID, tt.todoID, tt.input)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTodoPostgres_DeleteByID(t *testing.T) {
+	const id = 1
+	deletedAt := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	stateColumns := []string{
+		"title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "deleted_at"}
+	changeColumns := append(append([]string{"id"}, stateColumns...), "user_ids")
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		todoID    uint
+		version   uint
+		want      []uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("t", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, "t", "", false, core.PriorityNone, nil, false, "", deletedAt, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable+" td SET deleted_at = NOW\\(\\)").
+					WithArgs(id, id, 0).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoDeleted, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID:    id,
+			todoID:    id,
+			want:      []uint{1, 2},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "version matches",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("t", "", false, core.PriorityNone, nil, false, "", nil))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, "t", "", false, core.PriorityNone, nil, false, "", deletedAt, "{1}")
+				m.ExpectQuery("UPDATE "+todosTable+" td SET deleted_at = NOW\\(\\)").
+					WithArgs(id, id, 2).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoDeleted, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
+			},
+			userID:    id,
+			todoID:    id,
+			version:   2,
+			want:      []uint{1},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "version mismatch",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("t", "", false, core.PriorityNone, nil, false, "", nil))
+				m.ExpectQuery("UPDATE "+todosTable+" td SET deleted_at = NOW\\(\\)").
+					WithArgs(id, id, 2).
+					WillReturnRows(sqlmock.NewRows(changeColumns))
+				m.ExpectQuery("SELECT td.version FROM "+todosTable).
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+				m.ExpectRollback()
+			},
+			userID:  id,
+			todoID:  id,
+			version: 2,
+			want:    nil,
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrVersionMismatch)
+			},
+		},
+		{
+			name: "no access with version",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(id, 2).
+					WillReturnRows(sqlmock.NewRows(stateColumns))
+				m.ExpectRollback()
+			},
+			userID:  2,
+			todoID:  id,
+			version: 2,
+			want:    nil,
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.Error(t, err) && assert.NotErrorIs(t, err, ErrVersionMismatch)
+			},
+		},
+		{
+			name: "no user",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(id, 0).
+					WillReturnRows(sqlmock.NewRows(stateColumns))
+				m.ExpectRollback()
+			},
+			userID:    0,
+			todoID:    id,
+			want:      nil,
+			errAssert: assert.Error,
+		},
+		{
+			name: "no todo",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(0, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns))
+				m.ExpectRollback()
+			},
+			userID:    id,
+			todoID:    0,
+			want:      nil,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.DeleteByID(context.Background(), tt.userID, tt.todoID, tt.version)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTodoPostgres_DeleteByCompletion(t *testing.T) {
+	const (
+		id        = 1
+		completed = true
+	)
+	deletedAt := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	changeColumns := []string{
+		"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "deleted_at",
+		"user_ids"}
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		userID    uint
+		input     bool
+		want      []uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, "t", "", completed, core.PriorityNone, nil, false, "", deletedAt, "{1,2}").
+					AddRow(2, "t", "", completed, core.PriorityNone, nil, false, "", deletedAt, "{1}")
+				m.ExpectQuery("UPDATE "+todosTable+" td SET deleted_at = NOW\\(\\)").
+					WithArgs(id, completed).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoDeleted, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(2, id, core.TodoDeleted, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				m.ExpectCommit()
+			},
+			userID:    id,
+			input:     completed,
+			want:      []uint{id, 1, 2, 1},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no user",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("UPDATE "+todosTable+" td SET deleted_at = NOW\\(\\)").
+					WithArgs(0, completed).
+					WillReturnRows(sqlmock.NewRows(changeColumns))
+				m.ExpectCommit()
+			},
+			userID:    0,
+			input:     completed,
+			want:      []uint{0},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no matches",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("UPDATE "+todosTable+" td SET deleted_at = NOW\\(\\)").
+					WithArgs(id, completed).
+					WillReturnRows(sqlmock.NewRows(changeColumns))
+				m.ExpectCommit()
+			},
+			userID:    id,
+			input:     completed,
+			want:      []uint{id},
+			errAssert: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.DeleteByCompletion(context.Background(), tt.userID, tt.input)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTodoPostgres_Export(t *testing.T) {
+	const (
+		id    = 1
+		title = "t"
+	)
+	now := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	columns := []string{
+		"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version"}
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		fnErr     error
+		want      []core.Todo
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, now, now, "{}", 1).
+					AddRow(id+1, title, "", true, core.PriorityNone, nil, false, "", nil, now, now, "{}", 1)
+				m.ExpectQuery("SELECT (.+) FROM " + todosTable + "(.+)td.deleted_at IS NULL ORDER BY td.id").
+					WithArgs(id).
+					WillReturnRows(rows)
+			},
+			want: []core.Todo{
+				{ID: id, Title: title, Tags: []string{}, CreatedAt: now, UpdatedAt: now, Version: 1},
+				{ID: id + 1, Title: title, Completed: true, Tags: []string{}, CreatedAt: now, UpdatedAt: now, Version: 1},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "stopped by fn",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, now, now, "{}", 1).
+					AddRow(id+1, title, "", true, core.PriorityNone, nil, false, "", nil, now, now, "{}", 1)
+				m.ExpectQuery("SELECT (.+) FROM " + todosTable).
+					WithArgs(id).
+					WillReturnRows(rows)
+			},
+			fnErr: errStop,
+			want: []core.Todo{
+				{ID: id, Title: title, Tags: []string{}, CreatedAt: now, UpdatedAt: now, Version: 1},
+			},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, errStop)
+			},
+		},
+		{
+			name: "empty",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("SELECT (.+) FROM " + todosTable).
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			want:      nil,
+			errAssert: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		var got []core.Todo
+		err := r.Export(context.Background(), id, func(todo core.Todo) error {
+			got = append(got, todo)
+			return tt.fnErr
+		})
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestTodoPostgres_ExportCalendar(t *testing.T) {
+	const (
+		id    = 1
+		title = "t"
+		name  = "n.ics"
+		uid   = "u"
+	)
+	now := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	columns := []string{
+		"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version", "calendar_name", "calendar_uid"}
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		want      []core.CalendarObject
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(id, title, "", false, core.PriorityNone, nil, false, "", nil, now, now, "{}", 1, name, uid).
+					AddRow(id+1, title, "", true, core.PriorityNone, nil, false, "", nil, now, now, "{}", 1, "", "")
+				m.ExpectQuery("SELECT (.+) COALESCE\\(ut.calendar_name, ''\\)(.+) FROM " + todosTable +
+					"(.+)td.deleted_at IS NULL ORDER BY td.id").
+					WithArgs(id).
+					WillReturnRows(rows)
+			},
+			want: []core.CalendarObject{
+				{
+					Todo: core.Todo{ID: id, Title: title, Tags: []string{}, CreatedAt: now, UpdatedAt: now, Version: 1},
+					Name: name,
+					UID:  uid,
+				},
+				{
+					Todo: core.Todo{ID: id + 1, Title: title, Completed: true, Tags: []string{}, CreatedAt: now,
+						UpdatedAt: now, Version: 1},
+				},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "fail to query",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("SELECT (.+) FROM " + todosTable).
+					WithArgs(id).
+					WillReturnError(errors.New(""))
+			},
+			want:      nil,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		var got []core.CalendarObject
+		err := r.ExportCalendar(context.Background(), id, func(obj core.CalendarObject) error {
+			got = append(got, obj)
+			return nil
+		})
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestTodoPostgres_GetCalendarObject(t *testing.T) {
+	const (
+		userID = 1
+		todoID = 2
+		title  = "t"
+		name   = "n.ics"
+		uid    = "u"
+	)
+	now := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	columns := []string{
+		"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version", "calendar_name", "calendar_uid"}
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		todoID    uint
+		want      core.CalendarObject
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok by name",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(todoID, title, "", false, core.PriorityNone, nil, false, "", nil, now, now, "{}", 1, name, uid)
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+
+					"(.+)ut.calendar_name = \\$2 OR \\(ut.calendar_name IS NULL AND td.id = \\$3\\)").
+					WithArgs(userID, name, 0).
+					WillReturnRows(rows)
+			},
+			todoID: 0,
+			want: core.CalendarObject{
+				Todo: core.Todo{ID: todoID, Title: title, Tags: []string{}, CreatedAt: now, UpdatedAt: now, Version: 1},
+				Name: name,
+				UID:  uid,
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "not found",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable).
+					WithArgs(userID, name, todoID).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			todoID: todoID,
+			want:   core.CalendarObject{},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrNotFound)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetCalendarObject(context.Background(), userID, name, tt.todoID)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestTodoPostgres_SetCalendarName(t *testing.T) {
+	const (
+		userID = 1
+		todoID = 2
+		name   = "n.ics"
+		uid    = "u"
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("UPDATE "+usersTodosTable+" SET calendar_name = \\$3, calendar_uid = \\$4").
+					WithArgs(userID, todoID, name, uid).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "name taken",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("UPDATE "+usersTodosTable).
+					WithArgs(userID, todoID, name, uid).
+					WillReturnError(&pq.Error{Code: uniqueViolationCode})
+			},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrAlreadyExists)
+			},
+		},
+		{
+			name: "todo not found",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("UPDATE "+usersTodosTable).
+					WithArgs(userID, todoID, name, uid).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrNotFound)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.SetCalendarName(context.Background(), userID, todoID, name, uid)
+		tt.errAssert(t, err, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestTodoPostgres_GetTrash(t *testing.T) {
+	const (
+		id    = 1
+		title = "t"
+	)
+	now := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	columns := []string{
+		"id", "title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "project_id", "created_at", "updated_at", "tags", "version", "deleted_at"}
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		want      []core.Todo
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows(columns).
+					AddRow(id, title, "", true, core.PriorityNone, nil, false, "", nil, now, now, "{}", 1, now)
+				m.ExpectQuery("SELECT (.+), td.deleted_at FROM " + todosTable + "(.+)td.deleted_at IS NOT NULL").
+					WithArgs(id).
+					WillReturnRows(rows)
+			},
+			want: []core.Todo{
+				{
+					ID:        id,
+					Title:     title,
+					Completed: true,
+					Tags:      []string{},
+					CreatedAt: now,
+					UpdatedAt: now,
+					Version:   1,
+					DeletedAt: &now,
+				},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "empty",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("SELECT (.+) FROM " + todosTable).
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			want:      nil,
+			errAssert: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetTrash(context.Background(), id)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestTodoPostgres_Restore(t *testing.T) {
+	const id = 1
+	deletedAt := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	stateColumns := []string{
+		"title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "deleted_at"}
+	changeColumns := append(append([]string{"id"}, stateColumns...), "user_ids")
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		todoID    uint
+		want      []uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("t", "", false, core.PriorityNone, nil, false, "", deletedAt))
+				rows := sqlmock.NewRows(changeColumns).
+					AddRow(id, "t", "", false, core.PriorityNone, nil, false, "", nil, "{1,2}")
+				m.ExpectQuery("UPDATE "+todosTable+" td SET deleted_at = NULL").
+					WithArgs(id, id).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoRestored, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectCommit()
 			},
-			want:      id,
+			todoID:    id,
+			want:      []uint{1, 2},
 			errAssert: assert.NoError,
 		},
 		{
-			name: "ok only description",
+			name: "not in trash",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"}).
-					AddRow(id)
-				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(description, false, id, id).
-					WillReturnRows(rows)
-			},
-			userID: id,
-			todoID: id,
-			input: core.Todo{
-				Description: description,
+				m.ExpectBegin()
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow("t", "", false, core.PriorityNone, nil, false, "", nil))
+				m.ExpectQuery("UPDATE "+todosTable+" td SET deleted_at = NULL").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(changeColumns))
+				m.ExpectRollback()
 			},
-			want:      id,
-			errAssert: assert.NoError,
+			todoID:    id,
+			want:      nil,
+			errAssert: assert.Error,
 		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.Restore(context.Background(), id, tt.todoID)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestTodoPostgres_EmptyTrash(t *testing.T) {
+	const id = 1
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	mock.ExpectExec("DELETE FROM " + todosTable + "(.+)td.deleted_at IS NOT NULL").
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err = r.EmptyTrash(context.Background(), id)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTodoPostgres_Purge(t *testing.T) {
+	before := time.Now()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		want      []uint
+		errAssert assert.ErrorAssertionFunc
+	}{
 		{
-			name: "ok only completed",
+			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"}).
-					AddRow(id)
-				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(completed, id, id).
+				rows := sqlmock.NewRows([]string{"user_ids"}).
+					AddRow("{1}").
+					AddRow("{2,3}")
+				m.ExpectQuery("DELETE FROM " + todosTable + " td WHERE td.deleted_at < \\$1").
+					WithArgs(before).
 					WillReturnRows(rows)
 			},
-			userID: id,
-			todoID: id,
-			input: core.Todo{
-				Completed: completed,
-			},
-			want:      id,
+			want:      []uint{1, 2, 3},
 			errAssert: assert.NoError,
 		},
 		{
-			name: "ok empty",
+			name: "nothing to purge",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"}).
-					AddRow(id)
-				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(false, id, id).
-					WillReturnRows(rows)
+				m.ExpectQuery("DELETE FROM " + todosTable).
+					WithArgs(before).
+					WillReturnRows(sqlmock.NewRows([]string{"user_ids"}))
 			},
-			userID:    id,
-			todoID:    id,
-			input:     core.Todo{},
-			want:      id,
+			want:      nil,
 			errAssert: assert.NoError,
 		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.Purge(context.Background(), before)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestTodoPostgres_Share(t *testing.T) {
+	const (
+		id       = 1
+		username = "u"
+		role     = core.RoleEditor
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		todoID    uint
+		want      uint
+		errAssert assert.ErrorAssertionFunc
+	}{
 		{
-			name: "no user",
+			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"})
-				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(title, description, completed, 0, id).
+				rows := sqlmock.NewRows([]string{"user_id"}).
+					AddRow(2)
+				m.ExpectQuery("INSERT INTO "+usersTodosTable).
+					WithArgs(id, id, username, role).
 					WillReturnRows(rows)
 			},
-			userID: 0,
-			todoID: id,
-			input: core.Todo{
-				Title:       title,
-				Description: description,
-				Completed:   completed,
-			},
-			want:      0,
-			errAssert: assert.Error,
+			todoID:    id,
+			want:      2,
+			errAssert: assert.NoError,
 		},
 		{
-			name: "no todo",
+			name: "no todo or user",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"})
-				m.ExpectQuery("UPDATE "+todosTable).
-					WithArgs(title, description, completed, id, 0).
+				rows := sqlmock.NewRows([]string{"user_id"})
+				m.ExpectQuery("INSERT INTO "+usersTodosTable).
+					WithArgs(id, 0, username, role).
 					WillReturnRows(rows)
 			},
-			userID: id,
 			todoID: 0,
-			input: core.Todo{
-				Title:       title,
-				Description: description,
-				Completed:   completed,
+			want:   0,
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrNotFound)
 			},
-			want:      0,
-			errAssert: assert.Error,
 		},
 	}
 	for _, tt := range tests {
 		tt.mock(mock)
-		got, err := r.PatchByID(context.Background(), tt.userID, tt.todoID, tt.input)
+		got, err := r.Share(context.Background(), id, tt.todoID, username, role)
 		tt.errAssert(t, err)
 		assert.Equal(t, tt.want, got)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	}
 }
 
-func TestTodoPostgres_DeleteByID(t *testing.T) {
+func TestTodoPostgres_GetCollaborators(t *testing.T) {
 	const id = 1
 
 	db, mock, err := sqlmock.New()
@@ -637,69 +2402,108 @@ func TestTodoPostgres_DeleteByID(t *testing.T) {
 
 	r := NewTodoPostgres(db)
 
+	columns := []string{"id", "username", "role"}
+
 	tests := []struct {
 		name      string
 		mock      func(m sqlmock.Sqlmock)
-		userID    uint
 		todoID    uint
-		want      uint
+		want      []core.Collaborator
 		errAssert assert.ErrorAssertionFunc
 	}{
 		{
 			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"}).
-					AddRow(id)
-				m.ExpectQuery("DELETE FROM "+todosTable).
+				rows := sqlmock.NewRows(columns).
+					AddRow(id, "a", core.RoleOwner).
+					AddRow(2, "b", core.RoleViewer)
+				m.ExpectQuery("SELECT (.+) FROM "+usersTodosTable).
 					WithArgs(id, id).
 					WillReturnRows(rows)
 			},
-			userID:    id,
-			todoID:    id,
-			want:      id,
+			todoID: id,
+			want: []core.Collaborator{
+				{UserID: id, Username: "a", Role: core.RoleOwner},
+				{UserID: 2, Username: "b", Role: core.RoleViewer},
+			},
 			errAssert: assert.NoError,
 		},
 		{
-			name: "no user",
+			name: "no todo",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"})
-				m.ExpectQuery("DELETE FROM "+todosTable).
-					WithArgs(0, id).
+				rows := sqlmock.NewRows(columns)
+				m.ExpectQuery("SELECT (.+) FROM "+usersTodosTable).
+					WithArgs(id, 0).
 					WillReturnRows(rows)
 			},
-			userID:    0,
-			todoID:    id,
-			want:      0,
-			errAssert: assert.Error,
+			todoID:    0,
+			want:      nil,
+			errAssert: assert.NoError,
 		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetCollaborators(context.Background(), id, tt.todoID)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestTodoPostgres_RevokeAccess(t *testing.T) {
+	const id = 1
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	tests := []struct {
+		name           string
+		mock           func(m sqlmock.Sqlmock)
+		collaboratorID uint
+		errAssert      assert.ErrorAssertionFunc
+	}{
 		{
-			name: "no todo",
+			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"})
-				m.ExpectQuery("DELETE FROM "+todosTable).
-					WithArgs(id, 0).
+				rows := sqlmock.NewRows([]string{"user_id"}).
+					AddRow(2)
+				m.ExpectQuery("DELETE FROM "+usersTodosTable).
+					WithArgs(id, id, 2).
 					WillReturnRows(rows)
 			},
-			userID:    id,
-			todoID:    0,
-			want:      0,
-			errAssert: assert.Error,
+			collaboratorID: 2,
+			errAssert:      assert.NoError,
+		},
+		{
+			name: "no collaborator",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_id"})
+				m.ExpectQuery("DELETE FROM "+usersTodosTable).
+					WithArgs(id, id, 3).
+					WillReturnRows(rows)
+			},
+			collaboratorID: 3,
+			errAssert:      assert.Error,
 		},
 	}
 	for _, tt := range tests {
 		tt.mock(mock)
-		got, err := r.DeleteByID(context.Background(), tt.userID, tt.todoID)
+		err := r.RevokeAccess(context.Background(), id, id, tt.collaboratorID)
 		tt.errAssert(t, err)
-		assert.Equal(t, tt.want, got)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	}
 }
 
-func TestTodoPostgres_DeleteByCompletion(t *testing.T) {
+func TestTodoPostgres_GetHistory(t *testing.T) {
 	const (
-		id        = 1
-		completed = true
+		id       = 1
+		username = "u"
+		limit    = 10
 	)
+	now := time.Now()
 
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -707,60 +2511,202 @@ func TestTodoPostgres_DeleteByCompletion(t *testing.T) {
 
 	r := NewTodoPostgres(db)
 
+	columns := []string{"id", "todo_id", "user_id", "username", "action", "changes", "created_at"}
+
 	tests := []struct {
 		name      string
 		mock      func(m sqlmock.Sqlmock)
-		userID    uint
-		input     bool
-		want      []uint
+		page      core.TodoPage
+		want      []core.TodoEvent
 		errAssert assert.ErrorAssertionFunc
 	}{
 		{
 			name: "ok",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"}).
-					AddRow(id)
-				m.ExpectQuery("DELETE FROM "+todosTable).
-					WithArgs(id, completed).
+				m.ExpectQuery("SELECT EXISTS").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+				rows := sqlmock.NewRows(columns).
+					AddRow(2, id, id, username, core.TodoUpdated,
+						[]byte(`{"completed":{"before":true,"after":false}}`), now).
+					AddRow(1, id, id, username, core.TodoCreated, []byte(`{"title":{"before":"","after":"t"}}`), now)
+				m.ExpectQuery("SELECT (.+) FROM "+todoEventsTable+"(.+)ORDER BY ev.id DESC LIMIT \\$2").
+					WithArgs(id, limit).
 					WillReturnRows(rows)
 			},
-			userID:    id,
-			input:     completed,
-			want:      []uint{id},
+			page: core.TodoPage{Limit: limit},
+			want: []core.TodoEvent{
+				{
+					ID:       2,
+					TodoID:   id,
+					UserID:   id,
+					Username: username,
+					Action:   core.TodoUpdated,
+					Changes: map[string]core.FieldChange{
+						"completed": {Before: true, After: false},
+					},
+					CreatedAt: now,
+				},
+				{
+					ID:       1,
+					TodoID:   id,
+					UserID:   id,
+					Username: username,
+					Action:   core.TodoCreated,
+					Changes: map[string]core.FieldChange{
+						"title": {Before: "", After: "t"},
+					},
+					CreatedAt: now,
+				},
+			},
 			errAssert: assert.NoError,
 		},
 		{
-			name: "no user",
+			name: "after cursor",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"})
-				m.ExpectQuery("DELETE FROM "+todosTable).
-					WithArgs(0, completed).
-					WillReturnRows(rows)
+				m.ExpectQuery("SELECT EXISTS").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+				m.ExpectQuery("WHERE ev.todo_id = \\$1 AND ev.id < \\$2 ORDER BY ev.id DESC LIMIT \\$3").
+					WithArgs(id, 2, limit).
+					WillReturnRows(sqlmock.NewRows(columns))
 			},
-			userID:    0,
-			input:     completed,
+			page:      core.TodoPage{After: 2, Limit: limit},
 			want:      nil,
 			errAssert: assert.NoError,
 		},
 		{
-			name: "no matches",
+			name: "no access",
 			mock: func(m sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id"})
-				m.ExpectQuery("DELETE FROM "+todosTable).
-					WithArgs(id, completed).
-					WillReturnRows(rows)
+				m.ExpectQuery("SELECT EXISTS").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			},
+			page: core.TodoPage{Limit: limit},
+			want: nil,
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrNotFound)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetHistory(context.Background(), id, id, tt.page)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestTodoPostgres_InTransaction(t *testing.T) {
+	const (
+		id    = 1
+		title = "t"
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewTodoPostgres(db)
+
+	stateColumns := []string{
+		"title", "description", "completed", "priority", "due_at", "auto_complete", "recurrence", "deleted_at"}
+	changeColumns := append(append([]string{"id"}, stateColumns...), "user_ids")
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		fn        func(repo TodoRepository) error
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectExec("SAVEPOINT todo_1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectQuery("INSERT INTO "+todosTable).
+					WithArgs(title, "", false, core.PriorityNone, nil, false, "").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+				m.ExpectExec("INSERT INTO "+usersTodosTable).
+					WithArgs(id, id, nil).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoCreated, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectExec("RELEASE SAVEPOINT todo_1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectExec("SAVEPOINT todo_2").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectQuery("SELECT (.+) FROM "+todosTable+" td INNER JOIN (.+) WHERE td.id = \\$1 AND ut.user_id = \\$2 FOR UPDATE OF td").
+					WithArgs(id, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns).
+						AddRow(title, "", false, core.PriorityNone, nil, false, "", nil))
+				m.ExpectQuery("UPDATE "+todosTable).
+					WithArgs(id, id, 0).
+					WillReturnRows(sqlmock.NewRows(changeColumns).
+						AddRow(id, title, "", false, core.PriorityNone, nil, false, "", time.Now(), "{1}"))
+				m.ExpectExec("INSERT INTO "+todoEventsTable).
+					WithArgs(id, id, core.TodoDeleted, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(id, 1))
+				m.ExpectExec("RELEASE SAVEPOINT todo_2").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit()
+			},
+			fn: func(repo TodoRepository) error {
+				if _, err := repo.Create(context.Background(), id, core.Todo{Title: title}); err != nil {
+					return err
+				}
+				_, err := repo.DeleteByID(context.Background(), id, id, 0)
+				return err
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "failed change left out",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectExec("SAVEPOINT todo_1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(0, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns))
+				m.ExpectExec("ROLLBACK TO SAVEPOINT todo_1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit()
+			},
+			fn: func(repo TodoRepository) error {
+				_, err := repo.DeleteByID(context.Background(), id, 0, 0)
+				assert.Error(t, err)
+				return nil
 			},
-			userID:    id,
-			input:     completed,
-			want:      nil,
 			errAssert: assert.NoError,
 		},
+		{
+			name: "rolled back",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectExec("SAVEPOINT todo_1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectQuery("SELECT (.+) FOR UPDATE").
+					WithArgs(0, id).
+					WillReturnRows(sqlmock.NewRows(stateColumns))
+				m.ExpectExec("ROLLBACK TO SAVEPOINT todo_1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectRollback()
+			},
+			fn: func(repo TodoRepository) error {
+				_, err := repo.DeleteByID(context.Background(), id, 0, 0)
+				return err
+			},
+			errAssert: assert.Error,
+		},
 	}
 	for _, tt := range tests {
 		tt.mock(mock)
-		got, err := r.DeleteByCompletion(context.Background(), tt.userID, tt.input)
+		err := r.InTransaction(context.Background(), tt.fn)
 		tt.errAssert(t, err)
-		assert.Equal(t, tt.want, got)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	}
 }
@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/grimerssy/todo-service/internal/core"
 )
@@ -51,3 +53,325 @@ LIMIT 1;
 
 	return user, nil
 }
+
+func (r *UserPostgres) SetFeedToken(ctx context.Context, userID uint, tokenHash string) error {
+	query := fmt.Sprintf(`
+INSERT INTO %s (user_id, token_hash)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET token_hash = EXCLUDED.token_hash,
+    created_at = NOW();
+`, feedTokensTable)
+
+	if _, err := r.db.ExecContext(ctx, query, userID, tokenHash); err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (r *UserPostgres) GetIDByFeedToken(ctx context.Context, tokenHash string) (uint, error) {
+	query := fmt.Sprintf(`
+SELECT user_id FROM %s
+WHERE token_hash = $1;
+`, feedTokensTable)
+
+	var userID uint
+	row := r.db.QueryRowContext(ctx, query, tokenHash)
+	if err := row.Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNotFound
+		}
+		return 0, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return userID, nil
+}
+
+func (r *UserPostgres) DeleteFeedToken(ctx context.Context, userID uint) error {
+	query := fmt.Sprintf(`
+DELETE FROM %s
+WHERE user_id = $1;
+`, feedTokensTable)
+
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *UserPostgres) SetDavPassword(ctx context.Context, userID uint, passwordHash string) error {
+	query := fmt.Sprintf(`
+INSERT INTO %s (user_id, password_hash)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET password_hash = EXCLUDED.password_hash,
+    created_at = NOW();
+`, davPasswordsTable)
+
+	if _, err := r.db.ExecContext(ctx, query, userID, passwordHash); err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (r *UserPostgres) GetIDByDavPassword(ctx context.Context, username, passwordHash string) (uint, error) {
+	query := fmt.Sprintf(`
+SELECT dp.user_id FROM %s dp
+INNER JOIN %s u
+ON u.id = dp.user_id
+WHERE u.username = $1
+    AND dp.password_hash = $2;
+`, davPasswordsTable, usersTable)
+
+	var userID uint
+	row := r.db.QueryRowContext(ctx, query, username, passwordHash)
+	if err := row.Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNotFound
+		}
+		return 0, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return userID, nil
+}
+
+func (r *UserPostgres) DeleteDavPassword(ctx context.Context, userID uint) error {
+	query := fmt.Sprintf(`
+DELETE FROM %s
+WHERE user_id = $1;
+`, davPasswordsTable)
+
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *UserPostgres) CreateRefreshToken(ctx context.Context, userID uint, tokenHash string,
+	expiresAt time.Time) error {
+
+	query := fmt.Sprintf(`
+INSERT INTO %s (user_id, family_id, token_hash, expires_at)
+VALUES ($1, nextval('refresh_token_families'), $2, $3);
+`, refreshTokensTable)
+
+	if _, err := r.db.ExecContext(ctx, query, userID, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return nil
+}
+
+// RotateRefreshToken deletes the whole family of a token that was already
+// rotated, since a copy of it leaked, and returns ErrTokenReused.
+func (r *UserPostgres) RotateRefreshToken(ctx context.Context, tokenHash, newTokenHash string,
+	expiresAt time.Time) (uint, error) {
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not begin transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+UPDATE %s
+SET rotated_at = NOW()
+WHERE token_hash = $1
+  AND rotated_at IS NULL
+  AND expires_at > NOW()
+RETURNING user_id, family_id;
+`, refreshTokensTable)
+
+	var userID, familyID uint
+	err = tx.QueryRowContext(ctx, query, tokenHash).Scan(&userID, &familyID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, r.deleteRotatedFamily(ctx, tx, tokenHash)
+	case err != nil:
+		return 0, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	query = fmt.Sprintf(`
+INSERT INTO %s (user_id, family_id, token_hash, expires_at)
+VALUES ($1, $2, $3, $4);
+`, refreshTokensTable)
+
+	if _, err := tx.ExecContext(ctx, query, userID, familyID, newTokenHash, expiresAt); err != nil {
+		return 0, fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return userID, nil
+}
+
+// deleteRotatedFamily returns ErrNotFound if the token has not been rotated.
+func (r *UserPostgres) deleteRotatedFamily(ctx context.Context, tx *sql.Tx, tokenHash string) error {
+	query := fmt.Sprintf(`
+DELETE FROM %s
+WHERE family_id = (
+    SELECT family_id FROM %s
+    WHERE token_hash = $1
+      AND rotated_at IS NOT NULL
+);
+`, refreshTokensTable, refreshTokensTable)
+
+	result, err := tx.ExecContext(ctx, query, tokenHash)
+	if err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return ErrNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return ErrTokenReused
+}
+
+func (r *UserPostgres) DeleteRefreshTokenFamily(ctx context.Context, userID uint, tokenHash string) error {
+	query := fmt.Sprintf(`
+DELETE FROM %s
+WHERE family_id = (
+    SELECT family_id FROM %s
+    WHERE token_hash = $1
+      AND user_id = $2
+);
+`, refreshTokensTable, refreshTokensTable)
+
+	result, err := r.db.ExecContext(ctx, query, tokenHash, userID)
+	if err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *UserPostgres) RevokeToken(ctx context.Context, userID uint, tokenID string, expiresAt time.Time) error {
+	query := fmt.Sprintf(`
+INSERT INTO %s (jti, user_id, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (jti) DO NOTHING;
+`, revokedTokensTable)
+
+	if _, err := r.db.ExecContext(ctx, query, tokenID, userID, expiresAt); err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return nil
+}
+
+// RevokeAllTokens revokes the access tokens issued up to issuedAt. The cutoff
+// never moves back, so an older token cannot undo a later revocation.
+func (r *UserPostgres) RevokeAllTokens(ctx context.Context, userID uint, issuedAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+UPDATE %s
+SET tokens_revoked_at = GREATEST(tokens_revoked_at, $2)
+WHERE id = $1;
+`, usersTable)
+
+	result, err := tx.ExecContext(ctx, query, userID, issuedAt)
+	if err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return ErrNotFound
+	}
+
+	query = fmt.Sprintf(`
+DELETE FROM %s
+WHERE user_id = $1;
+`, refreshTokensTable)
+
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %s", err.Error())
+	}
+
+	return nil
+}
+
+// IsTokenRevoked compares the issued-at of the token with the one of the
+// token that revoked all of them, so both have the same precision.
+func (r *UserPostgres) IsTokenRevoked(ctx context.Context, userID uint, tokenID string,
+	issuedAt time.Time) (bool, error) {
+
+	query := fmt.Sprintf(`
+SELECT EXISTS (
+    SELECT 1 FROM %s
+    WHERE jti = $1
+) OR EXISTS (
+    SELECT 1 FROM %s
+    WHERE id = $2
+      AND tokens_revoked_at >= $3
+);
+`, revokedTokensTable, usersTable)
+
+	var revoked bool
+	row := r.db.QueryRowContext(ctx, query, tokenID, userID, issuedAt)
+	if err := row.Scan(&revoked); err != nil {
+		return false, fmt.Errorf("could not scan row: %s", err.Error())
+	}
+
+	return revoked, nil
+}
+
+// PruneRevokedTokens removes expired revocations and refresh token families.
+func (r *UserPostgres) PruneRevokedTokens(ctx context.Context) error {
+	query := fmt.Sprintf(`
+DELETE FROM %s
+WHERE expires_at <= NOW();
+`, revokedTokensTable)
+
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	query = fmt.Sprintf(`
+DELETE FROM %[1]s
+WHERE family_id IN (
+    SELECT family_id FROM %[1]s
+    GROUP BY family_id
+    HAVING MAX(expires_at) <= NOW()
+);
+`, refreshTokensTable)
+
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("could not execute query: %s", err.Error())
+	}
+
+	return nil
+}
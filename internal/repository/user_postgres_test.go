@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/grimerssy/todo-service/internal/core"
@@ -134,3 +136,688 @@ func TestUserPostgres_GetCredentialsByUsername(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	}
 }
+
+func TestUserPostgres_SetFeedToken(t *testing.T) {
+	const (
+		userID    = 1
+		tokenHash = "hash"
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+feedTokensTable+" (.+) ON CONFLICT \\(user_id\\) DO UPDATE").
+					WithArgs(userID, tokenHash).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "fail to insert",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+feedTokensTable).
+					WithArgs(userID, tokenHash).
+					WillReturnError(errors.New(""))
+			},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.SetFeedToken(context.Background(), userID, tokenHash)
+		tt.errAssert(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestUserPostgres_GetIDByFeedToken(t *testing.T) {
+	const (
+		userID    = 1
+		tokenHash = "hash"
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		want      uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_id"}).
+					AddRow(userID)
+				m.ExpectQuery("SELECT user_id FROM " + feedTokensTable).
+					WithArgs(tokenHash).
+					WillReturnRows(rows)
+			},
+			want:      userID,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "unknown token",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_id"})
+				m.ExpectQuery("SELECT user_id FROM " + feedTokensTable).
+					WithArgs(tokenHash).
+					WillReturnRows(rows)
+			},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrNotFound)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetIDByFeedToken(context.Background(), tokenHash)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestUserPostgres_DeleteFeedToken(t *testing.T) {
+	const userID = 1
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("DELETE FROM " + feedTokensTable).
+					WithArgs(userID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no token",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("DELETE FROM " + feedTokensTable).
+					WithArgs(userID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrNotFound)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.DeleteFeedToken(context.Background(), userID)
+		tt.errAssert(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestUserPostgres_SetDavPassword(t *testing.T) {
+	const (
+		userID       = 1
+		passwordHash = "hash"
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+davPasswordsTable+" (.+) ON CONFLICT \\(user_id\\) DO UPDATE").
+					WithArgs(userID, passwordHash).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "fail to insert",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+davPasswordsTable).
+					WithArgs(userID, passwordHash).
+					WillReturnError(errors.New(""))
+			},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.SetDavPassword(context.Background(), userID, passwordHash)
+		tt.errAssert(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestUserPostgres_GetIDByDavPassword(t *testing.T) {
+	const (
+		userID       = 1
+		username     = "username"
+		passwordHash = "hash"
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		want      uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_id"}).
+					AddRow(userID)
+				m.ExpectQuery("SELECT dp.user_id FROM "+davPasswordsTable+" dp INNER JOIN "+usersTable).
+					WithArgs(username, passwordHash).
+					WillReturnRows(rows)
+			},
+			want:      userID,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "unknown password",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_id"})
+				m.ExpectQuery("SELECT dp.user_id FROM "+davPasswordsTable).
+					WithArgs(username, passwordHash).
+					WillReturnRows(rows)
+			},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrNotFound)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.GetIDByDavPassword(context.Background(), username, passwordHash)
+		tt.errAssert(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestUserPostgres_DeleteDavPassword(t *testing.T) {
+	const userID = 1
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("DELETE FROM " + davPasswordsTable).
+					WithArgs(userID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no password",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("DELETE FROM " + davPasswordsTable).
+					WithArgs(userID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrNotFound)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.DeleteDavPassword(context.Background(), userID)
+		tt.errAssert(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestUserPostgres_CreateRefreshToken(t *testing.T) {
+	const (
+		userID    = 1
+		tokenHash = "hash"
+	)
+	expiresAt := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+refreshTokensTable+" (.+) nextval").
+					WithArgs(userID, tokenHash, expiresAt).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "fail to insert",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+refreshTokensTable).
+					WithArgs(userID, tokenHash, expiresAt).
+					WillReturnError(errors.New(""))
+			},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.CreateRefreshToken(context.Background(), userID, tokenHash, expiresAt)
+		tt.errAssert(t, err, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestUserPostgres_RotateRefreshToken(t *testing.T) {
+	const (
+		userID       = 1
+		familyID     = 2
+		tokenHash    = "hash"
+		newTokenHash = "new hash"
+	)
+	expiresAt := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		want      uint
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_id", "family_id"}).AddRow(userID, familyID)
+				m.ExpectBegin()
+				m.ExpectQuery("UPDATE " + refreshTokensTable + " SET rotated_at").
+					WithArgs(tokenHash).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+refreshTokensTable).
+					WithArgs(userID, familyID, newTokenHash, expiresAt).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				m.ExpectCommit()
+			},
+			want:      userID,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "reused",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("UPDATE " + refreshTokensTable).
+					WithArgs(tokenHash).
+					WillReturnError(sql.ErrNoRows)
+				m.ExpectExec("DELETE FROM " + refreshTokensTable + " WHERE family_id").
+					WithArgs(tokenHash).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit()
+			},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrTokenReused)
+			},
+		},
+		{
+			name: "unknown or expired",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectQuery("UPDATE " + refreshTokensTable).
+					WithArgs(tokenHash).
+					WillReturnError(sql.ErrNoRows)
+				m.ExpectExec("DELETE FROM " + refreshTokensTable).
+					WithArgs(tokenHash).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectRollback()
+			},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrNotFound)
+			},
+		},
+		{
+			name: "fail to insert",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"user_id", "family_id"}).AddRow(userID, familyID)
+				m.ExpectBegin()
+				m.ExpectQuery("UPDATE " + refreshTokensTable).
+					WithArgs(tokenHash).
+					WillReturnRows(rows)
+				m.ExpectExec("INSERT INTO "+refreshTokensTable).
+					WithArgs(userID, familyID, newTokenHash, expiresAt).
+					WillReturnError(errors.New(""))
+				m.ExpectRollback()
+			},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.RotateRefreshToken(context.Background(), tokenHash, newTokenHash, expiresAt)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestUserPostgres_DeleteRefreshTokenFamily(t *testing.T) {
+	const (
+		userID    = 1
+		tokenHash = "hash"
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("DELETE FROM "+refreshTokensTable+" WHERE family_id").
+					WithArgs(tokenHash, userID).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no token",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("DELETE FROM "+refreshTokensTable).
+					WithArgs(tokenHash, userID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrNotFound)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.DeleteRefreshTokenFamily(context.Background(), userID, tokenHash)
+		tt.errAssert(t, err, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestUserPostgres_RevokeToken(t *testing.T) {
+	const (
+		userID  = 1
+		tokenID = "jti"
+	)
+	expiresAt := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+revokedTokensTable+" (.+) ON CONFLICT \\(jti\\) DO NOTHING").
+					WithArgs(tokenID, userID, expiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "fail to insert",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("INSERT INTO "+revokedTokensTable).
+					WithArgs(tokenID, userID, expiresAt).
+					WillReturnError(errors.New(""))
+			},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.RevokeToken(context.Background(), userID, tokenID, expiresAt)
+		tt.errAssert(t, err, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestUserPostgres_RevokeAllTokens(t *testing.T) {
+	const userID = 1
+	issuedAt := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectExec("UPDATE "+usersTable+" SET tokens_revoked_at = GREATEST\\(tokens_revoked_at, \\$2\\)").
+					WithArgs(userID, issuedAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectExec("DELETE FROM " + refreshTokensTable).
+					WithArgs(userID).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit()
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "no user",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectExec("UPDATE "+usersTable).
+					WithArgs(userID, issuedAt).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectRollback()
+			},
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrNotFound)
+			},
+		},
+		{
+			name: "fail to delete refresh tokens",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin()
+				m.ExpectExec("UPDATE "+usersTable).
+					WithArgs(userID, issuedAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectExec("DELETE FROM " + refreshTokensTable).
+					WithArgs(userID).
+					WillReturnError(errors.New(""))
+				m.ExpectRollback()
+			},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.RevokeAllTokens(context.Background(), userID, issuedAt)
+		tt.errAssert(t, err, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestUserPostgres_IsTokenRevoked(t *testing.T) {
+	const (
+		userID  = 1
+		tokenID = "jti"
+	)
+	issuedAt := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		want      bool
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "revoked",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"revoked"}).AddRow(true)
+				m.ExpectQuery("SELECT EXISTS (.+) FROM "+revokedTokensTable+" (.+) tokens_revoked_at >= \\$3").
+					WithArgs(tokenID, userID, issuedAt).
+					WillReturnRows(rows)
+			},
+			want:      true,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "not revoked",
+			mock: func(m sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"revoked"}).AddRow(false)
+				m.ExpectQuery("SELECT EXISTS").
+					WithArgs(tokenID, userID, issuedAt).
+					WillReturnRows(rows)
+			},
+			want:      false,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "fail to query",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("SELECT EXISTS").
+					WithArgs(tokenID, userID, issuedAt).
+					WillReturnError(errors.New(""))
+			},
+			want:      false,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		got, err := r.IsTokenRevoked(context.Background(), userID, tokenID, issuedAt)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestUserPostgres_PruneRevokedTokens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := NewUserPostgres(db)
+
+	tests := []struct {
+		name      string
+		mock      func(m sqlmock.Sqlmock)
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("DELETE FROM " + revokedTokensTable + " WHERE expires_at <= NOW\\(\\)").
+					WillReturnResult(sqlmock.NewResult(0, 5))
+				m.ExpectExec("DELETE FROM " + refreshTokensTable + " WHERE family_id IN \\((.+) " +
+					"HAVING MAX\\(expires_at\\) <= NOW\\(\\)").
+					WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "fail to delete",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("DELETE FROM " + revokedTokensTable).
+					WillReturnError(errors.New(""))
+			},
+			errAssert: assert.Error,
+		},
+		{
+			name: "fail to delete refresh tokens",
+			mock: func(m sqlmock.Sqlmock) {
+				m.ExpectExec("DELETE FROM " + revokedTokensTable).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectExec("DELETE FROM " + refreshTokensTable).
+					WillReturnError(errors.New(""))
+			},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		tt.mock(mock)
+		err := r.PruneRevokedTokens(context.Background())
+		tt.errAssert(t, err, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/internal/repository"
+	"github.com/grimerssy/todo-service/pkg/broker"
+	"github.com/grimerssy/todo-service/pkg/cache"
+	"github.com/grimerssy/todo-service/pkg/encoding"
+)
+
+const (
+	maxProjectNameLength = 64
+)
+
+var projectColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type ProjectEncoded struct {
+	generations    *cache.Generations
+	broker         broker.Broker
+	userEncoder    encoding.Encoder
+	projectEncoder encoding.Encoder
+	repository     repository.ProjectRepository
+}
+
+func NewProjectEncoded(generations *cache.Generations, broker broker.Broker,
+	userEncoder, projectEncoder encoding.Encoder, repository repository.ProjectRepository) *ProjectEncoded {
+
+	return &ProjectEncoded{
+		generations:    generations,
+		broker:         broker,
+		userEncoder:    userEncoder,
+		projectEncoder: projectEncoder,
+		repository:     repository,
+	}
+}
+
+func (s *ProjectEncoded) Create(ctx context.Context, userID any, projectReq core.ProjectRequest) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	project, err := s.requestToProject(projectReq)
+	if err != nil {
+		return fmt.Errorf("could not convert request to project: %s", err.Error())
+	}
+
+	switch err := s.repository.Create(ctx, uintUserID, project); err {
+	case nil:
+		return nil
+	case repository.ErrAlreadyExists:
+		return ErrProjectAlreadyExists
+	default:
+		return fmt.Errorf("could not create project: %s", err.Error())
+	}
+}
+
+func (s *ProjectEncoded) GetByID(ctx context.Context, userID, projectID any) (core.ProjectResponse, error) {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return core.ProjectResponse{}, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintProjectID, err := s.projectEncoder.DecodeID(projectID)
+	if err != nil {
+		return core.ProjectResponse{}, fmt.Errorf("could not decode project id: %s", err.Error())
+	}
+
+	project, err := s.repository.GetByID(ctx, uintUserID, uintProjectID)
+	if err != nil {
+		return core.ProjectResponse{}, ErrProjectNotFound
+	}
+
+	return s.projectToResponse(projectID, project), nil
+}
+
+func (s *ProjectEncoded) GetAll(ctx context.Context, userID any) ([]core.ProjectResponse, error) {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	projects, err := s.repository.GetAll(ctx, uintUserID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get projects: %s", err.Error())
+	}
+
+	responses := make([]core.ProjectResponse, len(projects))
+
+	for i, project := range projects {
+		projectID, err := s.projectEncoder.EncodeID(project.ID)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode project id: %s", err.Error())
+		}
+
+		responses[i] = s.projectToResponse(projectID, project)
+	}
+
+	return responses, nil
+}
+
+func (s *ProjectEncoded) UpdateByID(ctx context.Context, userID, projectID any,
+	projectReq core.ProjectRequest) error {
+
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	project, err := s.requestToProject(projectReq)
+	if err != nil {
+		return fmt.Errorf("could not convert request to project: %s", err.Error())
+	}
+
+	uintProjectID, err := s.projectEncoder.DecodeID(projectID)
+	if err != nil {
+		return fmt.Errorf("could not decode project id: %s", err.Error())
+	}
+
+	switch _, err := s.repository.UpdateByID(ctx, uintUserID, uintProjectID, project); err {
+	case nil:
+		return nil
+	case repository.ErrAlreadyExists:
+		return ErrProjectAlreadyExists
+	default:
+		return ErrProjectNotFound
+	}
+}
+
+func (s *ProjectEncoded) DeleteByID(ctx context.Context, userID, projectID any, deleteTodos bool) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintProjectID, err := s.projectEncoder.DecodeID(projectID)
+	if err != nil {
+		return fmt.Errorf("could not decode project id: %s", err.Error())
+	}
+
+	userIDs, err := s.repository.DeleteByID(ctx, uintUserID, uintProjectID, deleteTodos)
+	if err != nil {
+		return ErrProjectNotFound
+	}
+
+	// The todos of the project are moved or deleted for all of these users.
+	s.generations.Increment(userIDs...)
+	for _, id := range userIDs {
+		s.broker.Publish(id, string(core.TodoChangeReset), nil)
+	}
+
+	return nil
+}
+
+func (*ProjectEncoded) requestToProject(req core.ProjectRequest) (core.Project, error) {
+	var project core.Project
+
+	name := strings.TrimSpace(req.Name)
+
+	if len(name) == 0 {
+		return project, errors.New("empty name")
+	}
+	if len(name) > maxProjectNameLength {
+		return project, errors.New("name is too long")
+	}
+	if len(req.Color) != 0 && !projectColorPattern.MatchString(req.Color) {
+		return project, errors.New("color must be a #rrggbb hex value")
+	}
+
+	project = core.Project{
+		Name:     name,
+		Color:    strings.ToLower(req.Color),
+		Archived: req.Archived,
+	}
+
+	return project, nil
+}
+
+func (*ProjectEncoded) projectToResponse(projectID any, project core.Project) core.ProjectResponse {
+	return core.ProjectResponse{
+		ID:       projectID,
+		Name:     project.Name,
+		Color:    project.Color,
+		Archived: project.Archived,
+	}
+}
@@ -3,33 +3,129 @@ package service
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/pkg/auth"
+	"github.com/grimerssy/todo-service/pkg/export"
+	"github.com/grimerssy/todo-service/pkg/ical"
 )
 
 var (
-	ErrTodoNotFound = errors.New("todo does not exist")
-	ErrUserNotFound = errors.New("user does not exist")
+	ErrTodoNotFound           = errors.New("todo does not exist")
+	ErrTodoModified           = errors.New("todo has been modified")
+	ErrMalformedPatch         = errors.New("malformed patch")
+	ErrInvalidPatch           = errors.New("patch cannot be applied")
+	ErrPatchTestFailed        = errors.New("patch test failed")
+	ErrInvalidOperation       = errors.New("invalid operation")
+	ErrBatchAborted           = errors.New("batch aborted")
+	ErrTodoNotRecurring       = errors.New("todo is not recurring")
+	ErrInvalidCursor          = errors.New("invalid cursor")
+	ErrUserNotFound           = errors.New("user does not exist")
+	ErrItemNotFound           = errors.New("item does not exist")
+	ErrTagNotFound            = errors.New("tag does not exist")
+	ErrTagAlreadyExists       = errors.New("tag already exists")
+	ErrTodoOrTagNotFound      = errors.New("todo or tag does not exist")
+	ErrProjectNotFound        = errors.New("project does not exist")
+	ErrProjectAlreadyExists   = errors.New("project already exists")
+	ErrTodoOrProjectNotFound  = errors.New("todo or project does not exist")
+	ErrTodoOrUserNotFound     = errors.New("todo or user does not exist")
+	ErrCollaboratorNotFound   = errors.New("collaborator does not exist")
+	ErrFeedTokenNotFound      = errors.New("feed token does not exist")
+	ErrDavPasswordNotFound    = errors.New("dav password does not exist")
+	ErrInvalidDavCredentials  = errors.New("dav credentials are invalid")
+	ErrInvalidRefreshToken    = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused     = errors.New("refresh token has already been used")
+	ErrInvalidToken           = errors.New("token is invalid")
+	ErrTokenRevoked           = errors.New("token has been revoked")
+	ErrInvalidCalendarObject  = errors.New("invalid calendar object")
+	ErrCalendarObjectConflict = errors.New("calendar object name is taken")
+	ErrKeySetNotPublished     = errors.New("key set is not published")
 )
 
 type Services struct {
 	UserService
 	TodoService
+	TagService
+	ProjectService
 }
 
 type UserService interface {
 	SignUp(ctx context.Context, userReq core.UserRequest) error
-	SignIn(ctx context.Context, userReq core.UserRequest) (string, error)
+	SignIn(ctx context.Context, userReq core.UserRequest) (core.Tokens, error)
+	Refresh(ctx context.Context, refreshToken string) (core.Tokens, error)
+	Authenticate(ctx context.Context, username, password string) (any, error)
 	GetID(ctx context.Context, token string) (any, error)
+	GetExpiry(ctx context.Context, token string) (time.Time, error)
+	SignOut(ctx context.Context, accessToken, refreshToken string) error
+	SignOutAll(ctx context.Context, accessToken string) error
+	PruneRevokedTokens(ctx context.Context) error
+	GetKeySet() (auth.JWKSet, error)
+	CreateFeedToken(ctx context.Context, userID any) (string, error)
+	GetIDByFeedToken(ctx context.Context, feedToken string) (any, error)
+	RevokeFeedToken(ctx context.Context, userID any) error
+	CreateDavPassword(ctx context.Context, userID any) (string, error)
+	AuthenticateDav(ctx context.Context, username, password string) (any, error)
+	RevokeDavPassword(ctx context.Context, userID any) error
 }
 
 type TodoService interface {
 	Create(ctx context.Context, userID any, todoReq core.TodoRequest) error
 	GetByID(ctx context.Context, userID, todoID any) (core.TodoResponse, error)
-	GetByCompletion(ctx context.Context, userID any, completed bool) ([]core.TodoResponse, error)
-	GetAll(ctx context.Context, userID any) ([]core.TodoResponse, error)
-	UpdateByID(ctx context.Context, userID, todoID any, todoReq core.TodoRequest) error
-	PatchByID(ctx context.Context, userID, todoID any, todoReq core.TodoRequest) error
-	DeleteByID(ctx context.Context, userID, todoID any) error
+	GetByCompletion(ctx context.Context, userID any, completed bool, sort core.TodoSort,
+		pageReq core.TodoPageRequest) (core.TodoPageResponse, error)
+	GetAll(ctx context.Context, userID any, filter core.TodoFilter, sort core.TodoSort,
+		pageReq core.TodoPageRequest) (core.TodoPageResponse, error)
+	GetOverdue(ctx context.Context, userID any) ([]core.TodoResponse, error)
+	GetByProject(ctx context.Context, userID, projectID any, sort core.TodoSort) ([]core.TodoResponse, error)
+	GetDueToday(ctx context.Context, userID any, location *time.Location) ([]core.TodoResponse, error)
+	GetDueWithin(ctx context.Context, userID any, days uint) ([]core.TodoResponse, error)
+	Search(ctx context.Context, userID any, query string, limit uint) ([]core.TodoSearchResponse, error)
+	GetOccurrences(ctx context.Context, userID, todoID any, count uint) ([]time.Time, error)
+	UpdateByID(ctx context.Context, userID, todoID any, todoReq core.TodoRequest, version uint) error
+	PatchByID(ctx context.Context, userID, todoID any, patchReq core.TodoPatchRequest, version uint) error
+	ApplyJSONPatch(ctx context.Context, userID, todoID any, patch []byte, version uint) error
+	DeleteByID(ctx context.Context, userID, todoID any, version uint) error
 	DeleteByCompletion(ctx context.Context, userID any, completed bool) error
+	Batch(ctx context.Context, userID any, batchReq core.TodoBatchRequest) ([]core.TodoOperationResult, error)
+	Import(ctx context.Context, userID any, r export.Reader, dryRun bool) (core.ImportResponse, error)
+	Export(ctx context.Context, userID any, fn func(todo core.TodoResponse) error) error
+	GetCalendar(ctx context.Context, userID any, fn func(todo ical.Todo) error) error
+	GetCalendarObjects(ctx context.Context, userID any, fn func(obj core.CalendarObjectResponse) error) error
+	GetCalendarObject(ctx context.Context, userID any, name string) (core.CalendarObjectResponse, error)
+	PutCalendarObject(ctx context.Context, userID any, name string, data []byte, version uint,
+		createOnly bool) (bool, error)
+	DeleteCalendarObject(ctx context.Context, userID any, name string, version uint) error
+	GetTrash(ctx context.Context, userID any) ([]core.TodoResponse, error)
+	Restore(ctx context.Context, userID, todoID any) error
+	EmptyTrash(ctx context.Context, userID any) error
+	PurgeTrash(ctx context.Context) error
+	Share(ctx context.Context, userID, todoID any, shareReq core.ShareRequest) error
+	GetCollaborators(ctx context.Context, userID, todoID any) ([]core.CollaboratorResponse, error)
+	RevokeAccess(ctx context.Context, userID, todoID, collaboratorID any) error
+	GetHistory(ctx context.Context, userID, todoID any, pageReq core.TodoPageRequest) (core.TodoHistoryResponse, error)
+	CreateItem(ctx context.Context, userID, todoID any, itemReq core.ItemRequest) error
+	GetItemByID(ctx context.Context, userID, todoID, itemID any) (core.ItemResponse, error)
+	GetItems(ctx context.Context, userID, todoID any) ([]core.ItemResponse, error)
+	UpdateItemByID(ctx context.Context, userID, todoID, itemID any, itemReq core.ItemRequest) error
+	DeleteItemByID(ctx context.Context, userID, todoID, itemID any) error
+	SubscribeChanges(ctx context.Context, userID any, lastEventID string) (<-chan core.TodoChangeEvent, error)
+}
+
+type TagService interface {
+	Create(ctx context.Context, userID any, tagReq core.TagRequest) error
+	GetByID(ctx context.Context, userID, tagID any) (core.TagResponse, error)
+	GetAll(ctx context.Context, userID any) ([]core.TagResponse, error)
+	UpdateByID(ctx context.Context, userID, tagID any, tagReq core.TagRequest) error
+	DeleteByID(ctx context.Context, userID, tagID any) error
+	AttachToTodo(ctx context.Context, userID, todoID, tagID any) error
+	DetachFromTodo(ctx context.Context, userID, todoID, tagID any) error
+}
+
+type ProjectService interface {
+	Create(ctx context.Context, userID any, projectReq core.ProjectRequest) error
+	GetByID(ctx context.Context, userID, projectID any) (core.ProjectResponse, error)
+	GetAll(ctx context.Context, userID any) ([]core.ProjectResponse, error)
+	UpdateByID(ctx context.Context, userID, projectID any, projectReq core.ProjectRequest) error
+	DeleteByID(ctx context.Context, userID, projectID any, deleteTodos bool) error
 }
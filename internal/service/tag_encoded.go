@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/internal/repository"
+	"github.com/grimerssy/todo-service/pkg/broker"
+	"github.com/grimerssy/todo-service/pkg/cache"
+	"github.com/grimerssy/todo-service/pkg/encoding"
+)
+
+const (
+	maxTagNameLength = 64
+)
+
+type TagEncoded struct {
+	generations *cache.Generations
+	broker      broker.Broker
+	userEncoder encoding.Encoder
+	todoEncoder encoding.Encoder
+	tagEncoder  encoding.Encoder
+	repository  repository.TagRepository
+}
+
+func NewTagEncoded(generations *cache.Generations, broker broker.Broker,
+	userEncoder, todoEncoder, tagEncoder encoding.Encoder, repository repository.TagRepository) *TagEncoded {
+
+	return &TagEncoded{
+		generations: generations,
+		broker:      broker,
+		userEncoder: userEncoder,
+		todoEncoder: todoEncoder,
+		tagEncoder:  tagEncoder,
+		repository:  repository,
+	}
+}
+
+func (s *TagEncoded) Create(ctx context.Context, userID any, tagReq core.TagRequest) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	tag, err := s.requestToTag(tagReq)
+	if err != nil {
+		return fmt.Errorf("could not convert request to tag: %s", err.Error())
+	}
+
+	switch err := s.repository.Create(ctx, uintUserID, tag); err {
+	case nil:
+		return nil
+	case repository.ErrAlreadyExists:
+		return ErrTagAlreadyExists
+	default:
+		return fmt.Errorf("could not create tag: %s", err.Error())
+	}
+}
+
+func (s *TagEncoded) GetByID(ctx context.Context, userID, tagID any) (core.TagResponse, error) {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return core.TagResponse{}, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintTagID, err := s.tagEncoder.DecodeID(tagID)
+	if err != nil {
+		return core.TagResponse{}, fmt.Errorf("could not decode tag id: %s", err.Error())
+	}
+
+	tag, err := s.repository.GetByID(ctx, uintUserID, uintTagID)
+	if err != nil {
+		return core.TagResponse{}, ErrTagNotFound
+	}
+
+	return core.TagResponse{
+		ID:   tagID,
+		Name: tag.Name,
+	}, nil
+}
+
+func (s *TagEncoded) GetAll(ctx context.Context, userID any) ([]core.TagResponse, error) {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	tags, err := s.repository.GetAll(ctx, uintUserID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get tags: %s", err.Error())
+	}
+
+	responses := make([]core.TagResponse, len(tags))
+
+	for i, tag := range tags {
+		tagID, err := s.tagEncoder.EncodeID(tag.ID)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode tag id: %s", err.Error())
+		}
+
+		responses[i] = core.TagResponse{
+			ID:   tagID,
+			Name: tag.Name,
+		}
+	}
+
+	return responses, nil
+}
+
+func (s *TagEncoded) UpdateByID(ctx context.Context, userID, tagID any, tagReq core.TagRequest) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	tag, err := s.requestToTag(tagReq)
+	if err != nil {
+		return fmt.Errorf("could not convert request to tag: %s", err.Error())
+	}
+
+	uintTagID, err := s.tagEncoder.DecodeID(tagID)
+	if err != nil {
+		return fmt.Errorf("could not decode tag id: %s", err.Error())
+	}
+
+	switch _, err := s.repository.UpdateByID(ctx, uintUserID, uintTagID, tag); err {
+	case nil:
+	case repository.ErrAlreadyExists:
+		return ErrTagAlreadyExists
+	default:
+		return ErrTagNotFound
+	}
+
+	s.generations.Increment(uintUserID)
+	s.broker.Publish(uintUserID, string(core.TodoChangeReset), nil)
+
+	return nil
+}
+
+func (s *TagEncoded) DeleteByID(ctx context.Context, userID, tagID any) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintTagID, err := s.tagEncoder.DecodeID(tagID)
+	if err != nil {
+		return fmt.Errorf("could not decode tag id: %s", err.Error())
+	}
+
+	if _, err := s.repository.DeleteByID(ctx, uintUserID, uintTagID); err != nil {
+		return ErrTagNotFound
+	}
+
+	s.generations.Increment(uintUserID)
+	s.broker.Publish(uintUserID, string(core.TodoChangeReset), nil)
+
+	return nil
+}
+
+func (s *TagEncoded) AttachToTodo(ctx context.Context, userID, todoID, tagID any) error {
+	uintUserID, uintTodoID, uintTagID, err := s.decodeTodoTagIDs(userID, todoID, tagID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repository.AttachToTodo(ctx, uintUserID, uintTodoID, uintTagID); err != nil {
+		return ErrTodoOrTagNotFound
+	}
+
+	s.generations.Increment(uintUserID)
+	s.broker.Publish(uintUserID, string(core.TodoChangeUpdated), uintTodoID)
+
+	return nil
+}
+
+func (s *TagEncoded) DetachFromTodo(ctx context.Context, userID, todoID, tagID any) error {
+	uintUserID, uintTodoID, uintTagID, err := s.decodeTodoTagIDs(userID, todoID, tagID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repository.DetachFromTodo(ctx, uintUserID, uintTodoID, uintTagID); err != nil {
+		return ErrTodoOrTagNotFound
+	}
+
+	s.generations.Increment(uintUserID)
+	s.broker.Publish(uintUserID, string(core.TodoChangeUpdated), uintTodoID)
+
+	return nil
+}
+
+func (s *TagEncoded) decodeTodoTagIDs(userID, todoID, tagID any) (uint, uint, uint, error) {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("could not decode todo id: %s", err.Error())
+	}
+
+	uintTagID, err := s.tagEncoder.DecodeID(tagID)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("could not decode tag id: %s", err.Error())
+	}
+
+	return uintUserID, uintTodoID, uintTagID, nil
+}
+
+func (*TagEncoded) requestToTag(req core.TagRequest) (core.Tag, error) {
+	var tag core.Tag
+
+	name := strings.TrimSpace(req.Name)
+
+	if len(name) == 0 {
+		return tag, errors.New("empty name")
+	}
+	if len(name) > maxTagNameLength {
+		return tag, errors.New("name is too long")
+	}
+
+	tag = core.Tag{
+		Name: name,
+	}
+
+	return tag, nil
+}
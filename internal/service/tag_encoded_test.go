@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/internal/repository"
+	"github.com/grimerssy/todo-service/pkg/broker"
+	"github.com/grimerssy/todo-service/pkg/cache"
+	"github.com/grimerssy/todo-service/pkg/encoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cacheStub map[any]any
+
+func (c cacheStub) SetValue(key, val any) { c[key] = val }
+func (c cacheStub) GetValue(key any) any  { return c[key] }
+func (c cacheStub) RemoveValue(key any)   { delete(c, key) }
+
+func (c cacheStub) RemoveGroup(group any) {
+	for key := range c {
+		if grouped, ok := key.(cache.Grouped); ok && grouped.Group() == group {
+			delete(c, key)
+		}
+	}
+}
+
+type brokerStub struct {
+	broker.Broker
+}
+
+func (brokerStub) Publish(topic any, name string, data any) {}
+
+type tagRepositoryStub struct {
+	repository.TagRepository
+}
+
+func (tagRepositoryStub) UpdateByID(ctx context.Context, userID uint, tagID uint, tag core.Tag) (uint, error) {
+	return userID, nil
+}
+
+func (tagRepositoryStub) DeleteByID(ctx context.Context, userID uint, tagID uint) (uint, error) {
+	return userID, nil
+}
+
+func TestTagEncoded_invalidation(t *testing.T) {
+	userEncoder, err := encoding.NewHashids(encoding.ConfigHashids{}, encoding.UserKey)
+	require.NoError(t, err)
+	tagEncoder, err := encoding.NewHashids(encoding.ConfigHashids{}, encoding.TagKey)
+	require.NoError(t, err)
+
+	userID, err := userEncoder.EncodeID(1)
+	require.NoError(t, err)
+	tagID, err := tagEncoder.EncodeID(1)
+	require.NoError(t, err)
+
+	tagged := cache.TodoCacheKey{UserID: 1, Args: allArgs{tags: "work", tagMatch: core.MatchAnyTag}}
+	other := cache.TodoCacheKey{UserID: 2, Args: allArgs{tags: "work", tagMatch: core.MatchAnyTag}}
+
+	tests := []struct {
+		name   string
+		change func(s *TagEncoded) error
+	}{
+		{
+			name: "rename",
+			change: func(s *TagEncoded) error {
+				return s.UpdateByID(context.Background(), userID, tagID, core.TagRequest{Name: "office"})
+			},
+		},
+		{
+			name: "delete",
+			change: func(s *TagEncoded) error {
+				return s.DeleteByID(context.Background(), userID, tagID)
+			},
+		},
+	}
+	for _, tt := range tests {
+		c := cacheStub{}
+		generations := cache.NewGenerations(cache.ConfigLFU{}, cache.TodoKey, c)
+		generations.SetValue(1, generations.Get(1), tagged, core.TodoPageResponse{})
+		generations.SetValue(2, generations.Get(2), other, core.TodoPageResponse{})
+
+		s := NewTagEncoded(generations, brokerStub{}, userEncoder, nil, tagEncoder, tagRepositoryStub{})
+		require.NoError(t, tt.change(s), tt.name)
+
+		assert.Nil(t, c.GetValue(tagged), tt.name)
+		assert.NotNil(t, c.GetValue(other), tt.name)
+	}
+}
@@ -1,31 +1,130 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/grimerssy/todo-service/internal/core"
 	"github.com/grimerssy/todo-service/internal/repository"
+	"github.com/grimerssy/todo-service/pkg/broker"
 	"github.com/grimerssy/todo-service/pkg/cache"
 	"github.com/grimerssy/todo-service/pkg/encoding"
+	"github.com/grimerssy/todo-service/pkg/export"
+	"github.com/grimerssy/todo-service/pkg/ical"
+	"github.com/grimerssy/todo-service/pkg/jsonpatch"
+	"github.com/grimerssy/todo-service/pkg/rrule"
 )
 
+const (
+	// readWriteAttempts bounds the retries of writes that depend on the state
+	// read before them when no version is given.
+	readWriteAttempts = 3
+	importBatchSize   = 100
+	calendarUIDDomain = "todo-service"
+	// calendarObjectExtension ends the names of CalDAV resources of todos
+	// that were not created over CalDAV.
+	calendarObjectExtension = ".ics"
+)
+
+// calendarPriorities maps priorities onto the 1 to 9 scale of iCalendar,
+// where 1 is the highest.
+var calendarPriorities = map[core.Priority]int{
+	core.PriorityUrgent: 1,
+	core.PriorityHigh:   3,
+	core.PriorityMedium: 5,
+	core.PriorityLow:    9,
+}
+
+var (
+	// errOperationFailed rolls back a batch once one of its operations fails.
+	errOperationFailed = errors.New("operation failed")
+	// errDryRun rolls back the transactions of a dry run.
+	errDryRun = errors.New("dry run")
+)
+
+type ConfigTrash struct {
+	RetentionDays uint
+	PurgeMinutes  time.Duration
+}
+
 type TodoEncoded struct {
-	cache       cache.Cache
-	userEncoder encoding.Encoder
-	todoEncoder encoding.Encoder
-	repository  repository.TodoRepository
+	trashRetention time.Duration
+	cache          cache.Cache
+	generations    *cache.Generations
+	broker         broker.Broker
+	userEncoder    encoding.Encoder
+	todoEncoder    encoding.Encoder
+	itemEncoder    encoding.Encoder
+	projectEncoder encoding.Encoder
+	eventEncoder   encoding.Encoder
+	repository     repository.TodoRepository
+	itemRepository repository.ItemRepository
+}
+
+// todoChange is a change to publish once the transaction making it commits.
+type todoChange struct {
+	change  core.TodoChange
+	todoID  uint
+	userIDs []uint
+}
+
+type allArgs struct {
+	tags     string
+	tagMatch core.TagMatch
+	sort     core.TodoSort
+	page     core.TodoPage
+}
+
+type completionArgs struct {
+	completed bool
+	sort      core.TodoSort
+	page      core.TodoPage
+}
+
+type projectArgs struct {
+	projectID uint
+	sort      core.TodoSort
 }
 
-func NewTodoEncoded(cache cache.Cache, userEncoder, todoEncoder encoding.Encoder,
-	repository repository.TodoRepository) *TodoEncoded {
+type searchArgs struct {
+	query string
+	limit uint
+}
+
+type historyArgs struct {
+	todoID uint
+	page   core.TodoPage
+}
+
+type dueArgs struct {
+	from time.Time
+	to   time.Time
+}
+
+func NewTodoEncoded(cfg ConfigTrash, cache cache.Cache, generations *cache.Generations, broker broker.Broker,
+	userEncoder, todoEncoder, itemEncoder, projectEncoder, eventEncoder encoding.Encoder,
+	repository repository.TodoRepository, itemRepository repository.ItemRepository) *TodoEncoded {
 
 	return &TodoEncoded{
-		cache:       cache,
-		userEncoder: userEncoder,
-		todoEncoder: todoEncoder,
-		repository:  repository,
+		trashRetention: time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+		cache:          cache,
+		generations:    generations,
+		broker:         broker,
+		userEncoder:    userEncoder,
+		todoEncoder:    todoEncoder,
+		itemEncoder:    itemEncoder,
+		projectEncoder: projectEncoder,
+		eventEncoder:   eventEncoder,
+		repository:     repository,
+		itemRepository: itemRepository,
 	}
 }
 
@@ -35,18 +134,35 @@ func (s *TodoEncoded) Create(ctx context.Context, userID any, todoReq core.TodoR
 		return fmt.Errorf("could not decode user id: %s", err.Error())
 	}
 
-	todo, err := s.requestToTodo(todoReq)
+	todoID, err := s.createTodo(ctx, s.repository, uintUserID, todoReq)
 	if err != nil {
-		return fmt.Errorf("could not convert request to todo: %s", err.Error())
+		return err
 	}
 
-	if err := s.repository.Create(ctx, uintUserID, todo); err != nil {
-		return fmt.Errorf("could not create todo: %s", err.Error())
+	s.invalidateUserCache(uintUserID)
+	s.publish(core.TodoChangeCreated, todoID, uintUserID)
+
+	return nil
+}
+
+func (s *TodoEncoded) createTodo(ctx context.Context, repo repository.TodoRepository, userID uint,
+	todoReq core.TodoRequest) (uint, error) {
+
+	todo, err := s.requestToTodo(todoReq)
+	if err != nil {
+		return 0, fmt.Errorf("could not convert request to todo: %s", err.Error())
 	}
 
-	s.invalidateUserCache(uintUserID, nil)
+	todoID, err := repo.Create(ctx, userID, todo)
+	switch err {
+	case nil:
+	case repository.ErrNotFound:
+		return 0, ErrProjectNotFound
+	default:
+		return 0, fmt.Errorf("could not create todo: %s", err.Error())
+	}
 
-	return nil
+	return todoID, nil
 }
 
 func (s *TodoEncoded) GetByID(ctx context.Context, userID, todoID any) (core.TodoResponse, error) {
@@ -60,6 +176,7 @@ func (s *TodoEncoded) GetByID(ctx context.Context, userID, todoID any) (core.Tod
 		return core.TodoResponse{}, fmt.Errorf("could not decode todo id: %s", err.Error())
 	}
 
+	generation := s.generations.Get(uintUserID)
 	cacheKey := cache.TodoCacheKey{
 		UserID: uintUserID,
 		Args:   uintTodoID,
@@ -74,230 +191,1975 @@ func (s *TodoEncoded) GetByID(ctx context.Context, userID, todoID any) (core.Tod
 		return core.TodoResponse{}, ErrTodoNotFound
 	}
 
-	response := core.TodoResponse{
-		ID:          todoID,
-		Title:       todo.Title,
-		Description: todo.Description,
-		Completed:   todo.Completed,
+	response, err := s.todoToResponse(todoID, todo)
+	if err != nil {
+		return core.TodoResponse{}, err
+	}
+
+	s.generations.SetValue(uintUserID, generation, cacheKey, response)
+
+	return response, nil
+}
+
+func (s *TodoEncoded) GetByCompletion(ctx context.Context, userID any, completed bool,
+	sort core.TodoSort, pageReq core.TodoPageRequest) (core.TodoPageResponse, error) {
+
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return core.TodoPageResponse{}, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	page, err := s.requestToTodoPage(pageReq, sort)
+	if err != nil {
+		return core.TodoPageResponse{}, err
+	}
+
+	generation := s.generations.Get(uintUserID)
+	cacheKey := cache.TodoCacheKey{
+		UserID: uintUserID,
+		Args: completionArgs{
+			completed: completed,
+			sort:      sort,
+			page:      page,
+		},
+	}
+
+	if cached := s.cache.GetValue(cacheKey); cached != nil {
+		return cached.(core.TodoPageResponse), nil
+	}
+
+	todos, err := s.repository.GetByCompletion(ctx, uintUserID, completed, sort, lookahead(page))
+	if err != nil {
+		return core.TodoPageResponse{}, fmt.Errorf("could not get todos: %s", err.Error())
+	}
+
+	response, err := s.todosToPage(todos, sort, page.Limit)
+	if err != nil {
+		return core.TodoPageResponse{}, err
+	}
+
+	s.generations.SetValue(uintUserID, generation, cacheKey, response)
+
+	return response, nil
+}
+
+func (s *TodoEncoded) GetAll(ctx context.Context, userID any, filter core.TodoFilter,
+	sort core.TodoSort, pageReq core.TodoPageRequest) (core.TodoPageResponse, error) {
+
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return core.TodoPageResponse{}, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	page, err := s.requestToTodoPage(pageReq, sort)
+	if err != nil {
+		return core.TodoPageResponse{}, err
+	}
+
+	filter.Tags = uniqueSorted(filter.Tags)
+
+	generation := s.generations.Get(uintUserID)
+	cacheKey := cache.TodoCacheKey{
+		UserID: uintUserID,
+		Args: allArgs{
+			tags:     strings.Join(filter.Tags, "\x00"),
+			tagMatch: filter.TagMatch,
+			sort:     sort,
+			page:     page,
+		},
+	}
+
+	if cached := s.cache.GetValue(cacheKey); cached != nil {
+		return cached.(core.TodoPageResponse), nil
+	}
+
+	todos, err := s.repository.GetAll(ctx, uintUserID, filter, sort, lookahead(page))
+	if err != nil {
+		return core.TodoPageResponse{}, fmt.Errorf("could not get todos: %s", err.Error())
+	}
+
+	response, err := s.todosToPage(todos, sort, page.Limit)
+	if err != nil {
+		return core.TodoPageResponse{}, err
 	}
 
-	s.cache.SetValue(cacheKey, response)
+	s.generations.SetValue(uintUserID, generation, cacheKey, response)
 
 	return response, nil
 }
 
-func (s *TodoEncoded) GetByCompletion(ctx context.Context, userID any, completed bool) ([]core.TodoResponse, error) {
+func (s *TodoEncoded) GetByProject(ctx context.Context, userID, projectID any,
+	sort core.TodoSort) ([]core.TodoResponse, error) {
+
 	uintUserID, err := s.userEncoder.DecodeID(userID)
 	if err != nil {
 		return nil, fmt.Errorf("could not decode user id: %s", err.Error())
 	}
 
+	uintProjectID, err := s.projectEncoder.DecodeID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode project id: %s", err.Error())
+	}
+
+	generation := s.generations.Get(uintUserID)
 	cacheKey := cache.TodoCacheKey{
 		UserID: uintUserID,
-		Args:   completed,
+		Args: projectArgs{
+			projectID: uintProjectID,
+			sort:      sort,
+		},
 	}
 
 	if cached := s.cache.GetValue(cacheKey); cached != nil {
 		return cached.([]core.TodoResponse), nil
 	}
 
-	todos, err := s.repository.GetByCompletion(ctx, uintUserID, completed)
+	todos, err := s.repository.GetByProject(ctx, uintUserID, uintProjectID, sort)
 	if err != nil {
 		return nil, fmt.Errorf("could not get todos: %s", err.Error())
 	}
 
-	responses := make([]core.TodoResponse, len(todos))
+	responses, err := s.todosToResponses(todos)
+	if err != nil {
+		return nil, err
+	}
 
-	for i, todo := range todos {
-		todoID, err := s.todoEncoder.EncodeID(todo.ID)
-		if err != nil {
-			return nil, fmt.Errorf("could not encode todo id: %s", err.Error())
-		}
+	s.generations.SetValue(uintUserID, generation, cacheKey, responses)
 
-		responses[i] = core.TodoResponse{
-			ID:          todoID,
-			Title:       todo.Title,
-			Description: todo.Description,
-			Completed:   todo.Completed,
-		}
+	return responses, nil
+}
+
+func (s *TodoEncoded) GetOverdue(ctx context.Context, userID any) ([]core.TodoResponse, error) {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	now := time.Now().UTC().Truncate(time.Minute)
+
+	generation := s.generations.Get(uintUserID)
+	cacheKey := cache.TodoCacheKey{
+		UserID: uintUserID,
+		Args: dueArgs{
+			to: now,
+		},
 	}
 
-	s.cache.SetValue(cacheKey, responses)
+	if cached := s.cache.GetValue(cacheKey); cached != nil {
+		return cached.([]core.TodoResponse), nil
+	}
+
+	todos, err := s.repository.GetOverdue(ctx, uintUserID, now)
+	if err != nil {
+		return nil, fmt.Errorf("could not get todos: %s", err.Error())
+	}
+
+	responses, err := s.todosToResponses(todos)
+	if err != nil {
+		return nil, err
+	}
+
+	s.generations.SetValue(uintUserID, generation, cacheKey, responses)
 
 	return responses, nil
 }
 
-func (s *TodoEncoded) GetAll(ctx context.Context, userID any) ([]core.TodoResponse, error) {
+func (s *TodoEncoded) GetDueToday(ctx context.Context, userID any, location *time.Location) ([]core.TodoResponse, error) {
+	now := time.Now().In(location)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	to := from.AddDate(0, 0, 1)
+
+	return s.getDueBetween(ctx, userID, from, to)
+}
+
+func (s *TodoEncoded) GetDueWithin(ctx context.Context, userID any, days uint) ([]core.TodoResponse, error) {
+	from := time.Now().Truncate(time.Minute)
+	to := from.AddDate(0, 0, int(days))
+
+	return s.getDueBetween(ctx, userID, from, to)
+}
+
+func (s *TodoEncoded) getDueBetween(ctx context.Context, userID any, from, to time.Time) ([]core.TodoResponse, error) {
 	uintUserID, err := s.userEncoder.DecodeID(userID)
 	if err != nil {
 		return nil, fmt.Errorf("could not decode user id: %s", err.Error())
 	}
 
+	from, to = from.UTC(), to.UTC()
+
+	generation := s.generations.Get(uintUserID)
 	cacheKey := cache.TodoCacheKey{
 		UserID: uintUserID,
-		Args:   nil,
+		Args: dueArgs{
+			from: from,
+			to:   to,
+		},
 	}
 
 	if cached := s.cache.GetValue(cacheKey); cached != nil {
 		return cached.([]core.TodoResponse), nil
 	}
 
-	todos, err := s.repository.GetAll(ctx, uintUserID)
+	todos, err := s.repository.GetDueBetween(ctx, uintUserID, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("could not get todos: %s", err.Error())
 	}
 
-	responses := make([]core.TodoResponse, len(todos))
+	responses, err := s.todosToResponses(todos)
+	if err != nil {
+		return nil, err
+	}
 
-	for i, todo := range todos {
-		todoID, err := s.todoEncoder.EncodeID(todo.ID)
+	s.generations.SetValue(uintUserID, generation, cacheKey, responses)
+
+	return responses, nil
+}
+
+func (s *TodoEncoded) Search(ctx context.Context, userID any, query string,
+	limit uint) ([]core.TodoSearchResponse, error) {
+
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	query = strings.TrimSpace(query)
+	if len(query) == 0 {
+		return nil, errors.New("empty search query")
+	}
+
+	generation := s.generations.Get(uintUserID)
+	cacheKey := cache.TodoCacheKey{
+		UserID: uintUserID,
+		Args: searchArgs{
+			query: query,
+			limit: limit,
+		},
+	}
+
+	if cached := s.cache.GetValue(cacheKey); cached != nil {
+		return cached.([]core.TodoSearchResponse), nil
+	}
+
+	results, err := s.repository.Search(ctx, uintUserID, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not search todos: %s", err.Error())
+	}
+
+	responses := make([]core.TodoSearchResponse, len(results))
+
+	for i, result := range results {
+		todoID, err := s.todoEncoder.EncodeID(result.Todo.ID)
 		if err != nil {
 			return nil, fmt.Errorf("could not encode todo id: %s", err.Error())
 		}
 
-		responses[i] = core.TodoResponse{
-			ID:          todoID,
-			Title:       todo.Title,
-			Description: todo.Description,
-			Completed:   todo.Completed,
+		todoRes, err := s.todoToResponse(todoID, result.Todo)
+		if err != nil {
+			return nil, err
+		}
+
+		responses[i] = core.TodoSearchResponse{
+			Todo:               todoRes,
+			Rank:               result.Rank,
+			TitleSnippet:       result.TitleSnippet,
+			DescriptionSnippet: result.DescriptionSnippet,
 		}
 	}
 
-	s.cache.SetValue(cacheKey, responses)
+	s.generations.SetValue(uintUserID, generation, cacheKey, responses)
 
 	return responses, nil
 }
 
-func (s *TodoEncoded) UpdateByID(ctx context.Context, userID, todoID any, todoReq core.TodoRequest) error {
+func (s *TodoEncoded) GetOccurrences(ctx context.Context, userID, todoID any, count uint) ([]time.Time, error) {
 	uintUserID, err := s.userEncoder.DecodeID(userID)
 	if err != nil {
-		return fmt.Errorf("could not decode user id: %s", err.Error())
+		return nil, fmt.Errorf("could not decode user id: %s", err.Error())
 	}
 
-	todo, err := s.requestToTodo(todoReq)
+	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
 	if err != nil {
-		return fmt.Errorf("could not convert request to todo: %s", err.Error())
+		return nil, fmt.Errorf("could not decode todo id: %s", err.Error())
 	}
 
-	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
+	todo, err := s.repository.GetByID(ctx, uintUserID, uintTodoID)
 	if err != nil {
-		return fmt.Errorf("could not decode todo id: %s", err.Error())
+		return nil, ErrTodoNotFound
+	}
+
+	if len(todo.Recurrence) == 0 {
+		return nil, ErrTodoNotRecurring
 	}
 
-	id, err := s.repository.UpdateByID(ctx, uintUserID, uintTodoID, todo)
+	rule, err := rrule.Parse(todo.Recurrence)
 	if err != nil {
-		return ErrTodoNotFound
+		return nil, fmt.Errorf("could not parse recurrence: %s", err.Error())
 	}
 
-	s.invalidateUserCache(uintUserID, []uint{id})
+	start := time.Now().UTC()
+	if todo.DueAt != nil {
+		start = *todo.DueAt
+	}
 
-	return nil
+	return rule.Occurrences(start, count), nil
 }
 
-func (s *TodoEncoded) PatchByID(ctx context.Context, userID, todoID any, todoReq core.TodoRequest) error {
-	uintUserID, err := s.userEncoder.DecodeID(userID)
+// UpdateByID checks the version unless it is zero, as do PatchByID and
+// DeleteByID.
+func (s *TodoEncoded) UpdateByID(ctx context.Context, userID, todoID any, todoReq core.TodoRequest,
+	version uint) error {
+
+	uintUserID, uintTodoID, err := s.decodeTodoIDs(userID, todoID)
 	if err != nil {
-		return fmt.Errorf("could not decode user id: %s", err.Error())
+		return err
 	}
 
-	todo := core.Todo{
-		Title:       todoReq.Title,
-		Description: todoReq.Description,
-		Completed:   todoReq.Completed,
+	userIDs, err := s.updateTodo(ctx, s.repository, uintUserID, uintTodoID, todoReq, version)
+	if err != nil {
+		return err
 	}
 
-	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
+	s.invalidateUserCache(userIDs...)
+	s.publish(core.TodoChangeUpdated, uintTodoID, userIDs...)
+
+	return nil
+}
+
+func (s *TodoEncoded) updateTodo(ctx context.Context, repo repository.TodoRepository, userID, todoID uint,
+	todoReq core.TodoRequest, version uint) ([]uint, error) {
+
+	todo, err := s.requestToTodo(todoReq)
 	if err != nil {
-		return fmt.Errorf("could not decode todo id: %s", err.Error())
+		return nil, fmt.Errorf("could not convert request to todo: %s", err.Error())
 	}
+	todo.Version = version
 
-	id, err := s.repository.PatchByID(ctx, uintUserID, uintTodoID, todo)
-	if err != nil {
-		return ErrTodoNotFound
+	if todoReq.Completed {
+		if err := s.complete(&todo, time.Now().UTC()); err != nil {
+			return nil, fmt.Errorf("could not advance recurrence: %s", err.Error())
+		}
 	}
 
-	s.invalidateUserCache(uintUserID, []uint{id})
+	userIDs, err := repo.UpdateByID(ctx, userID, todoID, todo)
+	if err != nil {
+		if err == repository.ErrVersionMismatch {
+			return nil, ErrTodoModified
+		}
+		if todo.ProjectID != nil {
+			return nil, ErrTodoOrProjectNotFound
+		}
+		return nil, ErrTodoNotFound
+	}
 
-	return nil
+	return userIDs, nil
 }
 
-func (s *TodoEncoded) DeleteByID(ctx context.Context, userID, todoID any) error {
-	uintUserID, err := s.userEncoder.DecodeID(userID)
+// PatchByID applies a JSON Merge Patch, where null resets a field.
+func (s *TodoEncoded) PatchByID(ctx context.Context, userID, todoID any, patchReq core.TodoPatchRequest,
+	version uint) error {
+
+	uintUserID, uintTodoID, err := s.decodeTodoIDs(userID, todoID)
 	if err != nil {
-		return fmt.Errorf("could not decode user id: %s", err.Error())
+		return err
 	}
 
-	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
-	if err != nil {
-		return fmt.Errorf("could not decode todo id: %s", err.Error())
+	if _, err := s.requestToPatch(patchReq); err != nil {
+		return ErrInvalidPatch
 	}
 
-	id, err := s.repository.DeleteByID(ctx, uintUserID, uintTodoID)
+	userIDs, err := s.patchTodo(ctx, s.repository, uintUserID, uintTodoID, patchReq, version)
 	if err != nil {
-		return ErrTodoNotFound
+		return err
 	}
 
-	s.invalidateUserCache(uintUserID, []uint{id})
+	s.invalidateUserCache(userIDs...)
+	s.publish(core.TodoChangeUpdated, uintTodoID, userIDs...)
 
 	return nil
 }
 
-func (s *TodoEncoded) DeleteByCompletion(ctx context.Context, userID any, completed bool) error {
-	uintUserID, err := s.userEncoder.DecodeID(userID)
+func (s *TodoEncoded) patchTodo(ctx context.Context, repo repository.TodoRepository, userID, todoID uint,
+	patchReq core.TodoPatchRequest, version uint) ([]uint, error) {
+
+	patch, err := s.requestToPatch(patchReq)
 	if err != nil {
-		return fmt.Errorf("could not decode user id: %s", err.Error())
+		return nil, fmt.Errorf("could not convert request to patch: %s", err.Error())
+	}
+	patch.Version = version
+
+	if !patch.Completed.Value {
+		return s.writePatch(ctx, repo, userID, todoID, patch)
 	}
 
-	ids, err := s.repository.DeleteByCompletion(ctx, uintUserID, completed)
+	for attempt := 1; ; attempt++ {
+		var userIDs []uint
+		err := repo.InTransaction(ctx, func(repo repository.TodoRepository) error {
+			var err error
+			userIDs, err = s.completeTodo(ctx, repo, userID, todoID, patch)
+			return err
+		})
+		if err != ErrTodoModified || version != 0 || attempt == readWriteAttempts {
+			return userIDs, err
+		}
+	}
+}
+
+// completeTodo writes the patch at the version the todo is read at, so that
+// the todo cannot change in between.
+func (s *TodoEncoded) completeTodo(ctx context.Context, repo repository.TodoRepository, userID, todoID uint,
+	patch core.TodoPatch) ([]uint, error) {
+
+	current, err := repo.GetByID(ctx, userID, todoID)
 	if err != nil {
-		return fmt.Errorf("could not delete todos: %s", err.Error())
+		return nil, ErrTodoNotFound
+	}
+	if patch.Version == 0 {
+		patch.Version = current.Version
+	}
+
+	merged := current
+	if patch.Recurrence.Set {
+		merged.Recurrence = patch.Recurrence.Value
+	}
+	if patch.DueAt.Set {
+		merged.DueAt = patch.DueAt.Value
 	}
 
-	s.invalidateUserCache(uintUserID, ids)
+	if err := s.complete(&merged, time.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("could not advance recurrence: %s", err.Error())
+	}
+	if len(merged.Recurrence) != 0 {
+		patch.Completed = core.Some(merged.Completed)
+		patch.DueAt = core.Some(merged.DueAt)
+		patch.Recurrence = core.Some(merged.Recurrence)
+	}
 
-	return nil
+	return s.writePatch(ctx, repo, userID, todoID, patch)
 }
 
-func (*TodoEncoded) requestToTodo(req core.TodoRequest) (core.Todo, error) {
-	var todo core.Todo
+func (s *TodoEncoded) writePatch(ctx context.Context, repo repository.TodoRepository, userID, todoID uint,
+	patch core.TodoPatch) ([]uint, error) {
 
-	if len(req.Title) == 0 {
-		return todo, errors.New("empty title")
+	userIDs, err := repo.PatchByID(ctx, userID, todoID, patch)
+	if err != nil {
+		if err == repository.ErrVersionMismatch {
+			return nil, ErrTodoModified
+		}
+		if patch.ProjectID.Value != nil {
+			return nil, ErrTodoOrProjectNotFound
+		}
+		return nil, ErrTodoNotFound
 	}
 
-	todo = core.Todo{
-		Title:       req.Title,
-		Description: req.Description,
-		Completed:   false,
+	return userIDs, nil
+}
+
+// ApplyJSONPatch applies a JSON Patch (RFC 6902), retrying without a version
+// if the todo changes meanwhile.
+func (s *TodoEncoded) ApplyJSONPatch(ctx context.Context, userID, todoID any, patchDoc []byte,
+	version uint) error {
+
+	uintUserID, uintTodoID, err := s.decodeTodoIDs(userID, todoID)
+	if err != nil {
+		return err
 	}
 
-	return todo, nil
+	patch, err := jsonpatch.Decode(patchDoc)
+	if err != nil {
+		return ErrMalformedPatch
+	}
+
+	for attempt := 1; ; attempt++ {
+		current, err := s.repository.GetByID(ctx, uintUserID, uintTodoID)
+		if err != nil {
+			return ErrTodoNotFound
+		}
+		if version != 0 && current.Version != version {
+			return ErrTodoModified
+		}
+
+		patchReq, err := s.applyTodoPatch(current, patch)
+		if err != nil {
+			return err
+		}
+
+		userIDs, err := s.patchTodo(ctx, s.repository, uintUserID, uintTodoID, patchReq, current.Version)
+		if err == nil {
+			s.invalidateUserCache(userIDs...)
+			s.publish(core.TodoChangeUpdated, uintTodoID, userIDs...)
+			return nil
+		}
+		if err != ErrTodoModified || version != 0 || attempt == readWriteAttempts {
+			return err
+		}
+	}
 }
 
-func (s *TodoEncoded) invalidateUserCache(userID uint, todoIDs []uint) {
-	cacheKeys := []cache.TodoCacheKey{
-		{
-			UserID: userID,
-			Args:   true,
-		},
-		{
-			UserID: userID,
-			Args:   false,
-		},
-		{
-			UserID: userID,
-			Args:   nil,
-		},
+// applyTodoPatch applies the patch to the todo and validates the result, which
+// is returned as a patch that sets every field.
+func (s *TodoEncoded) applyTodoPatch(todo core.Todo, patch jsonpatch.Patch) (core.TodoPatchRequest, error) {
+	var patchReq core.TodoPatchRequest
+	var todoReq core.TodoRequest
+
+	response, err := s.todoToResponse(nil, todo)
+	if err != nil {
+		return patchReq, err
+	}
+
+	doc, err := json.Marshal(core.TodoRequest{
+		Title:        response.Title,
+		Description:  response.Description,
+		Completed:    response.Completed,
+		Priority:     response.Priority,
+		DueAt:        response.DueAt,
+		AutoComplete: response.AutoComplete,
+		Recurrence:   response.Recurrence,
+		ProjectID:    response.ProjectID,
+	})
+	if err != nil {
+		return patchReq, fmt.Errorf("could not marshal todo: %s", err.Error())
 	}
-	for _, todoID := range todoIDs {
-		cacheKey := cache.TodoCacheKey{
-			UserID: userID,
-			Args:   todoID,
+
+	patched, err := patch.Apply(doc)
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return patchReq, ErrPatchTestFailed
 		}
-		cacheKeys = append(cacheKeys, cacheKey)
+		return patchReq, ErrInvalidPatch
 	}
 
-	for _, key := range cacheKeys {
-		s.cache.RemoveValue(key)
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&todoReq); err != nil {
+		return patchReq, ErrInvalidPatch
 	}
+
+	if _, err := s.requestToTodo(todoReq); err != nil {
+		return patchReq, ErrInvalidPatch
+	}
+
+	patchReq = core.TodoPatchRequest{
+		Title:        core.Some(todoReq.Title),
+		Description:  core.Some(todoReq.Description),
+		Completed:    core.Some(todoReq.Completed),
+		Priority:     core.Some(todoReq.Priority),
+		DueAt:        core.Optional[time.Time]{Set: true, Null: true},
+		AutoComplete: core.Some(todoReq.AutoComplete),
+		Recurrence:   core.Some(todoReq.Recurrence),
+		ProjectID:    core.Some(todoReq.ProjectID),
+	}
+	if todoReq.DueAt != nil {
+		patchReq.DueAt = core.Some(*todoReq.DueAt)
+	}
+
+	return patchReq, nil
+}
+
+func (s *TodoEncoded) DeleteByID(ctx context.Context, userID, todoID any, version uint) error {
+	uintUserID, uintTodoID, err := s.decodeTodoIDs(userID, todoID)
+	if err != nil {
+		return err
+	}
+
+	userIDs, err := s.deleteTodo(ctx, s.repository, uintUserID, uintTodoID, version)
+	if err != nil {
+		return err
+	}
+
+	s.invalidateUserCache(userIDs...)
+	s.publish(core.TodoChangeDeleted, uintTodoID, userIDs...)
+
+	return nil
+}
+
+func (s *TodoEncoded) deleteTodo(ctx context.Context, repo repository.TodoRepository, userID, todoID uint,
+	version uint) ([]uint, error) {
+
+	userIDs, err := repo.DeleteByID(ctx, userID, todoID, version)
+	switch err {
+	case nil:
+	case repository.ErrVersionMismatch:
+		return nil, ErrTodoModified
+	default:
+		return nil, ErrTodoNotFound
+	}
+
+	return userIDs, nil
+}
+
+// Batch runs the operations in a single transaction, rolled back on the first
+// failure unless ContinueOnError is set.
+func (s *TodoEncoded) Batch(ctx context.Context, userID any,
+	batchReq core.TodoBatchRequest) ([]core.TodoOperationResult, error) {
+
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	results := make([]core.TodoOperationResult, len(batchReq.Operations))
+	var changes []todoChange
+
+	err = s.repository.InTransaction(ctx, func(repo repository.TodoRepository) error {
+		for i, op := range batchReq.Operations {
+			todoID, change, err := s.runOperation(ctx, repo, uintUserID, op)
+			results[i] = core.TodoOperationResult{ID: todoID, Err: err}
+			if err != nil {
+				if batchReq.ContinueOnError {
+					continue
+				}
+				return errOperationFailed
+			}
+			changes = append(changes, change)
+		}
+		return nil
+	})
+	switch err {
+	case nil:
+	case errOperationFailed:
+		for i, op := range batchReq.Operations {
+			if results[i].Err == nil {
+				results[i] = core.TodoOperationResult{ID: op.ID, Err: ErrBatchAborted}
+			}
+		}
+		return results, nil
+	default:
+		return nil, fmt.Errorf("could not run batch: %s", err.Error())
+	}
+
+	for _, change := range changes {
+		s.invalidateUserCache(change.userIDs...)
+		s.publish(change.change, change.todoID, change.userIDs...)
+	}
+
+	return results, nil
+}
+
+func (s *TodoEncoded) runOperation(ctx context.Context, repo repository.TodoRepository, userID uint,
+	op core.TodoOperationRequest) (any, todoChange, error) {
+
+	if op.Op == core.BatchCreate {
+		todoID, err := s.createTodo(ctx, repo, userID, op.Todo)
+		if err != nil {
+			return nil, todoChange{}, err
+		}
+
+		encodedID, err := s.todoEncoder.EncodeID(todoID)
+		if err != nil {
+			return nil, todoChange{}, fmt.Errorf("could not encode todo id: %s", err.Error())
+		}
+
+		return encodedID, todoChange{core.TodoChangeCreated, todoID, []uint{userID}}, nil
+	}
+
+	todoID, err := s.todoEncoder.DecodeID(op.ID)
+	if err != nil {
+		return op.ID, todoChange{}, ErrTodoNotFound
+	}
+
+	change := todoChange{change: core.TodoChangeUpdated, todoID: todoID}
+	switch op.Op {
+	case core.BatchUpdate:
+		change.userIDs, err = s.updateTodo(ctx, repo, userID, todoID, op.Todo, op.Version)
+	case core.BatchPatch:
+		change.userIDs, err = s.patchTodo(ctx, repo, userID, todoID, op.Patch, op.Version)
+	case core.BatchComplete:
+		patchReq := core.TodoPatchRequest{Completed: core.Some(true)}
+		change.userIDs, err = s.patchTodo(ctx, repo, userID, todoID, patchReq, op.Version)
+	case core.BatchDelete:
+		change.change = core.TodoChangeDeleted
+		change.userIDs, err = s.deleteTodo(ctx, repo, userID, todoID, op.Version)
+	default:
+		err = ErrInvalidOperation
+	}
+
+	return op.ID, change, err
+}
+
+func (s *TodoEncoded) DeleteByCompletion(ctx context.Context, userID any, completed bool) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	userIDs, err := s.repository.DeleteByCompletion(ctx, uintUserID, completed)
+	if err != nil {
+		return fmt.Errorf("could not delete todos: %s", err.Error())
+	}
+
+	s.invalidateUserCache(userIDs...)
+	s.publishReset(userIDs...)
+
+	return nil
+}
+
+// Import skips the records of todos the user already has, so that an export
+// can be imported again. A dry run rolls back every transaction.
+func (s *TodoEncoded) Import(ctx context.Context, userID any, r export.Reader,
+	dryRun bool) (core.ImportResponse, error) {
+
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return core.ImportResponse{}, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	importRes := core.ImportResponse{DryRun: dryRun}
+	fail := func(line int, err error) {
+		importRes.Failed++
+		importRes.Errors = append(importRes.Errors, core.ImportError{Line: line, Error: err.Error()})
+	}
+
+	for done := false; !done; {
+		batchRes := importRes
+		err := s.repository.InTransaction(ctx, func(repo repository.TodoRepository) error {
+			for i := 0; i < importBatchSize && !done; i++ {
+				record, err := r.Read()
+				var recordErr *export.RecordError
+				switch {
+				case err == io.EOF:
+					done = true
+				case errors.As(err, &recordErr):
+					fail(recordErr.Line, recordErr.Err)
+				case err != nil:
+					fail(r.Line(), err)
+					done = true
+				default:
+					created, err := s.importTodo(ctx, repo, uintUserID, record)
+					switch {
+					case err != nil:
+						fail(r.Line(), err)
+					case created:
+						importRes.Created++
+					default:
+						importRes.Skipped++
+					}
+				}
+			}
+			if dryRun {
+				return errDryRun
+			}
+			return nil
+		})
+		if err != nil && err != errDryRun {
+			importRes = batchRes
+			fail(r.Line(), errors.New("could not import todos"))
+			break
+		}
+	}
+
+	if importRes.Created != 0 && !dryRun {
+		s.invalidateUserCache(uintUserID)
+		s.publishReset(uintUserID)
+	}
+
+	return importRes, nil
+}
+
+func (s *TodoEncoded) importTodo(ctx context.Context, repo repository.TodoRepository, userID uint,
+	record export.Todo) (bool, error) {
+
+	if len(record.ID) != 0 {
+		todoID, err := s.todoEncoder.DecodeID(record.ID)
+		if err == nil {
+			if _, err := repo.GetByID(ctx, userID, todoID); err == nil {
+				return false, nil
+			}
+		}
+	}
+
+	var projectID any
+	if len(record.ProjectID) != 0 {
+		projectID = record.ProjectID
+	}
+
+	todo, err := s.requestToTodo(core.TodoRequest{
+		Title:       record.Title,
+		Description: record.Description,
+		Priority:    record.Priority,
+		DueAt:       record.DueAt,
+		Recurrence:  record.Recurrence,
+		ProjectID:   projectID,
+	})
+	if err != nil {
+		return false, err
+	}
+	todo.Completed = record.Completed
+
+	for _, tag := range record.Tags {
+		name := strings.TrimSpace(tag)
+		if len(name) == 0 {
+			continue
+		}
+		if len(name) > maxTagNameLength {
+			return false, errors.New("tag name is too long")
+		}
+		todo.Tags = append(todo.Tags, name)
+	}
+	todo.Tags = uniqueSorted(todo.Tags)
+
+	switch _, err := repo.Create(ctx, userID, todo); err {
+	case nil:
+		return true, nil
+	case repository.ErrNotFound:
+		return false, ErrProjectNotFound
+	default:
+		return false, errors.New("could not create todo")
+	}
+}
+
+func (s *TodoEncoded) Export(ctx context.Context, userID any, fn func(todo core.TodoResponse) error) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	return s.repository.Export(ctx, uintUserID, func(todo core.Todo) error {
+		todoID, err := s.todoEncoder.EncodeID(todo.ID)
+		if err != nil {
+			return fmt.Errorf("could not encode todo id: %s", err.Error())
+		}
+
+		todoRes, err := s.todoToResponse(todoID, todo)
+		if err != nil {
+			return err
+		}
+
+		return fn(todoRes)
+	})
+}
+
+// GetCalendar derives UIDs from todo ids, unless the todo was created over
+// CalDAV with a UID of its own.
+func (s *TodoEncoded) GetCalendar(ctx context.Context, userID any, fn func(todo ical.Todo) error) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	return s.repository.ExportCalendar(ctx, uintUserID, func(obj core.CalendarObject) error {
+		if obj.Todo.DueAt == nil {
+			return nil
+		}
+
+		_, calendarTodo, err := s.objectToCalendar(obj)
+		if err != nil {
+			return err
+		}
+
+		return fn(calendarTodo)
+	})
+}
+
+func (s *TodoEncoded) GetCalendarObjects(ctx context.Context, userID any,
+	fn func(obj core.CalendarObjectResponse) error) error {
+
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	return s.repository.ExportCalendar(ctx, uintUserID, func(obj core.CalendarObject) error {
+		objRes, err := s.objectToResponse(obj)
+		if err != nil {
+			return err
+		}
+
+		return fn(objRes)
+	})
+}
+
+func (s *TodoEncoded) GetCalendarObject(ctx context.Context, userID any,
+	name string) (core.CalendarObjectResponse, error) {
+
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return core.CalendarObjectResponse{}, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	obj, err := s.getCalendarObject(ctx, s.repository, uintUserID, name)
+	if err != nil {
+		return core.CalendarObjectResponse{}, err
+	}
+
+	return s.objectToResponse(obj)
+}
+
+// PutCalendarObject only replaces the fields iCalendar has in common with
+// todos, and reports whether the todo was created. With createOnly, a name
+// that is taken is a conflict instead of being replaced.
+func (s *TodoEncoded) PutCalendarObject(ctx context.Context, userID any, name string, data []byte,
+	version uint, createOnly bool) (bool, error) {
+
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return false, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	calendarTodo, err := ical.Parse(bytes.NewReader(data))
+	if err != nil {
+		return false, ErrInvalidCalendarObject
+	}
+
+	todoReq := calendarToRequest(calendarTodo)
+	todo, err := s.requestToTodo(todoReq)
+	if err != nil {
+		return false, ErrInvalidCalendarObject
+	}
+	todo.Completed = todoReq.Completed
+
+	var change todoChange
+
+	err = s.repository.InTransaction(ctx, func(repo repository.TodoRepository) error {
+		obj, err := s.getCalendarObject(ctx, repo, uintUserID, name)
+		switch {
+		case err == nil && createOnly:
+			return ErrCalendarObjectConflict
+		case err == nil:
+			change = todoChange{change: core.TodoChangeUpdated, todoID: obj.Todo.ID}
+			change.userIDs, err = s.patchTodo(ctx, repo, uintUserID, obj.Todo.ID, requestToCalendarPatch(todoReq), version)
+			return err
+		case err != ErrTodoNotFound:
+			return err
+		case version != 0:
+			return ErrTodoModified
+		}
+
+		todoID, err := repo.Create(ctx, uintUserID, todo)
+		if err != nil {
+			return fmt.Errorf("could not create todo: %s", err.Error())
+		}
+
+		switch err := repo.SetCalendarName(ctx, uintUserID, todoID, name, calendarTodo.UID); err {
+		case nil:
+		case repository.ErrAlreadyExists:
+			return ErrCalendarObjectConflict
+		default:
+			return fmt.Errorf("could not set calendar name: %s", err.Error())
+		}
+
+		change = todoChange{core.TodoChangeCreated, todoID, []uint{uintUserID}}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	s.invalidateUserCache(change.userIDs...)
+	s.publish(change.change, change.todoID, change.userIDs...)
+
+	return change.change == core.TodoChangeCreated, nil
+}
+
+func (s *TodoEncoded) DeleteCalendarObject(ctx context.Context, userID any, name string, version uint) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	obj, err := s.getCalendarObject(ctx, s.repository, uintUserID, name)
+	if err != nil {
+		return err
+	}
+
+	userIDs, err := s.deleteTodo(ctx, s.repository, uintUserID, obj.Todo.ID, version)
+	if err != nil {
+		return err
+	}
+
+	s.invalidateUserCache(userIDs...)
+	s.publish(core.TodoChangeDeleted, obj.Todo.ID, userIDs...)
+
+	return nil
+}
+
+func (s *TodoEncoded) getCalendarObject(ctx context.Context, repo repository.TodoRepository, userID uint,
+	name string) (core.CalendarObject, error) {
+
+	var todoID uint
+	if strings.HasSuffix(name, calendarObjectExtension) {
+		if decoded, err := s.todoEncoder.DecodeID(strings.TrimSuffix(name, calendarObjectExtension)); err == nil {
+			todoID = decoded
+		}
+	}
+
+	obj, err := repo.GetCalendarObject(ctx, userID, name, todoID)
+	switch err {
+	case nil:
+		return obj, nil
+	case repository.ErrNotFound:
+		return core.CalendarObject{}, ErrTodoNotFound
+	default:
+		return core.CalendarObject{}, fmt.Errorf("could not get calendar object: %s", err.Error())
+	}
+}
+
+func (s *TodoEncoded) objectToCalendar(obj core.CalendarObject) (string, ical.Todo, error) {
+	name, uid := obj.Name, obj.UID
+	if len(name) == 0 {
+		todoID, err := s.todoEncoder.EncodeID(obj.Todo.ID)
+		if err != nil {
+			return "", ical.Todo{}, fmt.Errorf("could not encode todo id: %s", err.Error())
+		}
+		name = fmt.Sprintf("%v%s", todoID, calendarObjectExtension)
+		uid = fmt.Sprintf("%v@%s", todoID, calendarUIDDomain)
+	}
+
+	todo := obj.Todo
+	calendarTodo := ical.Todo{
+		UID:          uid,
+		Summary:      todo.Title,
+		Description:  todo.Description,
+		Categories:   todo.Tags,
+		Priority:     calendarPriorities[todo.Priority],
+		RRule:        todo.Recurrence,
+		Completed:    todo.Completed,
+		Created:      todo.CreatedAt,
+		LastModified: todo.UpdatedAt,
+		Stamp:        todo.UpdatedAt,
+	}
+	if todo.DueAt != nil {
+		calendarTodo.Due = *todo.DueAt
+	}
+	if todo.Completed {
+		calendarTodo.CompletedAt = todo.UpdatedAt
+	}
+
+	return name, calendarTodo, nil
+}
+
+func (s *TodoEncoded) objectToResponse(obj core.CalendarObject) (core.CalendarObjectResponse, error) {
+	name, calendarTodo, err := s.objectToCalendar(obj)
+	if err != nil {
+		return core.CalendarObjectResponse{}, err
+	}
+
+	var data bytes.Buffer
+	w := ical.NewWriter(&data, "")
+	if err := w.Write(calendarTodo); err != nil {
+		return core.CalendarObjectResponse{}, fmt.Errorf("could not write calendar object: %s", err.Error())
+	}
+	if err := w.Close(); err != nil {
+		return core.CalendarObjectResponse{}, fmt.Errorf("could not write calendar object: %s", err.Error())
+	}
+
+	return core.CalendarObjectResponse{
+		Name:    name,
+		Version: obj.Todo.Version,
+		Data:    data.Bytes(),
+	}, nil
+}
+
+// calendarToRequest maps the priorities of iCalendar back the way RFC 5545
+// groups them, with 1 being urgent.
+func calendarToRequest(calendarTodo ical.Todo) core.TodoRequest {
+	priority := core.PriorityNone
+	switch p := calendarTodo.Priority; {
+	case p == 1:
+		priority = core.PriorityUrgent
+	case p >= 2 && p <= 4:
+		priority = core.PriorityHigh
+	case p == 5:
+		priority = core.PriorityMedium
+	case p >= 6:
+		priority = core.PriorityLow
+	}
+
+	todoReq := core.TodoRequest{
+		Title:       calendarTodo.Summary,
+		Description: calendarTodo.Description,
+		Completed:   calendarTodo.Completed,
+		Priority:    priority.String(),
+		Recurrence:  calendarTodo.RRule,
+	}
+	if !calendarTodo.Due.IsZero() {
+		due := calendarTodo.Due
+		todoReq.DueAt = &due
+	}
+
+	return todoReq
+}
+
+func requestToCalendarPatch(todoReq core.TodoRequest) core.TodoPatchRequest {
+	patchReq := core.TodoPatchRequest{
+		Title:       core.Some(todoReq.Title),
+		Description: core.Some(todoReq.Description),
+		Completed:   core.Some(todoReq.Completed),
+		Priority:    core.Some(todoReq.Priority),
+		DueAt:       core.Optional[time.Time]{Set: true, Null: true},
+		Recurrence:  core.Some(todoReq.Recurrence),
+	}
+	if todoReq.DueAt != nil {
+		patchReq.DueAt = core.Some(*todoReq.DueAt)
+	}
+
+	return patchReq
+}
+
+func (s *TodoEncoded) GetTrash(ctx context.Context, userID any) ([]core.TodoResponse, error) {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	todos, err := s.repository.GetTrash(ctx, uintUserID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get trash: %s", err.Error())
+	}
+
+	return s.todosToResponses(todos)
+}
+
+func (s *TodoEncoded) Restore(ctx context.Context, userID, todoID any) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
+	if err != nil {
+		return fmt.Errorf("could not decode todo id: %s", err.Error())
+	}
+
+	userIDs, err := s.repository.Restore(ctx, uintUserID, uintTodoID)
+	if err != nil {
+		return ErrTodoNotFound
+	}
+
+	s.invalidateUserCache(userIDs...)
+	s.publish(core.TodoChangeCreated, uintTodoID, userIDs...)
+
+	return nil
+}
+
+func (s *TodoEncoded) EmptyTrash(ctx context.Context, userID any) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	if err := s.repository.EmptyTrash(ctx, uintUserID); err != nil {
+		return fmt.Errorf("could not empty trash: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (s *TodoEncoded) PurgeTrash(ctx context.Context) error {
+	before := time.Now().Add(-s.trashRetention)
+
+	userIDs, err := s.repository.Purge(ctx, before)
+	if err != nil {
+		return fmt.Errorf("could not purge trash: %s", err.Error())
+	}
+
+	s.invalidateUserCache(userIDs...)
+
+	return nil
+}
+
+func (s *TodoEncoded) Share(ctx context.Context, userID, todoID any, shareReq core.ShareRequest) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
+	if err != nil {
+		return fmt.Errorf("could not decode todo id: %s", err.Error())
+	}
+
+	role, err := core.ParseRole(shareReq.Role)
+	if err != nil {
+		return fmt.Errorf("could not parse role: %s", err.Error())
+	}
+
+	username := strings.TrimSpace(shareReq.Username)
+	if len(username) == 0 {
+		return errors.New("empty username")
+	}
+
+	collaboratorID, err := s.repository.Share(ctx, uintUserID, uintTodoID, username, role)
+	switch err {
+	case nil:
+	case repository.ErrNotFound:
+		return ErrTodoOrUserNotFound
+	default:
+		return fmt.Errorf("could not share todo: %s", err.Error())
+	}
+
+	s.invalidateUserCache(uintUserID, collaboratorID)
+	s.publish(core.TodoChangeCreated, uintTodoID, collaboratorID)
+
+	return nil
+}
+
+func (s *TodoEncoded) GetCollaborators(ctx context.Context, userID, todoID any) ([]core.CollaboratorResponse, error) {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode todo id: %s", err.Error())
+	}
+
+	collaborators, err := s.repository.GetCollaborators(ctx, uintUserID, uintTodoID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get collaborators: %s", err.Error())
+	}
+	if len(collaborators) == 0 {
+		return nil, ErrTodoNotFound
+	}
+
+	responses := make([]core.CollaboratorResponse, len(collaborators))
+
+	for i, collaborator := range collaborators {
+		collaboratorID, err := s.userEncoder.EncodeID(collaborator.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode user id: %s", err.Error())
+		}
+
+		responses[i] = core.CollaboratorResponse{
+			ID:       collaboratorID,
+			Username: collaborator.Username,
+			Role:     collaborator.Role,
+		}
+	}
+
+	return responses, nil
+}
+
+func (s *TodoEncoded) RevokeAccess(ctx context.Context, userID, todoID, collaboratorID any) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
+	if err != nil {
+		return fmt.Errorf("could not decode todo id: %s", err.Error())
+	}
+
+	uintCollaboratorID, err := s.userEncoder.DecodeID(collaboratorID)
+	if err != nil {
+		return fmt.Errorf("could not decode collaborator id: %s", err.Error())
+	}
+
+	if err := s.repository.RevokeAccess(ctx, uintUserID, uintTodoID, uintCollaboratorID); err != nil {
+		return ErrCollaboratorNotFound
+	}
+
+	s.invalidateUserCache(uintUserID, uintCollaboratorID)
+	s.publish(core.TodoChangeDeleted, uintTodoID, uintCollaboratorID)
+
+	return nil
+}
+
+func (s *TodoEncoded) GetHistory(ctx context.Context, userID, todoID any,
+	pageReq core.TodoPageRequest) (core.TodoHistoryResponse, error) {
+
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return core.TodoHistoryResponse{}, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
+	if err != nil {
+		return core.TodoHistoryResponse{}, fmt.Errorf("could not decode todo id: %s", err.Error())
+	}
+
+	page, err := requestToPage(pageReq, s.eventEncoder)
+	if err != nil {
+		return core.TodoHistoryResponse{}, err
+	}
+
+	generation := s.generations.Get(uintUserID)
+	cacheKey := cache.TodoCacheKey{
+		UserID: uintUserID,
+		Args: historyArgs{
+			todoID: uintTodoID,
+			page:   page,
+		},
+	}
+
+	if cached := s.cache.GetValue(cacheKey); cached != nil {
+		return cached.(core.TodoHistoryResponse), nil
+	}
+
+	events, err := s.repository.GetHistory(ctx, uintUserID, uintTodoID, lookahead(page))
+	switch err {
+	case nil:
+	case repository.ErrNotFound:
+		return core.TodoHistoryResponse{}, ErrTodoNotFound
+	default:
+		return core.TodoHistoryResponse{}, fmt.Errorf("could not get history: %s", err.Error())
+	}
+
+	response, err := s.eventsToHistory(events, page.Limit)
+	if err != nil {
+		return core.TodoHistoryResponse{}, err
+	}
+
+	s.generations.SetValue(uintUserID, generation, cacheKey, response)
+
+	return response, nil
+}
+
+func (s *TodoEncoded) CreateItem(ctx context.Context, userID, todoID any, itemReq core.ItemRequest) error {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
+	if err != nil {
+		return fmt.Errorf("could not decode todo id: %s", err.Error())
+	}
+
+	item, err := s.requestToItem(itemReq)
+	if err != nil {
+		return fmt.Errorf("could not convert request to item: %s", err.Error())
+	}
+
+	userIDs, err := s.itemRepository.Create(ctx, uintUserID, uintTodoID, item)
+	if err != nil {
+		return ErrTodoNotFound
+	}
+
+	s.invalidateUserCache(userIDs...)
+	s.publish(core.TodoChangeUpdated, uintTodoID, userIDs...)
+
+	return nil
+}
+
+func (s *TodoEncoded) GetItemByID(ctx context.Context, userID, todoID, itemID any) (core.ItemResponse, error) {
+	uintUserID, uintTodoID, uintItemID, err := s.decodeItemIDs(userID, todoID, itemID)
+	if err != nil {
+		return core.ItemResponse{}, err
+	}
+
+	item, err := s.itemRepository.GetByID(ctx, uintUserID, uintTodoID, uintItemID)
+	if err != nil {
+		return core.ItemResponse{}, ErrItemNotFound
+	}
+
+	return s.itemToResponse(itemID, item), nil
+}
+
+func (s *TodoEncoded) GetItems(ctx context.Context, userID, todoID any) ([]core.ItemResponse, error) {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode todo id: %s", err.Error())
+	}
+
+	items, err := s.itemRepository.GetByTodoID(ctx, uintUserID, uintTodoID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get items: %s", err.Error())
+	}
+
+	responses := make([]core.ItemResponse, len(items))
+
+	for i, item := range items {
+		itemID, err := s.itemEncoder.EncodeID(item.ID)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode item id: %s", err.Error())
+		}
+
+		responses[i] = s.itemToResponse(itemID, item)
+	}
+
+	return responses, nil
+}
+
+func (s *TodoEncoded) UpdateItemByID(ctx context.Context, userID, todoID, itemID any,
+	itemReq core.ItemRequest) error {
+
+	uintUserID, uintTodoID, uintItemID, err := s.decodeItemIDs(userID, todoID, itemID)
+	if err != nil {
+		return err
+	}
+
+	item, err := s.requestToItem(itemReq)
+	if err != nil {
+		return fmt.Errorf("could not convert request to item: %s", err.Error())
+	}
+
+	userIDs, err := s.itemRepository.UpdateByID(ctx, uintUserID, uintTodoID, uintItemID, item)
+	if err != nil {
+		return ErrItemNotFound
+	}
+
+	s.invalidateUserCache(userIDs...)
+	s.publish(core.TodoChangeUpdated, uintTodoID, userIDs...)
+
+	return nil
+}
+
+func (s *TodoEncoded) DeleteItemByID(ctx context.Context, userID, todoID, itemID any) error {
+	uintUserID, uintTodoID, uintItemID, err := s.decodeItemIDs(userID, todoID, itemID)
+	if err != nil {
+		return err
+	}
+
+	userIDs, err := s.itemRepository.DeleteByID(ctx, uintUserID, uintTodoID, uintItemID)
+	if err != nil {
+		return ErrItemNotFound
+	}
+
+	s.invalidateUserCache(userIDs...)
+	s.publish(core.TodoChangeUpdated, uintTodoID, userIDs...)
+
+	return nil
+}
+
+// SubscribeChanges replays the changes after the last event id first, or
+// sends a reset if they are no longer kept.
+func (s *TodoEncoded) SubscribeChanges(ctx context.Context, userID any,
+	lastEventID string) (<-chan core.TodoChangeEvent, error) {
+
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	var lastID uint64
+	invalidID := false
+	if len(lastEventID) != 0 {
+		lastID, err = strconv.ParseUint(lastEventID, 10, 64)
+		invalidID = err != nil
+	}
+
+	sub := s.broker.Subscribe(uintUserID, lastID)
+	events := make(chan core.TodoChangeEvent)
+
+	go func() {
+		defer close(events)
+		defer sub.Cancel()
+
+		send := func(event core.TodoChangeEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if sub.Missed || invalidID {
+			reset := core.TodoChangeEvent{ID: strconv.FormatUint(sub.LastID, 10), Change: core.TodoChangeReset}
+			if !send(reset) {
+				return
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-sub.Events:
+				if !ok || !send(s.eventToChange(event)) {
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// eventToChange turns a published event into a change. An id that cannot be
+// encoded makes it a reset.
+func (s *TodoEncoded) eventToChange(event broker.Event) core.TodoChangeEvent {
+	change := core.TodoChangeEvent{
+		ID:     strconv.FormatUint(event.ID, 10),
+		Change: core.TodoChange(event.Name),
+	}
+
+	todoID, ok := event.Data.(uint)
+	if !ok {
+		change.Change = core.TodoChangeReset
+		return change
+	}
+
+	encodedID, err := s.todoEncoder.EncodeID(todoID)
+	if err != nil {
+		change.Change = core.TodoChangeReset
+		return change
+	}
+	change.TodoID = encodedID
+
+	return change
+}
+
+func (s *TodoEncoded) decodeTodoIDs(userID, todoID any) (uint, uint, error) {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not decode todo id: %s", err.Error())
+	}
+
+	return uintUserID, uintTodoID, nil
+}
+
+func (s *TodoEncoded) decodeItemIDs(userID, todoID, itemID any) (uint, uint, uint, error) {
+	uintUserID, err := s.userEncoder.DecodeID(userID)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	uintTodoID, err := s.todoEncoder.DecodeID(todoID)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("could not decode todo id: %s", err.Error())
+	}
+
+	uintItemID, err := s.itemEncoder.DecodeID(itemID)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("could not decode item id: %s", err.Error())
+	}
+
+	return uintUserID, uintTodoID, uintItemID, nil
+}
+
+func (*TodoEncoded) requestToItem(req core.ItemRequest) (core.Item, error) {
+	var item core.Item
+
+	if len(req.Title) == 0 {
+		return item, errors.New("empty title")
+	}
+
+	item = core.Item{
+		Title:     req.Title,
+		Completed: req.Completed,
+	}
+	if req.Position != nil {
+		if *req.Position == 0 {
+			return item, errors.New("position must start from 1")
+		}
+		item.Position = *req.Position
+	}
+
+	return item, nil
+}
+
+func (*TodoEncoded) itemToResponse(itemID any, item core.Item) core.ItemResponse {
+	return core.ItemResponse{
+		ID:        itemID,
+		Title:     item.Title,
+		Completed: item.Completed,
+		Position:  item.Position,
+	}
+}
+
+func (s *TodoEncoded) requestToTodo(req core.TodoRequest) (core.Todo, error) {
+	var todo core.Todo
+
+	if len(req.Title) == 0 {
+		return todo, errors.New("empty title")
+	}
+
+	priority, err := core.ParsePriority(req.Priority)
+	if err != nil {
+		return todo, err
+	}
+
+	recurrence, err := normalizeRecurrence(req.Recurrence)
+	if err != nil {
+		return todo, err
+	}
+
+	projectID, err := s.decodeProjectID(req.ProjectID)
+	if err != nil {
+		return todo, err
+	}
+
+	todo = core.Todo{
+		Title:        req.Title,
+		Description:  req.Description,
+		Completed:    false,
+		Priority:     priority,
+		DueAt:        req.DueAt,
+		AutoComplete: req.AutoComplete,
+		Recurrence:   recurrence,
+		ProjectID:    projectID,
+	}
+
+	return todo, nil
+}
+
+// complete completes the todo, or advances it to its next occurrence when it
+// recurs.
+func (s *TodoEncoded) complete(todo *core.Todo, now time.Time) error {
+	todo.Completed = true
+	if len(todo.Recurrence) == 0 {
+		return nil
+	}
+
+	return s.advanceRecurrence(todo, now)
+}
+
+// advanceRecurrence anchors the series at the due date, or at now for todos
+// without one.
+func (*TodoEncoded) advanceRecurrence(todo *core.Todo, now time.Time) error {
+	rule, err := rrule.Parse(todo.Recurrence)
+	if err != nil {
+		return err
+	}
+
+	start := now
+	if todo.DueAt != nil {
+		start = *todo.DueAt
+	}
+
+	next, nextRule, ok := rule.Next(start)
+	if !ok {
+		return nil
+	}
+
+	todo.Completed = false
+	todo.DueAt = &next
+	todo.Recurrence = nextRule.String()
+
+	return nil
+}
+
+func (s *TodoEncoded) requestToPatch(req core.TodoPatchRequest) (core.TodoPatch, error) {
+	var patch core.TodoPatch
+
+	if req.Title.Set {
+		if len(req.Title.Value) == 0 {
+			return patch, errors.New("empty title")
+		}
+		patch.Title = core.Some(req.Title.Value)
+	}
+
+	if req.Description.Set {
+		patch.Description = core.Some(req.Description.Value)
+	}
+
+	if req.Completed.Set {
+		patch.Completed = core.Some(req.Completed.Value)
+	}
+
+	if req.Priority.Set {
+		priority, err := core.ParsePriority(req.Priority.Value)
+		if err != nil {
+			return patch, err
+		}
+		patch.Priority = core.Some(priority)
+	}
+
+	if req.DueAt.Set {
+		var dueAt *time.Time
+		if !req.DueAt.Null {
+			dueAt = &req.DueAt.Value
+		}
+		patch.DueAt = core.Some(dueAt)
+	}
+
+	if req.AutoComplete.Set {
+		patch.AutoComplete = core.Some(req.AutoComplete.Value)
+	}
+
+	if req.Recurrence.Set {
+		recurrence, err := normalizeRecurrence(req.Recurrence.Value)
+		if err != nil {
+			return patch, err
+		}
+		patch.Recurrence = core.Some(recurrence)
+	}
+
+	if req.ProjectID.Set {
+		projectID, err := s.decodeProjectID(req.ProjectID.Value)
+		if err != nil {
+			return patch, err
+		}
+		patch.ProjectID = core.Some(projectID)
+	}
+
+	return patch, nil
+}
+
+func (s *TodoEncoded) decodeProjectID(projectID any) (*uint, error) {
+	if projectID == nil {
+		return nil, nil
+	}
+
+	uintProjectID, err := s.projectEncoder.DecodeID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode project id: %s", err.Error())
+	}
+
+	return &uintProjectID, nil
+}
+
+func normalizeRecurrence(value string) (string, error) {
+	if len(strings.TrimSpace(value)) == 0 {
+		return "", nil
+	}
+
+	rule, err := rrule.Parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	return rule.String(), nil
+}
+
+func (s *TodoEncoded) todoToResponse(todoID any, todo core.Todo) (core.TodoResponse, error) {
+	var projectID any
+	if todo.ProjectID != nil {
+		encoded, err := s.projectEncoder.EncodeID(*todo.ProjectID)
+		if err != nil {
+			return core.TodoResponse{}, fmt.Errorf("could not encode project id: %s", err.Error())
+		}
+		projectID = encoded
+	}
+
+	tags := todo.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return core.TodoResponse{
+		ID:           todoID,
+		Title:        todo.Title,
+		Description:  todo.Description,
+		Completed:    todo.Completed,
+		Priority:     todo.Priority.String(),
+		DueAt:        todo.DueAt,
+		AutoComplete: todo.AutoComplete,
+		Recurrence:   todo.Recurrence,
+		ProjectID:    projectID,
+		Tags:         tags,
+		DeletedAt:    todo.DeletedAt,
+		Version:      todo.Version,
+	}, nil
+}
+
+func (s *TodoEncoded) todosToResponses(todos []core.Todo) ([]core.TodoResponse, error) {
+	responses := make([]core.TodoResponse, len(todos))
+
+	for i, todo := range todos {
+		todoID, err := s.todoEncoder.EncodeID(todo.ID)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode todo id: %s", err.Error())
+		}
+
+		responses[i], err = s.todoToResponse(todoID, todo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return responses, nil
+}
+
+// requestToPage decodes the cursor with the encoder of the ids it refers to.
+func requestToPage(req core.TodoPageRequest, encoder encoding.Encoder) (core.TodoPage, error) {
+	if req.Limit == 0 {
+		return core.TodoPage{}, errors.New("page limit must be positive")
+	}
+
+	page := core.TodoPage{
+		Limit: req.Limit,
+	}
+
+	if len(req.Cursor) == 0 {
+		return page, nil
+	}
+
+	after, err := encoder.DecodeID(req.Cursor)
+	if err != nil {
+		return core.TodoPage{}, ErrInvalidCursor
+	}
+	page.After = after
+
+	return page, nil
+}
+
+// todoCursorSorts lists the sorts a todo cursor can be issued for, so that a
+// cursor is refused under any other sort.
+var todoCursorSorts = []core.TodoSort{
+	{Field: core.SortByCreatedAt},
+	{Field: core.SortByCreatedAt, Descending: true},
+	{Field: core.SortByUpdatedAt},
+	{Field: core.SortByUpdatedAt, Descending: true},
+	{Field: core.SortByPriority},
+	{Field: core.SortByPriority, Descending: true},
+	{Field: core.SortByDueAt},
+	{Field: core.SortByDueAt, Descending: true},
+}
+
+// requestToTodoPage decodes a cursor holding the id of the last todo, the sort
+// it was listed by, and the value it was sorted by.
+func (s *TodoEncoded) requestToTodoPage(req core.TodoPageRequest, sort core.TodoSort) (core.TodoPage, error) {
+	if req.Limit == 0 {
+		return core.TodoPage{}, errors.New("page limit must be positive")
+	}
+
+	page := core.TodoPage{
+		Limit: req.Limit,
+	}
+
+	if len(req.Cursor) == 0 {
+		return page, nil
+	}
+
+	ids, err := s.todoEncoder.DecodeIDs(req.Cursor)
+	if err != nil || len(ids) != 4 || ids[1] >= uint(len(todoCursorSorts)) ||
+		todoCursorSorts[ids[1]] != sort || ids[2] > 1 {
+		return core.TodoPage{}, ErrInvalidCursor
+	}
+
+	page.After = ids[0]
+	page.AfterNull = ids[2] == 1
+	page.AfterValue = int64(ids[3]>>1) ^ -int64(ids[3]&1)
+
+	return page, nil
+}
+
+// todoCursor encodes the cursor requestToTodoPage decodes. The sort value is
+// zigzag encoded, since the ids have to be unsigned.
+func (s *TodoEncoded) todoCursor(todo core.Todo, sort core.TodoSort) (any, error) {
+	sortIndex := -1
+	for i, cursorSort := range todoCursorSorts {
+		if cursorSort == sort {
+			sortIndex = i
+		}
+	}
+	if sortIndex < 0 {
+		return nil, fmt.Errorf("could not sort by %q", sort.Field)
+	}
+
+	value, ok := sort.Value(todo)
+	var null uint
+	if !ok {
+		null = 1
+	}
+
+	return s.todoEncoder.EncodeIDs(todo.ID, uint(sortIndex), null, uint(value<<1^value>>63))
+}
+
+// todosToPage expects the todos to be fetched with lookahead, so that one
+// todo more than the limit means there is a next page.
+func (s *TodoEncoded) todosToPage(todos []core.Todo, sort core.TodoSort, limit uint) (core.TodoPageResponse, error) {
+	var nextCursor any
+
+	if uint(len(todos)) > limit {
+		todos = todos[:limit]
+
+		cursor, err := s.todoCursor(todos[limit-1], sort)
+		if err != nil {
+			return core.TodoPageResponse{}, fmt.Errorf("could not encode cursor: %s", err.Error())
+		}
+		nextCursor = cursor
+	}
+
+	responses, err := s.todosToResponses(todos)
+	if err != nil {
+		return core.TodoPageResponse{}, err
+	}
+
+	return core.TodoPageResponse{
+		Todos:      responses,
+		NextCursor: nextCursor,
+	}, nil
+}
+
+// eventsToHistory expects the events to be fetched with lookahead, the same
+// way todosToPage does.
+func (s *TodoEncoded) eventsToHistory(events []core.TodoEvent, limit uint) (core.TodoHistoryResponse, error) {
+	var nextCursor any
+
+	if uint(len(events)) > limit {
+		events = events[:limit]
+
+		cursor, err := s.eventEncoder.EncodeID(events[limit-1].ID)
+		if err != nil {
+			return core.TodoHistoryResponse{}, fmt.Errorf("could not encode cursor: %s", err.Error())
+		}
+		nextCursor = cursor
+	}
+
+	responses := make([]core.TodoEventResponse, len(events))
+
+	for i, event := range events {
+		eventID, err := s.eventEncoder.EncodeID(event.ID)
+		if err != nil {
+			return core.TodoHistoryResponse{}, fmt.Errorf("could not encode event id: %s", err.Error())
+		}
+
+		userID, err := s.userEncoder.EncodeID(event.UserID)
+		if err != nil {
+			return core.TodoHistoryResponse{}, fmt.Errorf("could not encode user id: %s", err.Error())
+		}
+
+		responses[i] = core.TodoEventResponse{
+			ID:        eventID,
+			UserID:    userID,
+			Username:  event.Username,
+			Action:    event.Action,
+			Changes:   event.Changes,
+			CreatedAt: event.CreatedAt,
+		}
+	}
+
+	return core.TodoHistoryResponse{
+		Events:     responses,
+		NextCursor: nextCursor,
+	}, nil
+}
+
+func (s *TodoEncoded) invalidateUserCache(userIDs ...uint) {
+	s.generations.Increment(userIDs...)
+}
+
+// publish tells the users about a change to the todo. Changes are published
+// on the topic of each user, with the todo id as the data.
+func (s *TodoEncoded) publish(change core.TodoChange, todoID uint, userIDs ...uint) {
+	for _, userID := range userIDs {
+		s.broker.Publish(userID, string(change), todoID)
+	}
+}
+
+func (s *TodoEncoded) publishReset(userIDs ...uint) {
+	for _, userID := range userIDs {
+		s.broker.Publish(userID, string(core.TodoChangeReset), nil)
+	}
+}
+
+func lookahead(page core.TodoPage) core.TodoPage {
+	page.Limit++
+	return page
+}
+
+func uniqueSorted(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := make([]string, len(values))
+	copy(sorted, values)
+	sort.Strings(sorted)
+
+	unique := sorted[:1]
+	for _, value := range sorted[1:] {
+		if value != unique[len(unique)-1] {
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
 }
@@ -0,0 +1,483 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grimerssy/todo-service/internal/core"
+	"github.com/grimerssy/todo-service/internal/repository"
+	"github.com/grimerssy/todo-service/pkg/cache"
+	"github.com/grimerssy/todo-service/pkg/encoding"
+	"github.com/grimerssy/todo-service/pkg/jsonpatch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTodoEncoded_requestToPatch(t *testing.T) {
+	projectEncoder, err := encoding.NewHashids(encoding.ConfigHashids{}, encoding.ProjectKey)
+	require.NoError(t, err)
+
+	s := &TodoEncoded{projectEncoder: projectEncoder}
+
+	dueAt := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+	projectID := uint(1)
+	encodedProjectID, err := projectEncoder.EncodeID(projectID)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		input     string
+		want      core.TodoPatch
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "ok empty",
+			input:     `{}`,
+			want:      core.TodoPatch{},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok set due date",
+			input:     `{"dueAt":"2026-10-18T09:00:00Z"}`,
+			want:      core.TodoPatch{DueAt: core.Some(&dueAt)},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok clear due date",
+			input:     `{"dueAt":null}`,
+			want:      core.TodoPatch{DueAt: core.Some[*time.Time](nil)},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok clear priority with none",
+			input:     `{"priority":"none"}`,
+			want:      core.TodoPatch{Priority: core.Some(core.PriorityNone)},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok clear priority with null",
+			input:     `{"priority":null}`,
+			want:      core.TodoPatch{Priority: core.Some(core.PriorityNone)},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "err unknown priority",
+			input:     `{"priority":"asap"}`,
+			want:      core.TodoPatch{},
+			errAssert: assert.Error,
+		},
+		{
+			name:      "ok turn auto complete off",
+			input:     `{"autoComplete":false}`,
+			want:      core.TodoPatch{AutoComplete: core.Some(false)},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok move to project",
+			input:     `{"projectId":"` + encodedProjectID.(string) + `"}`,
+			want:      core.TodoPatch{ProjectID: core.Some(&projectID)},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok move to inbox",
+			input:     `{"projectId":null}`,
+			want:      core.TodoPatch{ProjectID: core.Some[*uint](nil)},
+			errAssert: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		var req core.TodoPatchRequest
+		require.NoError(t, json.Unmarshal([]byte(tt.input), &req), tt.name)
+
+		got, err := s.requestToPatch(req)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestTodoEncoded_todoToResponse(t *testing.T) {
+	projectEncoder, err := encoding.NewHashids(encoding.ConfigHashids{}, encoding.ProjectKey)
+	require.NoError(t, err)
+
+	s := &TodoEncoded{projectEncoder: projectEncoder}
+
+	tests := []struct {
+		name string
+		todo core.Todo
+		want string
+	}{
+		{
+			name: "ok no tags",
+			todo: core.Todo{Title: "title"},
+			want: `"tags":[]`,
+		},
+		{
+			name: "ok tags",
+			todo: core.Todo{Title: "title", Tags: []string{"home", "work"}},
+			want: `"tags":["home","work"]`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := s.todoToResponse("id", tt.todo)
+		require.NoError(t, err, tt.name)
+
+		encoded, err := json.Marshal(got)
+		require.NoError(t, err, tt.name)
+		assert.Contains(t, string(encoded), tt.want, tt.name)
+	}
+}
+
+func TestTodoEncoded_todoCursor(t *testing.T) {
+	todoEncoder, err := encoding.NewHashids(encoding.ConfigHashids{}, encoding.TodoKey)
+	require.NoError(t, err)
+
+	s := &TodoEncoded{todoEncoder: todoEncoder}
+
+	createdAt := time.Date(2022, 5, 1, 10, 30, 0, 123456000, time.UTC)
+	dueAt := time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		todo core.Todo
+		sort core.TodoSort
+		want core.TodoPage
+	}{
+		{
+			name: "ok created at",
+			todo: core.Todo{ID: 2, CreatedAt: createdAt},
+			sort: core.TodoSort{Field: core.SortByCreatedAt},
+			want: core.TodoPage{After: 2, AfterValue: createdAt.UnixMicro(), Limit: 10},
+		},
+		{
+			name: "ok priority descending",
+			todo: core.Todo{ID: 3, Priority: core.PriorityHigh},
+			sort: core.TodoSort{Field: core.SortByPriority, Descending: true},
+			want: core.TodoPage{After: 3, AfterValue: int64(core.PriorityHigh), Limit: 10},
+		},
+		{
+			name: "ok due at before epoch",
+			todo: core.Todo{ID: 4, DueAt: &dueAt},
+			sort: core.TodoSort{Field: core.SortByDueAt},
+			want: core.TodoPage{After: 4, AfterValue: dueAt.UnixMicro(), Limit: 10},
+		},
+		{
+			name: "ok without due at",
+			todo: core.Todo{ID: 5},
+			sort: core.TodoSort{Field: core.SortByDueAt},
+			want: core.TodoPage{After: 5, AfterNull: true, Limit: 10},
+		},
+	}
+	for _, tt := range tests {
+		cursor, err := s.todoCursor(tt.todo, tt.sort)
+		require.NoError(t, err, tt.name)
+
+		got, err := s.requestToTodoPage(core.TodoPageRequest{Cursor: cursor.(string), Limit: 10}, tt.sort)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+
+		other := core.TodoSort{Field: tt.sort.Field, Descending: !tt.sort.Descending}
+		_, err = s.requestToTodoPage(core.TodoPageRequest{Cursor: cursor.(string), Limit: 10}, other)
+		assert.ErrorIs(t, err, ErrInvalidCursor, tt.name)
+	}
+
+	todoID, err := todoEncoder.EncodeID(2)
+	require.NoError(t, err)
+
+	_, err = s.requestToTodoPage(core.TodoPageRequest{Cursor: todoID.(string), Limit: 10}, core.TodoSort{Field: core.SortByCreatedAt})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestTodoEncoded_requestToItem(t *testing.T) {
+	s := &TodoEncoded{}
+
+	tests := []struct {
+		name      string
+		input     core.ItemRequest
+		want      core.Item
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "ok",
+			input:     core.ItemRequest{Title: "title"},
+			want:      core.Item{Title: "title"},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok completed",
+			input:     core.ItemRequest{Title: "title", Completed: true},
+			want:      core.Item{Title: "title", Completed: true},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "err empty title",
+			input:     core.ItemRequest{Completed: true},
+			want:      core.Item{},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		got, err := s.requestToItem(tt.input)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestTodoEncoded_applyTodoPatch(t *testing.T) {
+	projectEncoder, err := encoding.NewHashids(encoding.ConfigHashids{}, encoding.ProjectKey)
+	require.NoError(t, err)
+
+	s := &TodoEncoded{projectEncoder: projectEncoder}
+
+	dueAt := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	todo := core.Todo{Title: "title", Priority: core.PriorityHigh, DueAt: &dueAt}
+
+	tests := []struct {
+		name      string
+		input     string
+		want      core.TodoPatchRequest
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "ok complete",
+			input: `[{"op":"replace","path":"/completed","value":true}]`,
+			want: core.TodoPatchRequest{
+				Title:        core.Some("title"),
+				Description:  core.Some(""),
+				Completed:    core.Some(true),
+				Priority:     core.Some("high"),
+				DueAt:        core.Some(dueAt),
+				AutoComplete: core.Some(false),
+				Recurrence:   core.Some(""),
+				ProjectID:    core.Some[any](nil),
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name:  "ok remove due date",
+			input: `[{"op":"remove","path":"/dueAt"}]`,
+			want: core.TodoPatchRequest{
+				Title:        core.Some("title"),
+				Description:  core.Some(""),
+				Completed:    core.Some(false),
+				Priority:     core.Some("high"),
+				DueAt:        core.Optional[time.Time]{Set: true, Null: true},
+				AutoComplete: core.Some(false),
+				Recurrence:   core.Some(""),
+				ProjectID:    core.Some[any](nil),
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "err empty title",
+			input:     `[{"op":"replace","path":"/title","value":""}]`,
+			want:      core.TodoPatchRequest{},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		patch, err := jsonpatch.Decode([]byte(tt.input))
+		require.NoError(t, err, tt.name)
+
+		got, err := s.applyTodoPatch(todo, patch)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestTodoEncoded_PatchByID_invalid(t *testing.T) {
+	userEncoder, err := encoding.NewHashids(encoding.ConfigHashids{}, encoding.UserKey)
+	require.NoError(t, err)
+	todoEncoder, err := encoding.NewHashids(encoding.ConfigHashids{}, encoding.TodoKey)
+	require.NoError(t, err)
+	projectEncoder, err := encoding.NewHashids(encoding.ConfigHashids{}, encoding.ProjectKey)
+	require.NoError(t, err)
+
+	s := &TodoEncoded{userEncoder: userEncoder, todoEncoder: todoEncoder, projectEncoder: projectEncoder}
+
+	userID, err := userEncoder.EncodeID(1)
+	require.NoError(t, err)
+	todoID, err := todoEncoder.EncodeID(1)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "null title",
+			input: `{"title":null}`,
+		},
+		{
+			name:  "empty title",
+			input: `{"title":""}`,
+		},
+		{
+			name:  "unknown priority",
+			input: `{"priority":"asap"}`,
+		},
+		{
+			name:  "invalid recurrence",
+			input: `{"recurrence":"FREQ=HOURLY"}`,
+		},
+	}
+	for _, tt := range tests {
+		var req core.TodoPatchRequest
+		require.NoError(t, json.Unmarshal([]byte(tt.input), &req), tt.name)
+
+		err := s.PatchByID(context.Background(), userID, todoID, req, 0)
+		assert.ErrorIs(t, err, ErrInvalidPatch, tt.name)
+	}
+}
+
+type todoRepositoryStub struct {
+	repository.TodoRepository
+	updated core.Todo
+	objects map[string]core.CalendarObject
+	taken   bool
+	created bool
+}
+
+func (r *todoRepositoryStub) InTransaction(ctx context.Context, fn func(repo repository.TodoRepository) error) error {
+	return fn(r)
+}
+
+func (r *todoRepositoryStub) GetCalendarObject(ctx context.Context, userID uint, name string,
+	todoID uint) (core.CalendarObject, error) {
+
+	obj, ok := r.objects[name]
+	if !ok {
+		return core.CalendarObject{}, repository.ErrNotFound
+	}
+	return obj, nil
+}
+
+func (r *todoRepositoryStub) Create(ctx context.Context, userID uint, todo core.Todo) (uint, error) {
+	r.created = true
+	return 2, nil
+}
+
+func (r *todoRepositoryStub) SetCalendarName(ctx context.Context, userID uint, todoID uint, name, uid string) error {
+	if r.taken {
+		return repository.ErrAlreadyExists
+	}
+	return nil
+}
+
+func (r *todoRepositoryStub) UpdateByID(ctx context.Context, userID uint, todoID uint, todo core.Todo) ([]uint, error) {
+	r.updated = todo
+	return []uint{userID}, nil
+}
+
+func TestTodoEncoded_updateTodo(t *testing.T) {
+	s := &TodoEncoded{}
+
+	dueAt := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	nextDueAt := dueAt.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name  string
+		input core.TodoRequest
+		want  core.Todo
+	}{
+		{
+			name:  "ok complete",
+			input: core.TodoRequest{Title: "title", Completed: true, DueAt: &dueAt},
+			want:  core.Todo{Title: "title", Completed: true, DueAt: &dueAt},
+		},
+		{
+			name:  "ok keep incomplete",
+			input: core.TodoRequest{Title: "title", DueAt: &dueAt},
+			want:  core.Todo{Title: "title", DueAt: &dueAt},
+		},
+		{
+			name:  "ok complete recurring",
+			input: core.TodoRequest{Title: "title", Completed: true, DueAt: &dueAt, Recurrence: "FREQ=DAILY;COUNT=3"},
+			want:  core.Todo{Title: "title", DueAt: &nextDueAt, Recurrence: "FREQ=DAILY;COUNT=2"},
+		},
+		{
+			name:  "ok complete last occurrence",
+			input: core.TodoRequest{Title: "title", Completed: true, DueAt: &dueAt, Recurrence: "FREQ=DAILY;COUNT=1"},
+			want:  core.Todo{Title: "title", Completed: true, DueAt: &dueAt, Recurrence: "FREQ=DAILY;COUNT=1"},
+		},
+	}
+	for _, tt := range tests {
+		repo := &todoRepositoryStub{}
+		_, err := s.updateTodo(context.Background(), repo, 1, 1, tt.input, 0)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, repo.updated, tt.name)
+	}
+}
+
+func TestTodoEncoded_PutCalendarObject_createOnly(t *testing.T) {
+	userEncoder, err := encoding.NewHashids(encoding.ConfigHashids{}, encoding.UserKey)
+	require.NoError(t, err)
+	todoEncoder, err := encoding.NewHashids(encoding.ConfigHashids{}, encoding.TodoKey)
+	require.NoError(t, err)
+
+	userID, err := userEncoder.EncodeID(1)
+	require.NoError(t, err)
+
+	data := []byte(strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTODO",
+		"UID:a1@client",
+		"SUMMARY:Buy milk",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n"))
+
+	tests := []struct {
+		name      string
+		repo      *todoRepositoryStub
+		want      bool
+		created   bool
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "ok",
+			repo:      &todoRepositoryStub{},
+			want:      true,
+			created:   true,
+			errAssert: assert.NoError,
+		},
+		{
+			name: "exists",
+			repo: &todoRepositoryStub{objects: map[string]core.CalendarObject{
+				"a1.ics": {Name: "a1.ics", Todo: core.Todo{ID: 1}},
+			}},
+			want: false,
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrCalendarObjectConflict)
+			},
+		},
+		{
+			name:    "created concurrently",
+			repo:    &todoRepositoryStub{taken: true},
+			want:    false,
+			created: true,
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrCalendarObjectConflict)
+			},
+		},
+	}
+	for _, tt := range tests {
+		generations := cache.NewGenerations(cache.ConfigLFU{}, cache.TodoKey, cacheStub{})
+		s := &TodoEncoded{
+			generations: generations,
+			broker:      brokerStub{},
+			userEncoder: userEncoder,
+			todoEncoder: todoEncoder,
+			repository:  tt.repo,
+		}
+
+		got, err := s.PutCalendarObject(context.Background(), userID, "a1.ics", data, 0, true)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.Equal(t, tt.created, tt.repo.created, tt.name)
+	}
+}
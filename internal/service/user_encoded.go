@@ -2,31 +2,62 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/grimerssy/todo-service/internal/core"
 	"github.com/grimerssy/todo-service/internal/repository"
 	"github.com/grimerssy/todo-service/pkg/auth"
+	"github.com/grimerssy/todo-service/pkg/cache"
 	"github.com/grimerssy/todo-service/pkg/encoding"
 	"github.com/grimerssy/todo-service/pkg/hashing"
 )
 
+const (
+	feedTokenSize    = 32
+	davPasswordSize  = 32
+	refreshTokenSize = 32
+)
+
+type ConfigTokens struct {
+	RefreshDays            uint
+	RevocationCacheSeconds time.Duration
+	PruneMinutes           time.Duration
+}
+
+// revokedTokenKey caches whether the access token with the jti is revoked,
+// and revokedUserKey the time the tokens of the user were all revoked at.
+type (
+	revokedTokenKey string
+	revokedUserKey  uint
+)
+
 type UserEncoded struct {
-	hasher        hashing.Hasher
-	encoder       encoding.Encoder
-	authenticator auth.Authenticator
-	repository    repository.UserRepository
+	refreshTTL         time.Duration
+	revocationCacheTTL time.Duration
+	revocations        *cache.Expiring
+	hasher             hashing.Hasher
+	encoder            encoding.Encoder
+	authenticator      auth.Authenticator
+	repository         repository.UserRepository
 }
 
-func NewUserEncoded(hasher hashing.Hasher, encoder encoding.Encoder, authenticator auth.Authenticator,
-	repository repository.UserRepository) *UserEncoded {
+func NewUserEncoded(cfg ConfigTokens, revocations *cache.Expiring, hasher hashing.Hasher,
+	encoder encoding.Encoder, authenticator auth.Authenticator, repository repository.UserRepository) *UserEncoded {
 
 	return &UserEncoded{
-		hasher:        hasher,
-		encoder:       encoder,
-		authenticator: authenticator,
-		repository:    repository,
+		refreshTTL:         time.Duration(cfg.RefreshDays) * 24 * time.Hour,
+		revocationCacheTTL: cfg.RevocationCacheSeconds * time.Second,
+		revocations:        revocations,
+		hasher:             hasher,
+		encoder:            encoder,
+		authenticator:      authenticator,
+		repository:         repository,
 	}
 }
 
@@ -48,31 +79,382 @@ func (s *UserEncoded) SignUp(ctx context.Context, userReq core.UserRequest) erro
 	return nil
 }
 
-func (s *UserEncoded) SignIn(ctx context.Context, userReq core.UserRequest) (string, error) {
-	user, err := s.repository.GetCredentialsByUsername(ctx, userReq.Username)
+func (s *UserEncoded) SignIn(ctx context.Context, userReq core.UserRequest) (core.Tokens, error) {
+	id, err := s.Authenticate(ctx, userReq.Username, userReq.Password)
 	if err != nil {
-		return "", ErrUserNotFound
+		return core.Tokens{}, err
 	}
 
-	if match := s.hasher.CompareHashAndPassword(user.Password, userReq.Password); !match {
-		return "", errors.New("invalid password")
+	uintID, err := s.encoder.DecodeID(id)
+	if err != nil {
+		return core.Tokens{}, fmt.Errorf("could not decode user id: %s", err.Error())
 	}
 
-	id, err := s.encoder.EncodeID(user.ID)
+	refreshToken, err := generateToken(refreshTokenSize)
+	if err != nil {
+		return core.Tokens{}, fmt.Errorf("could not generate refresh token: %s", err.Error())
+	}
+
+	err = s.repository.CreateRefreshToken(ctx, uintID, hashToken(refreshToken), time.Now().Add(s.refreshTTL))
+	if err != nil {
+		return core.Tokens{}, fmt.Errorf("could not create refresh token: %s", err.Error())
+	}
+
+	return s.issueTokens(id, refreshToken)
+}
+
+// Refresh rotates the refresh token and issues a new access token. Reusing a
+// token that has already been rotated revokes every token of its family.
+func (s *UserEncoded) Refresh(ctx context.Context, refreshToken string) (core.Tokens, error) {
+	newRefreshToken, err := generateToken(refreshTokenSize)
+	if err != nil {
+		return core.Tokens{}, fmt.Errorf("could not generate refresh token: %s", err.Error())
+	}
+
+	userID, err := s.repository.RotateRefreshToken(ctx, hashToken(refreshToken), hashToken(newRefreshToken),
+		time.Now().Add(s.refreshTTL))
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return core.Tokens{}, ErrInvalidRefreshToken
+	case errors.Is(err, repository.ErrTokenReused):
+		return core.Tokens{}, ErrRefreshTokenReused
+	case err != nil:
+		return core.Tokens{}, fmt.Errorf("could not rotate refresh token: %s", err.Error())
+	}
+
+	id, err := s.encoder.EncodeID(userID)
 	if err != nil {
-		return "", fmt.Errorf("could not encode user id: %s", err.Error())
+		return core.Tokens{}, fmt.Errorf("could not encode user id: %s", err.Error())
 	}
 
-	token, err := s.authenticator.GenerateToken(id)
+	return s.issueTokens(id, newRefreshToken)
+}
+
+func (s *UserEncoded) issueTokens(userID any, refreshToken string) (core.Tokens, error) {
+	accessToken, err := s.authenticator.GenerateToken(userID)
 	if err != nil {
-		return "", fmt.Errorf("could not generate token: %s", err.Error())
+		return core.Tokens{}, fmt.Errorf("could not generate token: %s", err.Error())
 	}
 
-	return token, nil
+	return core.Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// Authenticate checks the password of the user and returns the encoded user
+// id, for clients that send credentials with every request instead of a token.
+func (s *UserEncoded) Authenticate(ctx context.Context, username, password string) (any, error) {
+	user, err := s.repository.GetCredentialsByUsername(ctx, username)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if match := s.hasher.CompareHashAndPassword(user.Password, password); !match {
+		return nil, errors.New("invalid password")
+	}
+
+	id, err := s.encoder.EncodeID(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode user id: %s", err.Error())
+	}
+
+	return id, nil
+}
+
+// invalidTokenError is ErrInvalidToken that also unwraps to the error of the
+// authenticator, which tells why the token was rejected.
+type invalidTokenError struct {
+	err error
+}
+
+func (e invalidTokenError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidToken.Error(), e.err.Error())
+}
+
+func (e invalidTokenError) Is(target error) bool {
+	return target == ErrInvalidToken
+}
+
+func (e invalidTokenError) Unwrap() error {
+	return e.err
 }
 
 func (s *UserEncoded) GetID(ctx context.Context, accessToken string) (any, error) {
-	return s.authenticator.ParseToken(accessToken)
+	claims, err := s.parseAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims.UserID, nil
+}
+
+func (s *UserEncoded) GetExpiry(ctx context.Context, accessToken string) (time.Time, error) {
+	claims, err := s.parseAccessToken(ctx, accessToken)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return claims.ExpiresAt, nil
+}
+
+func (s *UserEncoded) parseAccessToken(ctx context.Context, accessToken string) (auth.Claims, error) {
+	claims, err := s.authenticator.ParseToken(accessToken)
+	if err != nil {
+		return auth.Claims{}, invalidTokenError{err}
+	}
+
+	revoked, err := s.isRevoked(ctx, claims)
+	if err != nil {
+		return auth.Claims{}, fmt.Errorf("could not check token revocation: %s", err.Error())
+	}
+	if revoked {
+		return auth.Claims{}, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// isRevoked caches revocations until the token expires, and tokens that are
+// not revoked for a while, since other instances may revoke them.
+func (s *UserEncoded) isRevoked(ctx context.Context, claims auth.Claims) (bool, error) {
+	uintUserID, err := s.encoder.DecodeID(claims.UserID)
+	if err != nil {
+		return false, fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	if revokedAt, ok := s.revocations.GetValue(revokedUserKey(uintUserID)).(time.Time); ok &&
+		!claims.IssuedAt.After(revokedAt) {
+		return true, nil
+	}
+	if revoked, ok := s.revocations.GetValue(revokedTokenKey(claims.TokenID)).(bool); ok {
+		return revoked, nil
+	}
+
+	revoked, err := s.repository.IsTokenRevoked(ctx, uintUserID, claims.TokenID, claims.IssuedAt)
+	if err != nil {
+		return false, err
+	}
+
+	expiresAt := claims.ExpiresAt
+	if trustedUntil := time.Now().Add(s.revocationCacheTTL); !revoked && trustedUntil.Before(expiresAt) {
+		expiresAt = trustedUntil
+	}
+	s.revocations.SetValue(revokedTokenKey(claims.TokenID), revoked, expiresAt)
+
+	return revoked, nil
+}
+
+func (s *UserEncoded) SignOut(ctx context.Context, accessToken, refreshToken string) error {
+	claims, err := s.authenticator.ParseToken(accessToken)
+	if err != nil {
+		return invalidTokenError{err}
+	}
+
+	uintUserID, err := s.encoder.DecodeID(claims.UserID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	if err := s.repository.RevokeToken(ctx, uintUserID, claims.TokenID, claims.ExpiresAt); err != nil {
+		return fmt.Errorf("could not revoke token: %s", err.Error())
+	}
+	s.revocations.SetValue(revokedTokenKey(claims.TokenID), true, claims.ExpiresAt)
+
+	if len(refreshToken) == 0 {
+		return nil
+	}
+
+	err = s.repository.DeleteRefreshTokenFamily(ctx, uintUserID, hashToken(refreshToken))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("could not delete refresh tokens: %s", err.Error())
+	}
+
+	return nil
+}
+
+// SignOutAll revokes every refresh token of the user, and every access token
+// issued up to the one signing out, so that later sign-ins are kept.
+func (s *UserEncoded) SignOutAll(ctx context.Context, accessToken string) error {
+	claims, err := s.authenticator.ParseToken(accessToken)
+	if err != nil {
+		return invalidTokenError{err}
+	}
+
+	uintUserID, err := s.encoder.DecodeID(claims.UserID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	err = s.repository.RevokeAllTokens(ctx, uintUserID, claims.IssuedAt)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrUserNotFound
+	case err != nil:
+		return fmt.Errorf("could not revoke tokens: %s", err.Error())
+	}
+
+	// signing out everywhere includes CalDAV clients
+	err = s.repository.DeleteDavPassword(ctx, uintUserID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("could not delete dav password: %s", err.Error())
+	}
+
+	// tokens cached as not revoked are trusted until the cache ttl at most,
+	// so the cutoff only has to outlive them
+	revokedAt := claims.IssuedAt
+	if cached, ok := s.revocations.GetValue(revokedUserKey(uintUserID)).(time.Time); ok &&
+		cached.After(revokedAt) {
+		revokedAt = cached
+	}
+	s.revocations.SetValue(revokedUserKey(uintUserID), revokedAt, time.Now().Add(s.revocationCacheTTL))
+
+	return nil
+}
+
+func (s *UserEncoded) PruneRevokedTokens(ctx context.Context) error {
+	s.revocations.Prune(time.Now())
+
+	if err := s.repository.PruneRevokedTokens(ctx); err != nil {
+		return fmt.Errorf("could not prune revoked tokens: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (s *UserEncoded) GetKeySet() (auth.JWKSet, error) {
+	keySet, ok := s.authenticator.(auth.KeySet)
+	if !ok {
+		return auth.JWKSet{}, ErrKeySetNotPublished
+	}
+
+	return keySet.JWKS(), nil
+}
+
+// CreateFeedToken generates a new feed token of the user, which revokes the
+// previous one. Only its hash is stored, so it cannot be shown again.
+func (s *UserEncoded) CreateFeedToken(ctx context.Context, userID any) (string, error) {
+	uintUserID, err := s.encoder.DecodeID(userID)
+	if err != nil {
+		return "", fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	feedToken, err := generateToken(feedTokenSize)
+	if err != nil {
+		return "", fmt.Errorf("could not generate feed token: %s", err.Error())
+	}
+
+	if err := s.repository.SetFeedToken(ctx, uintUserID, hashToken(feedToken)); err != nil {
+		return "", fmt.Errorf("could not set feed token: %s", err.Error())
+	}
+
+	return feedToken, nil
+}
+
+func (s *UserEncoded) GetIDByFeedToken(ctx context.Context, feedToken string) (any, error) {
+	userID, err := s.repository.GetIDByFeedToken(ctx, hashToken(feedToken))
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, ErrFeedTokenNotFound
+	case err != nil:
+		return nil, fmt.Errorf("could not get user id: %s", err.Error())
+	}
+
+	id, err := s.encoder.EncodeID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode user id: %s", err.Error())
+	}
+
+	return id, nil
+}
+
+func (s *UserEncoded) RevokeFeedToken(ctx context.Context, userID any) error {
+	uintUserID, err := s.encoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	err = s.repository.DeleteFeedToken(ctx, uintUserID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrFeedTokenNotFound
+	case err != nil:
+		return fmt.Errorf("could not delete feed token: %s", err.Error())
+	}
+
+	return nil
+}
+
+// CreateDavPassword generates a new app password for CalDAV clients of the
+// user, which revokes the previous one. Like feed tokens, only its hash is
+// stored.
+func (s *UserEncoded) CreateDavPassword(ctx context.Context, userID any) (string, error) {
+	uintUserID, err := s.encoder.DecodeID(userID)
+	if err != nil {
+		return "", fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	password, err := generateToken(davPasswordSize)
+	if err != nil {
+		return "", fmt.Errorf("could not generate dav password: %s", err.Error())
+	}
+
+	if err := s.repository.SetDavPassword(ctx, uintUserID, hashToken(password)); err != nil {
+		return "", fmt.Errorf("could not set dav password: %s", err.Error())
+	}
+
+	return password, nil
+}
+
+// AuthenticateDav checks the app password of the user. The password is random,
+// so a fast hash is enough to store it.
+func (s *UserEncoded) AuthenticateDav(ctx context.Context, username, password string) (any, error) {
+	userID, err := s.repository.GetIDByDavPassword(ctx, username, hashToken(password))
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, ErrInvalidDavCredentials
+	case err != nil:
+		return nil, fmt.Errorf("could not get user id: %s", err.Error())
+	}
+
+	id, err := s.encoder.EncodeID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode user id: %s", err.Error())
+	}
+
+	return id, nil
+}
+
+func (s *UserEncoded) RevokeDavPassword(ctx context.Context, userID any) error {
+	uintUserID, err := s.encoder.DecodeID(userID)
+	if err != nil {
+		return fmt.Errorf("could not decode user id: %s", err.Error())
+	}
+
+	err = s.repository.DeleteDavPassword(ctx, uintUserID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrDavPasswordNotFound
+	case err != nil:
+		return fmt.Errorf("could not delete dav password: %s", err.Error())
+	}
+
+	return nil
+}
+
+// generateToken returns a URL-safe token of size random bytes.
+func generateToken(size int) (string, error) {
+	secret := make([]byte, size)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 func (*UserEncoded) requestToUser(su core.UserRequest) (core.User, error) {
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grimerssy/todo-service/internal/repository"
+	"github.com/grimerssy/todo-service/pkg/auth"
+	"github.com/grimerssy/todo-service/pkg/cache"
+	"github.com/grimerssy/todo-service/pkg/encoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type authenticatorStub map[string]auth.Claims
+
+func (a authenticatorStub) GenerateToken(userID any) (string, error) {
+	return "", nil
+}
+
+func (a authenticatorStub) ParseToken(accessToken string) (auth.Claims, error) {
+	return a[accessToken], nil
+}
+
+type userRepositoryStub struct {
+	repository.UserRepository
+	revokedAt time.Time
+}
+
+func (r *userRepositoryStub) RevokeAllTokens(ctx context.Context, userID uint, issuedAt time.Time) error {
+	r.revokedAt = issuedAt
+	return nil
+}
+
+func (r *userRepositoryStub) DeleteDavPassword(ctx context.Context, userID uint) error {
+	return repository.ErrNotFound
+}
+
+func (r *userRepositoryStub) IsTokenRevoked(ctx context.Context, userID uint, tokenID string,
+	issuedAt time.Time) (bool, error) {
+
+	return !issuedAt.After(r.revokedAt), nil
+}
+
+func TestUserEncoded_SignOutAll(t *testing.T) {
+	encoder, err := encoding.NewHashids(encoding.ConfigHashids{}, encoding.UserKey)
+	require.NoError(t, err)
+
+	userID, err := encoder.EncodeID(1)
+	require.NoError(t, err)
+
+	// tokens only carry whole seconds, and the user signs in again within the
+	// second they signed out everywhere in
+	signedOutAt := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := signedOutAt.Add(time.Hour)
+
+	authenticator := authenticatorStub{
+		"stolen":  {UserID: userID, TokenID: "stolen", IssuedAt: signedOutAt.Add(-time.Hour), ExpiresAt: expiresAt},
+		"current": {UserID: userID, TokenID: "current", IssuedAt: signedOutAt.Add(-time.Minute), ExpiresAt: expiresAt},
+		"next":    {UserID: userID, TokenID: "next", IssuedAt: signedOutAt, ExpiresAt: expiresAt},
+	}
+
+	tests := []struct {
+		name  string
+		cache bool
+	}{
+		{
+			name:  "same instance",
+			cache: true,
+		},
+		{
+			name:  "other instance",
+			cache: false,
+		},
+	}
+	for _, tt := range tests {
+		repo := &userRepositoryStub{}
+		revocations := cache.NewExpiring()
+		s := NewUserEncoded(ConfigTokens{RevocationCacheSeconds: 60}, revocations, nil, encoder, authenticator, repo)
+
+		require.NoError(t, s.SignOutAll(context.Background(), "current"), tt.name)
+		if !tt.cache {
+			s.revocations = cache.NewExpiring()
+		}
+
+		for _, token := range []string{"stolen", "current"} {
+			_, err := s.GetID(context.Background(), token)
+			assert.ErrorIs(t, err, ErrTokenRevoked, tt.name)
+		}
+
+		got, err := s.GetID(context.Background(), "next")
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, userID, got, tt.name)
+	}
+}
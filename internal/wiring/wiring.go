@@ -6,6 +6,7 @@ import (
 	"github.com/grimerssy/todo-service/internal/repository"
 	"github.com/grimerssy/todo-service/internal/service"
 	"github.com/grimerssy/todo-service/pkg/auth"
+	"github.com/grimerssy/todo-service/pkg/broker"
 	"github.com/grimerssy/todo-service/pkg/cache"
 	"github.com/grimerssy/todo-service/pkg/database"
 	"github.com/grimerssy/todo-service/pkg/encoding"
@@ -20,19 +21,28 @@ func GetRepositories(cfg *config.Config, logger logging.Logger) (*repository.Rep
 	}
 	userRepository := repository.NewUserPostgres(dbPsql)
 	todoRepository := repository.NewTodoPostgres(dbPsql)
+	itemRepository := repository.NewItemPostgres(dbPsql)
+	tagRepository := repository.NewTagPostgres(dbPsql)
+	projectRepository := repository.NewProjectPostgres(dbPsql)
 
 	closeDB := func() error {
 		return dbPsql.Close()
 	}
 
 	return &repository.Repositories{
-		UserRepository: userRepository,
-		TodoRepository: todoRepository,
+		UserRepository:    userRepository,
+		TodoRepository:    todoRepository,
+		ItemRepository:    itemRepository,
+		TagRepository:     tagRepository,
+		ProjectRepository: projectRepository,
 	}, closeDB
 }
 
 func GetServices(cfg *config.Config, logger logging.Logger, repositories *repository.Repositories) *service.Services {
 	todoCache := cache.NewLFU(cfg.LFU, cache.TodoKey)
+	generations := cache.NewGenerations(cfg.LFU, cache.TodoKey, todoCache)
+	revocations := cache.NewExpiring()
+	todoBroker := broker.NewMemory(cfg.Broker, broker.TodoKey)
 
 	hash := hashing.NewBcrypt(cfg.Bcrypt)
 
@@ -44,26 +54,67 @@ func GetServices(cfg *config.Config, logger logging.Logger, repositories *reposi
 	if err != nil {
 		logger.Logf(logging.FatalLevel, "could not initialize todo encoder: %s", err.Error())
 	}
+	itemEncoder, err := encoding.NewHashids(cfg.Hashids, encoding.ItemKey)
+	if err != nil {
+		logger.Logf(logging.FatalLevel, "could not initialize item encoder: %s", err.Error())
+	}
+	tagEncoder, err := encoding.NewHashids(cfg.Hashids, encoding.TagKey)
+	if err != nil {
+		logger.Logf(logging.FatalLevel, "could not initialize tag encoder: %s", err.Error())
+	}
+
+	projectEncoder, err := encoding.NewHashids(cfg.Hashids, encoding.ProjectKey)
+	if err != nil {
+		logger.Logf(logging.FatalLevel, "could not initialize project encoder: %s", err.Error())
+	}
+	eventEncoder, err := encoding.NewHashids(cfg.Hashids, encoding.EventKey)
+	if err != nil {
+		logger.Logf(logging.FatalLevel, "could not initialize event encoder: %s", err.Error())
+	}
 
-	authenticator := auth.NewJWT(cfg.JWT)
+	var authenticator auth.Authenticator
+	switch {
+	case len(cfg.PASETO.Purpose) != 0:
+		authenticator, err = auth.NewPASETO(cfg.PASETO)
+	case len(cfg.JWT.KeyFiles) != 0:
+		authenticator, err = auth.NewAsymmetricJWT(cfg.JWT)
+	default:
+		authenticator = auth.NewJWT(cfg.JWT)
+	}
+	if err != nil {
+		logger.Logf(logging.FatalLevel, "could not initialize authenticator: %s", err.Error())
+	}
 
-	userService := service.NewUserEncoded(hash, userEncoder, authenticator, repositories.UserRepository)
-	todoService := service.NewTodoEncoded(todoCache, userEncoder, todoEncoder, repositories.TodoRepository)
+	userService := service.NewUserEncoded(cfg.Tokens, revocations, hash, userEncoder, authenticator,
+		repositories.UserRepository)
+	todoService := service.NewTodoEncoded(cfg.Trash, todoCache, generations, todoBroker,
+		userEncoder, todoEncoder, itemEncoder, projectEncoder, eventEncoder,
+		repositories.TodoRepository, repositories.ItemRepository)
+	tagService := service.NewTagEncoded(generations, todoBroker, userEncoder, todoEncoder, tagEncoder,
+		repositories.TagRepository)
+	projectService := service.NewProjectEncoded(generations, todoBroker, userEncoder, projectEncoder,
+		repositories.ProjectRepository)
 
 	return &service.Services{
-		UserService: userService,
-		TodoService: todoService,
+		UserService:    userService,
+		TodoService:    todoService,
+		TagService:     tagService,
+		ProjectService: projectService,
 	}
 }
 
 func GetGinHandlers(cfg *config.Config, logger logging.Logger, services *service.Services) *handler.HandlersGin {
 	authGin := handler.NewAuthGin(cfg.Gin, logger, services.UserService)
 	middlewareGin := handler.NewMiddlewareGin(cfg.Gin, logger, services.UserService)
-	todoGin := handler.NewTodoGin(cfg.Gin, logger, services.TodoService)
+	todoGin := handler.NewTodoGin(cfg.Gin, logger, services.TodoService, services.UserService)
+	tagGin := handler.NewTagGin(cfg.Gin, logger, services.TagService)
+	projectGin := handler.NewProjectGin(cfg.Gin, logger, services.ProjectService)
 
 	return &handler.HandlersGin{
 		Auth:       authGin,
 		Middleware: middlewareGin,
 		Todo:       todoGin,
+		Tag:        tagGin,
+		Project:    projectGin,
 	}
 }
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// JWK is a public key of a JSON Web Key Set, as described in RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeySet is implemented by authenticators whose tokens can be verified by
+// anyone with the published public keys.
+type KeySet interface {
+	JWKS() JWKSet
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    crypto.PublicKey
+}
+
+// AsymmetricJWT signs tokens with an RSA or Ed25519 key and verifies them
+// with any loaded key, so that signing keys can be rolled out.
+type AsymmetricJWT struct {
+	claims        claimsConfigJWT
+	signingKeyID  string
+	signingMethod jwt.SigningMethod
+	signingKey    crypto.PrivateKey
+	keys          map[string]verificationKey
+}
+
+// NewAsymmetricJWT loads the PEM files of KeyFiles. A file may hold a private
+// key or only a public one, but the key SigningKeyID must be private.
+func NewAsymmetricJWT(cfg ConfigJWT) (*AsymmetricJWT, error) {
+	a := &AsymmetricJWT{
+		claims:       newClaimsConfigJWT(cfg),
+		signingKeyID: cfg.SigningKeyID,
+		keys:         make(map[string]verificationKey, len(cfg.KeyFiles)),
+	}
+
+	for keyID, path := range cfg.KeyFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read key %s: %s", keyID, err.Error())
+		}
+
+		privateKey, publicKey, err := parseKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse key %s: %s", keyID, err.Error())
+		}
+
+		method := jwt.SigningMethod(jwt.SigningMethodEdDSA)
+		if _, ok := publicKey.(*rsa.PublicKey); ok {
+			method = jwt.SigningMethodRS256
+		}
+		a.keys[keyID] = verificationKey{method: method, key: publicKey}
+
+		if keyID == cfg.SigningKeyID {
+			if privateKey == nil {
+				return nil, fmt.Errorf("signing key %s is not a private key", keyID)
+			}
+			a.signingMethod = method
+			a.signingKey = privateKey
+		}
+	}
+
+	if a.signingKey == nil {
+		return nil, fmt.Errorf("could not find signing key %s", cfg.SigningKeyID)
+	}
+
+	return a, nil
+}
+
+func (a *AsymmetricJWT) GenerateToken(userID any) (string, error) {
+	claims, err := a.claims.newClaims(userID)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(a.signingMethod, claims)
+	token.Header["kid"] = a.signingKeyID
+
+	accessToken, err := token.SignedString(a.signingKey)
+	if err != nil {
+		return "", fmt.Errorf("could not sign jwt token: %s", err.Error())
+	}
+
+	return accessToken, nil
+}
+
+func (a *AsymmetricJWT) ParseToken(accessToken string) (Claims, error) {
+	keyFunc := func(token *jwt.Token) (any, error) {
+		keyID, _ := token.Header["kid"].(string)
+		key, ok := a.keys[keyID]
+		if !ok {
+			return nil, errors.New("unknown key id")
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("invalid signing method")
+		}
+
+		return key.key, nil
+	}
+
+	return a.claims.parse(accessToken, keyFunc)
+}
+
+// JWKS returns the public keys tokens are verified with, ordered by id.
+func (a *AsymmetricJWT) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(a.keys))}
+
+	for keyID, key := range a.keys {
+		jwk := JWK{
+			Use:       "sig",
+			Algorithm: key.method.Alg(),
+			KeyID:     keyID,
+		}
+
+		switch publicKey := key.key.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+
+	return set
+}
+
+// parseKeyPEM returns the private key of the PEM data along with its public
+// key, or only the public key if the data holds no private one.
+func parseKeyPEM(data []byte) (crypto.PrivateKey, crypto.PublicKey, error) {
+	if privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return privateKey, privateKey.Public(), nil
+	}
+	if privateKey, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		if signer, ok := privateKey.(ed25519.PrivateKey); ok {
+			return signer, signer.Public(), nil
+		}
+	}
+	if publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return nil, publicKey, nil
+	}
+	if publicKey, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		if _, ok := publicKey.(ed25519.PublicKey); ok {
+			return nil, publicKey, nil
+		}
+	}
+
+	return nil, nil, errors.New("unsupported key, must be RSA or Ed25519 in PEM")
+}
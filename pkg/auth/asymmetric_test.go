@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeyPEM(t *testing.T, name string, key any, public bool) string {
+	var (
+		der []byte
+		err error
+	)
+	blockType := "PRIVATE KEY"
+	if public {
+		blockType = "PUBLIC KEY"
+		der, err = x509.MarshalPKIXPublicKey(key)
+	} else {
+		der, err = x509.MarshalPKCS8PrivateKey(key)
+	}
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), name+".pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+
+	return path
+}
+
+func TestAsymmetricJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	rsaFile := writeKeyPEM(t, "rsa", rsaKey, false)
+	rsaPublicFile := writeKeyPEM(t, "rsa.pub", &rsaKey.PublicKey, true)
+	edFile := writeKeyPEM(t, "ed", edKey, false)
+	edPublicFile := writeKeyPEM(t, "ed.pub", edPublic, true)
+
+	tests := []struct {
+		name      string
+		signer    ConfigJWT
+		verifier  ConfigJWT
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "ok rsa",
+			signer:    ConfigJWT{SigningKeyID: "a", KeyFiles: map[string]string{"a": rsaFile}},
+			verifier:  ConfigJWT{SigningKeyID: "a", KeyFiles: map[string]string{"a": rsaFile}},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok ed25519",
+			signer:    ConfigJWT{SigningKeyID: "b", KeyFiles: map[string]string{"b": edFile}},
+			verifier:  ConfigJWT{SigningKeyID: "b", KeyFiles: map[string]string{"b": edFile}},
+			errAssert: assert.NoError,
+		},
+		{
+			name:   "ok rotated key",
+			signer: ConfigJWT{SigningKeyID: "a", KeyFiles: map[string]string{"a": rsaFile}},
+			verifier: ConfigJWT{SigningKeyID: "b", KeyFiles: map[string]string{
+				"a": rsaPublicFile,
+				"b": edFile,
+			}},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "unknown key id",
+			signer:    ConfigJWT{SigningKeyID: "a", KeyFiles: map[string]string{"a": rsaFile}},
+			verifier:  ConfigJWT{SigningKeyID: "b", KeyFiles: map[string]string{"b": edFile}},
+			errAssert: assert.Error,
+		},
+		{
+			name:      "key of other algorithm",
+			signer:    ConfigJWT{SigningKeyID: "a", KeyFiles: map[string]string{"a": rsaFile}},
+			verifier:  ConfigJWT{SigningKeyID: "b", KeyFiles: map[string]string{"a": edPublicFile, "b": edFile}},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		signer, err := NewAsymmetricJWT(tt.signer)
+		require.NoError(t, err, tt.name)
+		verifier, err := NewAsymmetricJWT(tt.verifier)
+		require.NoError(t, err, tt.name)
+
+		token, err := signer.GenerateToken("user")
+		require.NoError(t, err, tt.name)
+
+		claims, err := verifier.ParseToken(token)
+		tt.errAssert(t, err, tt.name)
+		if err == nil {
+			assert.Equal(t, "user", claims.UserID, tt.name)
+			assert.NotEmpty(t, claims.TokenID, tt.name)
+		}
+	}
+}
+
+func TestAsymmetricJWT_ParseToken_symmetric(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPublicFile := writeKeyPEM(t, "rsa.pub", &rsaKey.PublicKey, true)
+	rsaFile := writeKeyPEM(t, "rsa", rsaKey, false)
+
+	a, err := NewAsymmetricJWT(ConfigJWT{SigningKeyID: "a", KeyFiles: map[string]string{"a": rsaFile}})
+	require.NoError(t, err)
+
+	// an HMAC token keyed with the public key must not pass as RS256
+	public, err := os.ReadFile(rsaPublicFile)
+	require.NoError(t, err)
+	claims, err := a.claims.newClaims("user")
+	require.NoError(t, err)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = "a"
+	signed, err := token.SignedString(public)
+	require.NoError(t, err)
+
+	_, err = a.ParseToken(signed)
+	assert.Error(t, err)
+}
+
+func TestNewAsymmetricJWT(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edFile := writeKeyPEM(t, "ed", edKey, false)
+	edPublicFile := writeKeyPEM(t, "ed.pub", edKey.Public(), true)
+
+	tests := []struct {
+		name      string
+		cfg       ConfigJWT
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "ok",
+			cfg:       ConfigJWT{SigningKeyID: "a", KeyFiles: map[string]string{"a": edFile}},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "missing signing key",
+			cfg:       ConfigJWT{SigningKeyID: "b", KeyFiles: map[string]string{"a": edFile}},
+			errAssert: assert.Error,
+		},
+		{
+			name:      "public signing key",
+			cfg:       ConfigJWT{SigningKeyID: "a", KeyFiles: map[string]string{"a": edPublicFile}},
+			errAssert: assert.Error,
+		},
+		{
+			name:      "missing file",
+			cfg:       ConfigJWT{SigningKeyID: "a", KeyFiles: map[string]string{"a": edFile + ".missing"}},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		_, err := NewAsymmetricJWT(tt.cfg)
+		tt.errAssert(t, err, tt.name)
+	}
+}
+
+func TestAsymmetricJWT_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	a, err := NewAsymmetricJWT(ConfigJWT{SigningKeyID: "b", KeyFiles: map[string]string{
+		"b": writeKeyPEM(t, "rsa", rsaKey, false),
+		"a": writeKeyPEM(t, "ed", edKey, false),
+	}})
+	require.NoError(t, err)
+
+	set := a.JWKS()
+	require.Len(t, set.Keys, 2)
+
+	ed := set.Keys[0]
+	assert.Equal(t, JWK{KeyType: "OKP", Use: "sig", Algorithm: "EdDSA", KeyID: "a", Curve: "Ed25519", X: ed.X}, ed)
+	x, err := base64.RawURLEncoding.DecodeString(ed.X)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(edPublic), x)
+
+	rs := set.Keys[1]
+	assert.Equal(t, "RSA", rs.KeyType)
+	assert.Equal(t, "RS256", rs.Algorithm)
+	assert.Equal(t, "b", rs.KeyID)
+	assert.Equal(t, "AQAB", rs.E)
+	n, err := base64.RawURLEncoding.DecodeString(rs.N)
+	require.NoError(t, err)
+	assert.Equal(t, rsaKey.N.Bytes(), n)
+}
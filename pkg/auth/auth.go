@@ -1,6 +1,32 @@
 package auth
 
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrMalformedToken   = errors.New("token is malformed")
+	ErrInvalidSignature = errors.New("token signature is invalid")
+	ErrTokenExpired     = errors.New("token is expired")
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("token issuer is invalid")
+	ErrInvalidAudience  = errors.New("token audience is invalid")
+	ErrInvalidSubject   = errors.New("token subject is invalid")
+)
+
+// Claims are what a parsed access token says about its holder. TokenID is
+// unique to the token, so it can be revoked on its own.
+type Claims struct {
+	UserID    any
+	TokenID   string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Authenticator issues and parses access tokens. Errors of ParseToken wrap
+// the error above that describes why the token was rejected.
 type Authenticator interface {
 	GenerateToken(userID any) (string, error)
-	ParseToken(accessToken string) (any, error)
+	ParseToken(accessToken string) (Claims, error)
 }
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
@@ -8,36 +10,51 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
+// ConfigJWT signs with SigningString, or with the key SigningKeyID of
+// KeyFiles, which map key ids to PEM files.
 type ConfigJWT struct {
 	TokenMinutes  time.Duration
+	LeewaySeconds time.Duration
+	Issuer        string
+	Audience      string
 	SigningString string
+	SigningKeyID  string
+	KeyFiles      map[string]string
 }
 
+const tokenIDSize = 16
+
 type JWT struct {
-	tokenTTL      time.Duration
+	claims        claimsConfigJWT
 	signingString string
 }
 
+// claimsJWT carries the encoded user id as the subject.
 type claimsJWT struct {
 	jwt.StandardClaims
-	UserID any
+}
+
+type claimsConfigJWT struct {
+	tokenTTL time.Duration
+	leeway   time.Duration
+	issuer   string
+	audience string
 }
 
 func NewJWT(cfg ConfigJWT) *JWT {
 	return &JWT{
-		tokenTTL:      cfg.TokenMinutes * time.Minute,
+		claims:        newClaimsConfigJWT(cfg),
 		signingString: cfg.SigningString,
 	}
 }
 
 func (s *JWT) GenerateToken(userID any) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &claimsJWT{
-		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(s.tokenTTL).Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
-		userID,
-	})
+	claims, err := s.claims.newClaims(userID)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	accessToken, err := token.SignedString([]byte(s.signingString))
 	if err != nil {
@@ -47,7 +64,7 @@ func (s *JWT) GenerateToken(userID any) (string, error) {
 	return accessToken, nil
 }
 
-func (s *JWT) ParseToken(accessToken string) (any, error) {
+func (s *JWT) ParseToken(accessToken string) (Claims, error) {
 	keyFunc := func(token *jwt.Token) (any, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("invalid signing method")
@@ -56,16 +73,92 @@ func (s *JWT) ParseToken(accessToken string) (any, error) {
 		return []byte(s.signingString), nil
 	}
 
-	token, err := jwt.ParseWithClaims(accessToken, &claimsJWT{}, keyFunc)
+	return s.claims.parse(accessToken, keyFunc)
+}
+
+func newClaimsConfigJWT(cfg ConfigJWT) claimsConfigJWT {
+	return claimsConfigJWT{
+		tokenTTL: cfg.TokenMinutes * time.Minute,
+		leeway:   cfg.LeewaySeconds * time.Second,
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+	}
+}
+
+func (c claimsConfigJWT) newClaims(userID any) (*claimsJWT, error) {
+	subject, ok := userID.(string)
+	if !ok || len(subject) == 0 {
+		return nil, fmt.Errorf("%w: user id must be a non-empty string", ErrInvalidSubject)
+	}
+
+	tokenID := make([]byte, tokenIDSize)
+	if _, err := rand.Read(tokenID); err != nil {
+		return nil, fmt.Errorf("could not generate jwt id: %s", err.Error())
+	}
+
+	now := time.Now()
+
+	return &claimsJWT{
+		jwt.StandardClaims{
+			Id:        hex.EncodeToString(tokenID),
+			Subject:   subject,
+			Issuer:    c.issuer,
+			Audience:  c.audience,
+			ExpiresAt: now.Add(c.tokenTTL).Unix(),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+		},
+	}, nil
+}
+
+// parse verifies the signature of the token with the key of keyFunc before
+// validating its claims, which the jwt package would do without leeway.
+func (c claimsConfigJWT) parse(accessToken string, keyFunc jwt.Keyfunc) (Claims, error) {
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+
+	token, err := parser.ParseWithClaims(accessToken, &claimsJWT{}, keyFunc)
 	if err != nil {
-		return nil, fmt.Errorf("could not parse jwt token: %s", err.Error())
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorMalformed == 0 {
+			return Claims{}, fmt.Errorf("%w: %s", ErrInvalidSignature, err.Error())
+		}
+		return Claims{}, fmt.Errorf("%w: %s", ErrMalformedToken, err.Error())
 	}
 
 	claims, ok := token.Claims.(*claimsJWT)
 	if !ok {
-		err := errors.New(fmt.Sprintf("token claims are not of type %T", claims))
-		return nil, fmt.Errorf("could not cast token claims: %s", err.Error())
+		return Claims{}, fmt.Errorf("%w: token claims are not of type %T", ErrMalformedToken, claims)
+	}
+
+	if err := c.validate(claims, time.Now()); err != nil {
+		return Claims{}, err
+	}
+
+	return Claims{
+		UserID:    claims.Subject,
+		TokenID:   claims.Id,
+		IssuedAt:  time.Unix(claims.IssuedAt, 0),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+func (c claimsConfigJWT) validate(claims *claimsJWT, now time.Time) error {
+	switch {
+	case len(claims.Id) == 0:
+		return fmt.Errorf("%w: missing jti claim", ErrMalformedToken)
+	case claims.ExpiresAt == 0:
+		return fmt.Errorf("%w: missing exp claim", ErrMalformedToken)
+	case len(claims.Subject) == 0:
+		return fmt.Errorf("%w: missing sub claim", ErrInvalidSubject)
+	case now.Add(-c.leeway).Unix() > claims.ExpiresAt:
+		return ErrTokenExpired
+	case now.Add(c.leeway).Unix() < claims.NotBefore, now.Add(c.leeway).Unix() < claims.IssuedAt:
+		return ErrTokenNotValidYet
+	case claims.Issuer != c.issuer:
+		return fmt.Errorf("%w: %q", ErrInvalidIssuer, claims.Issuer)
+	case claims.Audience != c.audience:
+		return fmt.Errorf("%w: %q", ErrInvalidAudience, claims.Audience)
 	}
 
-	return claims.UserID, nil
+	return nil
 }
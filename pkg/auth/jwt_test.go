@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWT_ParseToken(t *testing.T) {
+	cfg := ConfigJWT{
+		TokenMinutes:  15,
+		LeewaySeconds: 30,
+		Issuer:        "todo-service",
+		Audience:      "todo-api",
+		SigningString: "secret",
+	}
+	j := NewJWT(cfg)
+
+	now := time.Now()
+	validClaims := func() jwt.StandardClaims {
+		return jwt.StandardClaims{
+			Id:        "id",
+			Subject:   "user",
+			Issuer:    cfg.Issuer,
+			Audience:  cfg.Audience,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(time.Minute).Unix(),
+		}
+	}
+	sign := func(claims jwt.StandardClaims, signingString string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claimsJWT{claims}).
+			SignedString([]byte(signingString))
+		require.NoError(t, err)
+		return token
+	}
+
+	tests := []struct {
+		name  string
+		edit  func(c *jwt.StandardClaims)
+		token string
+		want  error
+	}{
+		{
+			name: "ok",
+			edit: func(c *jwt.StandardClaims) {},
+		},
+		{
+			name: "ok expired within leeway",
+			edit: func(c *jwt.StandardClaims) { c.ExpiresAt = now.Add(-10 * time.Second).Unix() },
+		},
+		{
+			name: "ok issued ahead within leeway",
+			edit: func(c *jwt.StandardClaims) {
+				c.IssuedAt = now.Add(10 * time.Second).Unix()
+				c.NotBefore = c.IssuedAt
+			},
+		},
+		{
+			name: "expired",
+			edit: func(c *jwt.StandardClaims) { c.ExpiresAt = now.Add(-time.Minute).Unix() },
+			want: ErrTokenExpired,
+		},
+		{
+			name: "not valid yet",
+			edit: func(c *jwt.StandardClaims) { c.NotBefore = now.Add(time.Minute).Unix() },
+			want: ErrTokenNotValidYet,
+		},
+		{
+			name: "issuer",
+			edit: func(c *jwt.StandardClaims) { c.Issuer = "other" },
+			want: ErrInvalidIssuer,
+		},
+		{
+			name: "audience",
+			edit: func(c *jwt.StandardClaims) { c.Audience = "other" },
+			want: ErrInvalidAudience,
+		},
+		{
+			name: "missing subject",
+			edit: func(c *jwt.StandardClaims) { c.Subject = "" },
+			want: ErrInvalidSubject,
+		},
+		{
+			name: "missing jti",
+			edit: func(c *jwt.StandardClaims) { c.Id = "" },
+			want: ErrMalformedToken,
+		},
+		{
+			name:  "signature",
+			token: sign(validClaims(), "other"),
+			want:  ErrInvalidSignature,
+		},
+		{
+			name:  "malformed",
+			token: "token",
+			want:  ErrMalformedToken,
+		},
+	}
+	for _, tt := range tests {
+		token := tt.token
+		if len(token) == 0 {
+			claims := validClaims()
+			tt.edit(&claims)
+			token = sign(claims, cfg.SigningString)
+		}
+
+		claims, err := j.ParseToken(token)
+		if tt.want != nil {
+			assert.ErrorIs(t, err, tt.want, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, "user", claims.UserID, tt.name)
+		assert.Equal(t, "id", claims.TokenID, tt.name)
+	}
+}
+
+func TestJWT_GenerateToken(t *testing.T) {
+	j := NewJWT(ConfigJWT{TokenMinutes: 15, Issuer: "todo-service", Audience: "todo-api", SigningString: "secret"})
+
+	token, err := j.GenerateToken("user")
+	require.NoError(t, err)
+
+	claims, err := j.ParseToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user", claims.UserID)
+	assert.Equal(t, 15*time.Minute, claims.ExpiresAt.Sub(claims.IssuedAt))
+
+	other := NewJWT(ConfigJWT{TokenMinutes: 15, Issuer: "todo-service", Audience: "other", SigningString: "secret"})
+	_, err = other.ParseToken(token)
+	assert.ErrorIs(t, err, ErrInvalidAudience)
+
+	_, err = j.GenerateToken(uint(1))
+	assert.ErrorIs(t, err, ErrInvalidSubject)
+}
@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	PurposeLocal  = "local"
+	PurposePublic = "public"
+)
+
+// ConfigPASETO selects PASETO v4 tokens over JWT when Purpose is set. Key is
+// hex encoded.
+type ConfigPASETO struct {
+	TokenMinutes  time.Duration
+	LeewaySeconds time.Duration
+	Issuer        string
+	Audience      string
+	Purpose       string
+	Key           string
+}
+
+const (
+	pasetoNonceSize = 32
+	pasetoMACSize   = 32
+)
+
+type PASETO struct {
+	tokenTTL   time.Duration
+	leeway     time.Duration
+	issuer     string
+	audience   string
+	header     string
+	localKey   []byte
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+type claimsPASETO struct {
+	Subject   string    `json:"sub"`
+	TokenID   string    `json:"jti"`
+	Issuer    string    `json:"iss"`
+	Audience  string    `json:"aud"`
+	IssuedAt  time.Time `json:"iat"`
+	NotBefore time.Time `json:"nbf"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+func NewPASETO(cfg ConfigPASETO) (*PASETO, error) {
+	key, err := hex.DecodeString(cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode paseto key: %s", err.Error())
+	}
+
+	p := &PASETO{
+		tokenTTL: cfg.TokenMinutes * time.Minute,
+		leeway:   cfg.LeewaySeconds * time.Second,
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		header:   "v4." + cfg.Purpose + ".",
+	}
+
+	switch cfg.Purpose {
+	case PurposeLocal:
+		if len(key) != chacha20.KeySize {
+			return nil, fmt.Errorf("paseto local key must be %d bytes", chacha20.KeySize)
+		}
+		p.localKey = key
+	case PurposePublic:
+		switch len(key) {
+		case ed25519.SeedSize:
+			p.privateKey = ed25519.NewKeyFromSeed(key)
+		case ed25519.PrivateKeySize:
+			p.privateKey = ed25519.PrivateKey(key)
+		default:
+			return nil, fmt.Errorf("paseto public key must be %d or %d bytes",
+				ed25519.SeedSize, ed25519.PrivateKeySize)
+		}
+		p.publicKey = p.privateKey.Public().(ed25519.PublicKey)
+	default:
+		return nil, fmt.Errorf("unsupported paseto purpose %q", cfg.Purpose)
+	}
+
+	return p, nil
+}
+
+func (p *PASETO) GenerateToken(userID any) (string, error) {
+	subject, ok := userID.(string)
+	if !ok || len(subject) == 0 {
+		return "", fmt.Errorf("%w: user id must be a non-empty string", ErrInvalidSubject)
+	}
+
+	tokenID := make([]byte, tokenIDSize)
+	if _, err := rand.Read(tokenID); err != nil {
+		return "", fmt.Errorf("could not generate paseto id: %s", err.Error())
+	}
+
+	now := time.Now()
+	payload, err := json.Marshal(claimsPASETO{
+		Subject:   subject,
+		TokenID:   hex.EncodeToString(tokenID),
+		Issuer:    p.issuer,
+		Audience:  p.audience,
+		IssuedAt:  now,
+		NotBefore: now,
+		ExpiresAt: now.Add(p.tokenTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not marshal paseto claims: %s", err.Error())
+	}
+
+	var body []byte
+	if p.localKey != nil {
+		body, err = p.encrypt(payload)
+		if err != nil {
+			return "", fmt.Errorf("could not encrypt paseto token: %s", err.Error())
+		}
+	} else {
+		body = p.sign(payload)
+	}
+
+	return p.header + base64.RawURLEncoding.EncodeToString(body), nil
+}
+
+func (p *PASETO) ParseToken(accessToken string) (Claims, error) {
+	payload, err := p.open(accessToken)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	var claims claimsPASETO
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, fmt.Errorf("%w: could not unmarshal paseto claims: %s", ErrMalformedToken, err.Error())
+	}
+
+	if err := p.validate(claims, time.Now()); err != nil {
+		return Claims{}, err
+	}
+
+	return Claims{
+		UserID:    claims.Subject,
+		TokenID:   claims.TokenID,
+		IssuedAt:  claims.IssuedAt,
+		ExpiresAt: claims.ExpiresAt,
+	}, nil
+}
+
+func (p *PASETO) validate(claims claimsPASETO, now time.Time) error {
+	switch {
+	case len(claims.TokenID) == 0:
+		return fmt.Errorf("%w: missing jti claim", ErrMalformedToken)
+	case claims.ExpiresAt.IsZero():
+		return fmt.Errorf("%w: missing exp claim", ErrMalformedToken)
+	case len(claims.Subject) == 0:
+		return fmt.Errorf("%w: missing sub claim", ErrInvalidSubject)
+	case !now.Add(-p.leeway).Before(claims.ExpiresAt):
+		return ErrTokenExpired
+	case now.Add(p.leeway).Before(claims.NotBefore), now.Add(p.leeway).Before(claims.IssuedAt):
+		return ErrTokenNotValidYet
+	case claims.Issuer != p.issuer:
+		return fmt.Errorf("%w: %q", ErrInvalidIssuer, claims.Issuer)
+	case claims.Audience != p.audience:
+		return fmt.Errorf("%w: %q", ErrInvalidAudience, claims.Audience)
+	}
+
+	return nil
+}
+
+// open rejects tokens with a footer, since none are issued.
+func (p *PASETO) open(token string) ([]byte, error) {
+	if !strings.HasPrefix(token, p.header) {
+		return nil, fmt.Errorf("%w: invalid header", ErrMalformedToken)
+	}
+
+	encoded := token[len(p.header):]
+	if strings.Contains(encoded, ".") {
+		return nil, fmt.Errorf("%w: unexpected footer", ErrMalformedToken)
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decode token: %s", ErrMalformedToken, err.Error())
+	}
+
+	if p.localKey != nil {
+		return p.decrypt(body)
+	}
+
+	return p.verify(body)
+}
+
+// encrypt implements v4.local encryption with an empty footer and implicit
+// assertion, returning the nonce, the ciphertext and the tag.
+func (p *PASETO) encrypt(payload []byte) ([]byte, error) {
+	nonce := make([]byte, pasetoNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	encryptionKey, counterNonce, authKey := p.splitLocalKey(nonce)
+
+	cipher, err := chacha20.NewUnauthenticatedCipher(encryptionKey, counterNonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, len(payload))
+	cipher.XORKeyStream(ciphertext, payload)
+
+	tag := keyedBlake2b(pasetoMACSize, authKey, pae([]byte(p.header), nonce, ciphertext, nil, nil))
+
+	body := make([]byte, 0, len(nonce)+len(ciphertext)+len(tag))
+	body = append(body, nonce...)
+	body = append(body, ciphertext...)
+
+	return append(body, tag...), nil
+}
+
+func (p *PASETO) decrypt(body []byte) ([]byte, error) {
+	if len(body) < pasetoNonceSize+pasetoMACSize {
+		return nil, fmt.Errorf("%w: token is too short", ErrMalformedToken)
+	}
+
+	nonce := body[:pasetoNonceSize]
+	ciphertext := body[pasetoNonceSize : len(body)-pasetoMACSize]
+	tag := body[len(body)-pasetoMACSize:]
+
+	encryptionKey, counterNonce, authKey := p.splitLocalKey(nonce)
+
+	want := keyedBlake2b(pasetoMACSize, authKey, pae([]byte(p.header), nonce, ciphertext, nil, nil))
+	if subtle.ConstantTimeCompare(tag, want) != 1 {
+		return nil, fmt.Errorf("%w: invalid authentication tag", ErrInvalidSignature)
+	}
+
+	cipher, err := chacha20.NewUnauthenticatedCipher(encryptionKey, counterNonce)
+	if err != nil {
+		return nil, err
+	}
+	payload := make([]byte, len(ciphertext))
+	cipher.XORKeyStream(payload, ciphertext)
+
+	return payload, nil
+}
+
+// splitLocalKey derives the encryption key, the XChaCha20 nonce and the
+// authentication key of a v4.local token from the key and its nonce.
+func (p *PASETO) splitLocalKey(nonce []byte) ([]byte, []byte, []byte) {
+	const (
+		encryptionInfo = "paseto-encryption-key"
+		authInfo       = "paseto-auth-key-for-aead"
+	)
+
+	tmp := keyedBlake2b(chacha20.KeySize+chacha20.NonceSizeX, p.localKey,
+		append([]byte(encryptionInfo), nonce...))
+	authKey := keyedBlake2b(chacha20.KeySize, p.localKey, append([]byte(authInfo), nonce...))
+
+	return tmp[:chacha20.KeySize], tmp[chacha20.KeySize:], authKey
+}
+
+// sign implements v4.public signing with an empty footer and implicit
+// assertion, returning the payload followed by its signature.
+func (p *PASETO) sign(payload []byte) []byte {
+	signature := ed25519.Sign(p.privateKey, pae([]byte(p.header), payload, nil, nil))
+
+	return append(append([]byte{}, payload...), signature...)
+}
+
+func (p *PASETO) verify(body []byte) ([]byte, error) {
+	if len(body) < ed25519.SignatureSize {
+		return nil, fmt.Errorf("%w: token is too short", ErrMalformedToken)
+	}
+
+	payload := body[:len(body)-ed25519.SignatureSize]
+	signature := body[len(body)-ed25519.SignatureSize:]
+
+	if !ed25519.Verify(p.publicKey, pae([]byte(p.header), payload, nil, nil), signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	return payload, nil
+}
+
+// pae is the pre-authentication encoding of PASETO, which prefixes the pieces
+// and each of them with its length as a little-endian 64-bit integer.
+func pae(pieces ...[]byte) []byte {
+	var buf bytes.Buffer
+
+	writeLength := func(n int) {
+		var length [8]byte
+		binary.LittleEndian.PutUint64(length[:], uint64(n)&(1<<63-1))
+		buf.Write(length[:])
+	}
+
+	writeLength(len(pieces))
+	for _, piece := range pieces {
+		writeLength(len(piece))
+		buf.Write(piece)
+	}
+
+	return buf.Bytes()
+}
+
+func keyedBlake2b(size int, key, data []byte) []byte {
+	// the size and key are valid for every call
+	h, _ := blake2b.New(size, key)
+	h.Write(data)
+
+	return h.Sum(nil)
+}
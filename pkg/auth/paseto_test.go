@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testLocalKey  = "707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f"
+	testPublicKey = "b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a3774" +
+		"1eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2"
+)
+
+func TestPAE(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces [][]byte
+		want   string
+	}{
+		{
+			name: "no pieces",
+			want: "0000000000000000",
+		},
+		{
+			name:   "empty piece",
+			pieces: [][]byte{{}},
+			want:   "0100000000000000" + "0000000000000000",
+		},
+		{
+			name:   "test",
+			pieces: [][]byte{[]byte("test")},
+			want:   "0100000000000000" + "0400000000000000" + hex.EncodeToString([]byte("test")),
+		},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, hex.EncodeToString(pae(tt.pieces...)), tt.name)
+	}
+}
+
+func TestPASETO_verify(t *testing.T) {
+	// test vector 4-S-1 of the PASETO specification
+	const token = "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9" +
+		"bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA"
+
+	p, err := NewPASETO(ConfigPASETO{Purpose: PurposePublic, Key: testPublicKey})
+	require.NoError(t, err)
+
+	payload, err := p.open(token)
+	require.NoError(t, err)
+	assert.Equal(t, `{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`, string(payload))
+
+	// Ed25519 signatures are deterministic, so signing gives the same token
+	assert.Equal(t, token, p.header+base64.RawURLEncoding.EncodeToString(p.sign(payload)))
+}
+
+func TestPASETO(t *testing.T) {
+	local, err := NewPASETO(ConfigPASETO{TokenMinutes: 15, Purpose: PurposeLocal, Key: testLocalKey})
+	require.NoError(t, err)
+	public, err := NewPASETO(ConfigPASETO{TokenMinutes: 15, Purpose: PurposePublic, Key: testPublicKey[:64]})
+	require.NoError(t, err)
+	otherLocal, err := NewPASETO(ConfigPASETO{TokenMinutes: 15, Purpose: PurposeLocal, Key: strings.Repeat("00", 32)})
+	require.NoError(t, err)
+	expired, err := NewPASETO(ConfigPASETO{Purpose: PurposeLocal, Key: testLocalKey})
+	require.NoError(t, err)
+
+	tamper := func(token string) string {
+		last := token[len(token)-1]
+		if last == 'A' {
+			return token[:len(token)-1] + "B"
+		}
+		return token[:len(token)-1] + "A"
+	}
+
+	tests := []struct {
+		name      string
+		signer    *PASETO
+		verifier  *PASETO
+		edit      func(string) string
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "ok local",
+			signer:    local,
+			verifier:  local,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok public",
+			signer:    public,
+			verifier:  public,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "tampered local",
+			signer:    local,
+			verifier:  local,
+			edit:      tamper,
+			errAssert: assert.Error,
+		},
+		{
+			name:      "tampered public",
+			signer:    public,
+			verifier:  public,
+			edit:      tamper,
+			errAssert: assert.Error,
+		},
+		{
+			name:      "footer",
+			signer:    local,
+			verifier:  local,
+			edit:      func(token string) string { return token + ".e30" },
+			errAssert: assert.Error,
+		},
+		{
+			name:      "other key",
+			signer:    local,
+			verifier:  otherLocal,
+			errAssert: assert.Error,
+		},
+		{
+			name:      "other purpose",
+			signer:    public,
+			verifier:  local,
+			errAssert: assert.Error,
+		},
+		{
+			name:      "expired",
+			signer:    expired,
+			verifier:  expired,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		token, err := tt.signer.GenerateToken("user")
+		require.NoError(t, err, tt.name)
+		assert.True(t, strings.HasPrefix(token, tt.signer.header), tt.name)
+		if tt.edit != nil {
+			token = tt.edit(token)
+		}
+
+		claims, err := tt.verifier.ParseToken(token)
+		tt.errAssert(t, err, tt.name)
+		if err == nil {
+			assert.Equal(t, "user", claims.UserID, tt.name)
+			assert.NotEmpty(t, claims.TokenID, tt.name)
+			assert.True(t, claims.IssuedAt.Before(claims.ExpiresAt), tt.name)
+		}
+	}
+}
+
+func TestPASETO_ParseToken(t *testing.T) {
+	cfg := ConfigPASETO{
+		TokenMinutes:  15,
+		LeewaySeconds: 30,
+		Issuer:        "todo-service",
+		Audience:      "todo-api",
+		Purpose:       PurposeLocal,
+		Key:           testLocalKey,
+	}
+	p, err := NewPASETO(cfg)
+	require.NoError(t, err)
+
+	now := time.Now()
+	validClaims := func() claimsPASETO {
+		return claimsPASETO{
+			Subject:   "user",
+			TokenID:   "id",
+			Issuer:    cfg.Issuer,
+			Audience:  cfg.Audience,
+			IssuedAt:  now,
+			NotBefore: now,
+			ExpiresAt: now.Add(time.Minute),
+		}
+	}
+
+	tests := []struct {
+		name string
+		edit func(c *claimsPASETO)
+		want error
+	}{
+		{
+			name: "ok",
+			edit: func(c *claimsPASETO) {},
+		},
+		{
+			name: "ok expired within leeway",
+			edit: func(c *claimsPASETO) { c.ExpiresAt = now.Add(-10 * time.Second) },
+		},
+		{
+			name: "ok issued ahead within leeway",
+			edit: func(c *claimsPASETO) {
+				c.IssuedAt = now.Add(10 * time.Second)
+				c.NotBefore = c.IssuedAt
+			},
+		},
+		{
+			name: "expired",
+			edit: func(c *claimsPASETO) { c.ExpiresAt = now.Add(-time.Minute) },
+			want: ErrTokenExpired,
+		},
+		{
+			name: "not valid yet",
+			edit: func(c *claimsPASETO) { c.NotBefore = now.Add(time.Minute) },
+			want: ErrTokenNotValidYet,
+		},
+		{
+			name: "issuer",
+			edit: func(c *claimsPASETO) { c.Issuer = "other" },
+			want: ErrInvalidIssuer,
+		},
+		{
+			name: "audience",
+			edit: func(c *claimsPASETO) { c.Audience = "other" },
+			want: ErrInvalidAudience,
+		},
+		{
+			name: "missing subject",
+			edit: func(c *claimsPASETO) { c.Subject = "" },
+			want: ErrInvalidSubject,
+		},
+		{
+			name: "missing jti",
+			edit: func(c *claimsPASETO) { c.TokenID = "" },
+			want: ErrMalformedToken,
+		},
+	}
+	for _, tt := range tests {
+		claims := validClaims()
+		tt.edit(&claims)
+		payload, err := json.Marshal(claims)
+		require.NoError(t, err, tt.name)
+		body, err := p.encrypt(payload)
+		require.NoError(t, err, tt.name)
+
+		got, err := p.ParseToken(p.header + base64.RawURLEncoding.EncodeToString(body))
+		if tt.want != nil {
+			assert.ErrorIs(t, err, tt.want, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, "user", got.UserID, tt.name)
+		assert.Equal(t, "id", got.TokenID, tt.name)
+	}
+}
+
+func TestNewPASETO(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       ConfigPASETO
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "ok local",
+			cfg:       ConfigPASETO{Purpose: PurposeLocal, Key: testLocalKey},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok public seed",
+			cfg:       ConfigPASETO{Purpose: PurposePublic, Key: testPublicKey[:64]},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok public private key",
+			cfg:       ConfigPASETO{Purpose: PurposePublic, Key: testPublicKey},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "short local key",
+			cfg:       ConfigPASETO{Purpose: PurposeLocal, Key: testLocalKey[:32]},
+			errAssert: assert.Error,
+		},
+		{
+			name:      "not hex",
+			cfg:       ConfigPASETO{Purpose: PurposeLocal, Key: "key"},
+			errAssert: assert.Error,
+		},
+		{
+			name:      "unknown purpose",
+			cfg:       ConfigPASETO{Purpose: "secret", Key: testLocalKey},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		_, err := NewPASETO(tt.cfg)
+		tt.errAssert(t, err, tt.name)
+	}
+}
@@ -0,0 +1,25 @@
+package broker
+
+// Event is a message published on a topic. IDs grow with every event published
+// to a broker, across all of its topics.
+type Event struct {
+	ID   uint64
+	Name string
+	Data any
+}
+
+// Subscription is closed once it is cancelled or the subscriber falls behind.
+type Subscription struct {
+	Events <-chan Event
+	// Missed reports that some of the events following the last event id
+	// are no longer kept, so the subscriber has to catch up some other way.
+	Missed bool
+	// LastID is the id of the last event published before the subscription.
+	LastID uint64
+	Cancel func()
+}
+
+type Broker interface {
+	Publish(topic any, name string, data any)
+	Subscribe(topic any, lastEventID uint64) Subscription
+}
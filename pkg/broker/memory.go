@@ -0,0 +1,175 @@
+package broker
+
+import (
+	"sync"
+	"time"
+)
+
+type cfgKey string
+
+const (
+	TodoKey cfgKey = "todo"
+)
+
+type ConfigMemory struct {
+	HistorySizes     map[cfgKey]int
+	BufferSizes      map[cfgKey]int
+	RetentionMinutes map[cfgKey]time.Duration
+}
+
+// Memory is a broker for a single process. Idle topics without subscribers
+// are evicted after the retention period.
+type Memory struct {
+	mu          sync.Mutex
+	historySize int
+	bufferSize  int
+	retention   time.Duration
+	now         func() time.Time
+	lastID      uint64
+	// evictedID is the id of the last event of the evicted topics, which new
+	// topics start from as if it fell out of their history.
+	evictedID uint64
+	sweptAt   time.Time
+	topics    map[any]*topic
+}
+
+type topic struct {
+	history []Event
+	// droppedID is the id of the last event that fell out of the history.
+	droppedID   uint64
+	publishedAt time.Time
+	subscribers map[chan Event]struct{}
+}
+
+func NewMemory(cfg ConfigMemory, cfgKey cfgKey) *Memory {
+	historySize := cfg.HistorySizes[cfgKey]
+	bufferSize := cfg.BufferSizes[cfgKey]
+	retention := cfg.RetentionMinutes[cfgKey] * time.Minute
+
+	switch {
+	case historySize < 0:
+		panic("broker history size cannot be less than 0")
+	case bufferSize < 1:
+		panic("broker buffer size must be 1 or more")
+	case retention < time.Minute:
+		panic("broker retention must be 1 minute or more")
+	}
+
+	return &Memory{
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		retention:   retention,
+		now:         time.Now,
+		topics:      make(map[any]*topic),
+	}
+}
+
+// Publish never blocks. A subscriber whose buffer is full is dropped, and is
+// expected to subscribe again with the id of the last event it received.
+func (b *Memory) Publish(topicKey any, name string, data any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.evict(now)
+
+	b.lastID++
+	event := Event{ID: b.lastID, Name: name, Data: data}
+
+	t := b.topic(topicKey)
+	t.publishedAt = now
+	t.history = append(t.history, event)
+	if len(t.history) > b.historySize {
+		dropped := len(t.history) - b.historySize
+		t.droppedID = t.history[dropped-1].ID
+		t.history = append(t.history[:0], t.history[dropped:]...)
+	}
+
+	for events := range t.subscribers {
+		select {
+		case events <- event:
+		default:
+			delete(t.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// Subscribe reports an id the broker has not given out yet as missed.
+func (b *Memory) Subscribe(topicKey any, lastEventID uint64) Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.evict(b.now())
+
+	t := b.topic(topicKey)
+
+	var replay []Event
+	missed := false
+	if lastEventID != 0 {
+		missed = lastEventID < t.droppedID || lastEventID > b.lastID
+		for _, event := range t.history {
+			if event.ID > lastEventID {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	events := make(chan Event, len(replay)+b.bufferSize)
+	for _, event := range replay {
+		events <- event
+	}
+	t.subscribers[events] = struct{}{}
+
+	return Subscription{
+		Events: events,
+		Missed: missed,
+		LastID: b.lastID,
+		Cancel: func() {
+			b.unsubscribe(topicKey, events)
+		},
+	}
+}
+
+func (b *Memory) unsubscribe(topicKey any, events chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t, ok := b.topics[topicKey]
+	if !ok {
+		return
+	}
+	if _, ok := t.subscribers[events]; ok {
+		delete(t.subscribers, events)
+		close(events)
+	}
+}
+
+func (b *Memory) topic(topicKey any) *topic {
+	t, ok := b.topics[topicKey]
+	if !ok {
+		t = &topic{droppedID: b.evictedID, subscribers: make(map[chan Event]struct{})}
+		b.topics[topicKey] = t
+	}
+
+	return t
+}
+
+// evict removes the topics without subscribers that have not been published
+// to for the retention period. Topics are checked at most once a period.
+func (b *Memory) evict(now time.Time) {
+	if now.Sub(b.sweptAt) < b.retention {
+		return
+	}
+	b.sweptAt = now
+
+	for topicKey, t := range b.topics {
+		if len(t.subscribers) != 0 || now.Sub(t.publishedAt) < b.retention {
+			continue
+		}
+		if n := len(t.history); n != 0 && t.history[n-1].ID > b.evictedID {
+			b.evictedID = t.history[n-1].ID
+		}
+		delete(b.topics, topicKey)
+	}
+}
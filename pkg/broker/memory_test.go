@@ -0,0 +1,152 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type result struct {
+	ids    []uint64
+	missed bool
+	closed bool
+}
+
+func TestMemory(t *testing.T) {
+	const key = TodoKey
+
+	cfg := ConfigMemory{
+		HistorySizes: map[cfgKey]int{
+			key: 2,
+		},
+		BufferSizes: map[cfgKey]int{
+			key: 2,
+		},
+		RetentionMinutes: map[cfgKey]time.Duration{
+			key: 1,
+		},
+	}
+
+	tests := []struct {
+		name     string
+		testCase func(b *Memory) result
+		want     result
+	}{
+		{
+			name: "live events",
+			testCase: func(b *Memory) result {
+				b.Publish(1, "created", nil)
+				sub := b.Subscribe(1, 0)
+				b.Publish(1, "updated", nil)
+				b.Publish(2, "created", nil)
+				b.Publish(1, "deleted", nil)
+				sub.Cancel()
+
+				return drain(sub)
+			},
+			want: result{ids: []uint64{2, 4}, closed: true},
+		},
+		{
+			name: "resume",
+			testCase: func(b *Memory) result {
+				b.Publish(1, "created", nil)
+				b.Publish(2, "created", nil)
+				b.Publish(1, "updated", nil)
+				sub := b.Subscribe(1, 1)
+				b.Publish(1, "deleted", nil)
+				sub.Cancel()
+
+				return drain(sub)
+			},
+			want: result{ids: []uint64{3, 4}, closed: true},
+		},
+		{
+			name: "resume after history dropped",
+			testCase: func(b *Memory) result {
+				b.Publish(1, "created", nil)
+				b.Publish(1, "updated", nil)
+				b.Publish(1, "updated", nil)
+				b.Publish(1, "deleted", nil)
+				sub := b.Subscribe(1, 1)
+				sub.Cancel()
+
+				return drain(sub)
+			},
+			want: result{ids: []uint64{3, 4}, missed: true, closed: true},
+		},
+		{
+			name: "resume from unknown id",
+			testCase: func(b *Memory) result {
+				b.Publish(1, "created", nil)
+				sub := b.Subscribe(1, 7)
+				sub.Cancel()
+
+				return drain(sub)
+			},
+			want: result{missed: true, closed: true},
+		},
+		{
+			name: "slow subscriber",
+			testCase: func(b *Memory) result {
+				sub := b.Subscribe(1, 0)
+				b.Publish(1, "created", nil)
+				b.Publish(1, "updated", nil)
+				b.Publish(1, "deleted", nil)
+				b.Publish(1, "created", nil)
+				sub.Cancel()
+
+				return drain(sub)
+			},
+			want: result{ids: []uint64{1, 2}, closed: true},
+		},
+		{
+			name: "evict idle topic",
+			testCase: func(b *Memory) result {
+				b.Publish(1, "created", nil)
+				b.Publish(2, "created", nil)
+				b.now = func() time.Time { return time.Now().Add(time.Minute) }
+				b.Publish(3, "created", nil)
+				sub := b.Subscribe(1, 1)
+				sub.Cancel()
+
+				return drain(sub)
+			},
+			want: result{missed: true, closed: true},
+		},
+		{
+			name: "keep subscribed topic",
+			testCase: func(b *Memory) result {
+				sub := b.Subscribe(1, 0)
+				b.Publish(1, "created", nil)
+				b.now = func() time.Time { return time.Now().Add(time.Minute) }
+				b.Publish(2, "created", nil)
+				b.Publish(1, "updated", nil)
+				sub.Cancel()
+
+				return drain(sub)
+			},
+			want: result{ids: []uint64{1, 3}, closed: true},
+		},
+	}
+	for _, tt := range tests {
+		got := tt.testCase(NewMemory(cfg, key))
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func drain(sub Subscription) result {
+	res := result{missed: sub.Missed}
+	for {
+		select {
+		case event, ok := <-sub.Events:
+			if !ok {
+				res.closed = true
+				return res
+			}
+			res.ids = append(res.ids, event.ID)
+		default:
+			return res
+		}
+	}
+}
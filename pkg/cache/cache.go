@@ -4,9 +4,19 @@ type Cache interface {
 	SetValue(key, val any)
 	GetValue(key any) any
 	RemoveValue(key any)
+	RemoveGroup(group any)
+}
+
+// Grouped keys can be removed together with the other keys of their group.
+type Grouped interface {
+	Group() any
 }
 
 type TodoCacheKey struct {
 	UserID uint
 	Args   any
 }
+
+func (k TodoCacheKey) Group() any {
+	return k.UserID
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Expiring is a cache of values that are only valid until a given time.
+// Expired values are never returned, and Prune frees the memory they hold.
+type Expiring struct {
+	mu     sync.Mutex
+	values map[any]expiringValue
+}
+
+type expiringValue struct {
+	val       any
+	expiresAt time.Time
+}
+
+func NewExpiring() *Expiring {
+	return &Expiring{
+		values: make(map[any]expiringValue),
+	}
+}
+
+func (c *Expiring) SetValue(key, val any, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[key] = expiringValue{val: val, expiresAt: expiresAt}
+}
+
+func (c *Expiring) GetValue(key any) any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	found, ok := c.values[key]
+	if !ok || !time.Now().Before(found.expiresAt) {
+		return nil
+	}
+
+	return found.val
+}
+
+func (c *Expiring) RemoveValue(key any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.values, key)
+}
+
+// Prune removes the values that have expired by now.
+func (c *Expiring) Prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, found := range c.values {
+		if !now.Before(found.expiresAt) {
+			delete(c.values, key)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiring(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		testCase func(c *Expiring) []any
+		want     []any
+	}{
+		{
+			name: "get before expiry",
+			testCase: func(c *Expiring) []any {
+				c.SetValue(1, true, now.Add(time.Hour))
+				c.SetValue("1", false, now.Add(time.Hour))
+
+				return []any{c.GetValue(1), c.GetValue("1"), c.GetValue(2)}
+			},
+			want: []any{true, false, nil},
+		},
+		{
+			name: "get after expiry",
+			testCase: func(c *Expiring) []any {
+				c.SetValue(1, true, now.Add(-time.Second))
+
+				return []any{c.GetValue(1)}
+			},
+			want: []any{nil},
+		},
+		{
+			name: "replace and remove",
+			testCase: func(c *Expiring) []any {
+				var results []any
+
+				c.SetValue(1, 1, now.Add(-time.Second))
+				c.SetValue(1, 2, now.Add(time.Hour))
+				results = append(results, c.GetValue(1))
+				c.RemoveValue(1)
+				results = append(results, c.GetValue(1))
+
+				return results
+			},
+			want: []any{2, nil},
+		},
+		{
+			name: "prune",
+			testCase: func(c *Expiring) []any {
+				c.SetValue(1, 1, now.Add(time.Minute))
+				c.SetValue(2, 2, now.Add(time.Hour))
+				c.Prune(now.Add(30 * time.Minute))
+
+				return []any{len(c.values), c.GetValue(2)}
+			},
+			want: []any{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		got := tt.testCase(NewExpiring())
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
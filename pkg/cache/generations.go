@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"sync"
+)
+
+// Generations counts cache invalidations per user. Incrementing the generation
+// of a user removes their values from the cache, and values read under an older
+// generation are not cached.
+type Generations struct {
+	mu          sync.Mutex
+	cache       Cache
+	capacity    int
+	latest      uint
+	floor       uint
+	generations map[uint]uint
+}
+
+func NewGenerations(cfg ConfigLFU, cfgKey cfgKey, cache Cache) *Generations {
+	return &Generations{
+		cache:       cache,
+		capacity:    cfg.Capacities[cfgKey],
+		generations: make(map[uint]uint),
+	}
+}
+
+func (g *Generations) Get(userID uint) uint {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.get(userID)
+}
+
+// SetValue caches the value unless the generation of the user has changed
+// since it was read.
+func (g *Generations) SetValue(userID, generation uint, key, val any) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.get(userID) == generation {
+		g.cache.SetValue(key, val)
+	}
+}
+
+// Increment moves the users to a new generation. Once more users are tracked
+// than the cache holds values, every user is moved to the latest generation,
+// so that only values read before now are refused.
+func (g *Generations) Increment(userIDs ...uint) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.generations)+len(userIDs) > g.capacity {
+		g.floor = g.latest
+		g.generations = make(map[uint]uint)
+	}
+
+	for _, userID := range userIDs {
+		g.latest++
+		g.generations[userID] = g.latest
+		g.cache.RemoveGroup(userID)
+	}
+}
+
+func (g *Generations) get(userID uint) uint {
+	if generation, ok := g.generations[userID]; ok {
+		return generation
+	}
+
+	return g.floor
+}
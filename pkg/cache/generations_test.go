@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerations(t *testing.T) {
+	const key = TodoKey
+
+	cfg := ConfigLFU{
+		Capacities: map[cfgKey]int{
+			key: 3,
+		},
+		CleanupSizes: map[cfgKey]int{
+			key: 1,
+		},
+	}
+
+	tests := []struct {
+		name     string
+		testCase func(g *Generations, c *LFU) []any
+		want     []any
+	}{
+		{
+			name: "unknown user",
+			testCase: func(g *Generations, c *LFU) []any {
+				return []any{g.Get(1)}
+			},
+			want: []any{uint(0)},
+		},
+		{
+			name: "increment",
+			testCase: func(g *Generations, c *LFU) []any {
+				var results []any
+
+				g.Increment(1)
+				results = append(results, g.Get(1))
+				g.Increment(1)
+				results = append(results, g.Get(1))
+
+				return results
+			},
+			want: []any{uint(1), uint(2)},
+		},
+		{
+			name: "increment several users",
+			testCase: func(g *Generations, c *LFU) []any {
+				g.Increment(1, 2)
+				g.Increment(2)
+
+				return []any{g.Get(1), g.Get(2), g.Get(3)}
+			},
+			want: []any{uint(1), uint(3), uint(0)},
+		},
+		{
+			name: "remove values on increment",
+			testCase: func(g *Generations, c *LFU) []any {
+				g.SetValue(1, g.Get(1), TodoCacheKey{UserID: 1, Args: 1}, 1)
+				g.SetValue(2, g.Get(2), TodoCacheKey{UserID: 2, Args: 1}, 2)
+				g.Increment(1)
+
+				return []any{c.GetValue(TodoCacheKey{UserID: 1, Args: 1}), c.GetValue(TodoCacheKey{UserID: 2, Args: 1})}
+			},
+			want: []any{nil, 2},
+		},
+		{
+			name: "refuse value read before increment",
+			testCase: func(g *Generations, c *LFU) []any {
+				generation := g.Get(1)
+				g.Increment(1)
+				g.SetValue(1, generation, TodoCacheKey{UserID: 1, Args: 1}, 1)
+
+				return []any{c.GetValue(TodoCacheKey{UserID: 1, Args: 1})}
+			},
+			want: []any{nil},
+		},
+		{
+			name: "refuse value read before forgetting users",
+			testCase: func(g *Generations, c *LFU) []any {
+				generation := g.Get(1)
+				g.Increment(1)
+				g.Increment(2, 3, 4)
+				g.SetValue(1, generation, TodoCacheKey{UserID: 1, Args: 1}, 1)
+
+				return []any{c.GetValue(TodoCacheKey{UserID: 1, Args: 1})}
+			},
+			want: []any{nil},
+		},
+		{
+			name: "stale values do not crowd out fresh ones",
+			testCase: func(g *Generations, c *LFU) []any {
+				for args := 1; args <= 2; args++ {
+					g.SetValue(1, g.Get(1), TodoCacheKey{UserID: 1, Args: args}, args)
+					for i := 0; i < 5; i++ {
+						c.GetValue(TodoCacheKey{UserID: 1, Args: args})
+					}
+				}
+				g.Increment(1)
+				g.SetValue(1, g.Get(1), TodoCacheKey{UserID: 1, Args: 3}, 3)
+				g.SetValue(1, g.Get(1), TodoCacheKey{UserID: 1, Args: 4}, 4)
+
+				return []any{c.GetValue(TodoCacheKey{UserID: 1, Args: 3}), c.GetValue(TodoCacheKey{UserID: 1, Args: 4})}
+			},
+			want: []any{3, 4},
+		},
+	}
+	for _, tt := range tests {
+		c := NewLFU(cfg, key)
+		got := tt.testCase(NewGenerations(cfg, key, c), c)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
@@ -20,6 +20,7 @@ type LFU struct {
 	capacity    int
 	cleanupSize int
 	hashmap     map[any]*item
+	groups      map[any]map[*item]struct{}
 	last        *item
 }
 
@@ -40,6 +41,7 @@ func NewLFU(cfg ConfigLFU, cfgKey cfgKey) *LFU {
 		capacity:    capacity,
 		cleanupSize: cleanupSize,
 		hashmap:     make(map[any]*item),
+		groups:      make(map[any]map[*item]struct{}),
 		last:        nil,
 	}
 
@@ -53,6 +55,7 @@ func (c *LFU) SetValue(key, val any) {
 	if ok {
 		found.val = val
 		c.updateItem(found)
+		c.mu.Unlock()
 		return
 	}
 
@@ -91,6 +94,16 @@ func (c *LFU) RemoveValue(key any) {
 	c.mu.Unlock()
 }
 
+func (c *LFU) RemoveGroup(group any) {
+	c.mu.Lock()
+
+	for found := range c.groups[group] {
+		c.deleteItem(found)
+	}
+
+	c.mu.Unlock()
+}
+
 type frequency struct {
 	used   uint
 	length uint
@@ -109,6 +122,7 @@ type item struct {
 
 func (c *LFU) addItem(item *item) {
 	c.hashmap[item.key] = item
+	c.groupItem(item)
 
 	if len(c.hashmap) > c.capacity {
 		for i := 0; i < c.cleanupSize; i++ {
@@ -221,6 +235,7 @@ func (c *LFU) updateItem(item *item) {
 
 func (c *LFU) deleteItem(item *item) {
 	delete(c.hashmap, item.key)
+	c.ungroupItem(item)
 	freq := item.freq
 	if item == freq.first {
 		freq.first = item.prev
@@ -239,6 +254,7 @@ func (c *LFU) popLast() {
 	last := c.last
 
 	delete(c.hashmap, last.key)
+	c.ungroupItem(last)
 	lastF := last.freq
 	lastF.length--
 	if lastF.length == 0 {
@@ -249,6 +265,33 @@ func (c *LFU) popLast() {
 	last.delete()
 }
 
+func (c *LFU) groupItem(i *item) {
+	key, ok := i.key.(Grouped)
+	if !ok {
+		return
+	}
+
+	group, ok := c.groups[key.Group()]
+	if !ok {
+		group = make(map[*item]struct{})
+		c.groups[key.Group()] = group
+	}
+	group[i] = struct{}{}
+}
+
+func (c *LFU) ungroupItem(i *item) {
+	key, ok := i.key.(Grouped)
+	if !ok {
+		return
+	}
+
+	group := c.groups[key.Group()]
+	delete(group, i)
+	if len(group) == 0 {
+		delete(c.groups, key.Group())
+	}
+}
+
 func (i *item) append(newI *item) {
 	newI.next = i.next
 	newI.prev = i
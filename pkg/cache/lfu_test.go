@@ -163,6 +163,37 @@ func TestLFU(t *testing.T) {
 				2, 1, nil, nil, 3, 4, 3, 5,
 			},
 		},
+		{
+			cfg: ConfigLFU{
+				Capacities: map[cfgKey]int{
+					key: 3,
+				},
+				CleanupSizes: map[cfgKey]int{
+					key: 1,
+				},
+			},
+			testCase: func(c *LFU) []any {
+				var results []any
+
+				c.SetValue(TodoCacheKey{UserID: 1, Args: 1}, 1)
+				c.SetValue(TodoCacheKey{UserID: 1, Args: 2}, 2)
+				c.SetValue(TodoCacheKey{UserID: 2, Args: 1}, 3)
+				c.SetValue(TodoCacheKey{UserID: 1, Args: 2}, 4)
+				c.RemoveGroup(uint(1))
+				results = append(results, c.GetValue(TodoCacheKey{UserID: 1, Args: 1}))
+				results = append(results, c.GetValue(TodoCacheKey{UserID: 1, Args: 2}))
+				results = append(results, c.GetValue(TodoCacheKey{UserID: 2, Args: 1}))
+				c.SetValue(4, 4)
+				c.SetValue(5, 5)
+				results = append(results, c.GetValue(4))
+				results = append(results, c.GetValue(5))
+
+				return results
+			},
+			want: []any{
+				nil, nil, 3, 4, 5,
+			},
+		},
 	}
 	for _, tt := range tests {
 		c := NewLFU(tt.cfg, key)
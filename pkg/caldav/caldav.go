@@ -0,0 +1,142 @@
+package caldav
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	NamespaceDAV            = "DAV:"
+	NamespaceCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	NamespaceCalendarServer = "http://calendarserver.org/ns/"
+)
+
+const ContentType = "application/xml; charset=utf-8"
+
+var prefixes = map[string]string{
+	NamespaceDAV:            "d",
+	NamespaceCalDAV:         "c",
+	NamespaceCalendarServer: "cs",
+}
+
+func DAVName(local string) xml.Name {
+	return xml.Name{Space: NamespaceDAV, Local: local}
+}
+
+func CalDAVName(local string) xml.Name {
+	return xml.Name{Space: NamespaceCalDAV, Local: local}
+}
+
+// Element is an XML element, such as a property and its value.
+type Element struct {
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Text     string
+	Children []Element
+}
+
+// Href is an element holding the path of a resource.
+func Href(path string) Element {
+	return Element{Name: DAVName("href"), Text: path}
+}
+
+// Response describes a resource of a multistatus. Status, if set, stands for
+// the whole resource.
+type Response struct {
+	Href     string
+	Status   int
+	Props    []Element
+	NotFound []xml.Name
+}
+
+// WriteMultistatus writes the body of a 207 Multi-Status response.
+func WriteMultistatus(w io.Writer, responses []Response) error {
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString(xml.Header)
+	bw.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="` + NamespaceCalDAV +
+		`" xmlns:cs="` + NamespaceCalendarServer + `">`)
+
+	for _, res := range responses {
+		bw.WriteString("<d:response>")
+		writeElement(bw, Href(res.Href))
+
+		if res.Status != 0 {
+			writeStatus(bw, res.Status)
+			bw.WriteString("</d:response>")
+			continue
+		}
+
+		if len(res.Props) != 0 || len(res.NotFound) == 0 {
+			writePropstat(bw, res.Props, http.StatusOK)
+		}
+		if len(res.NotFound) != 0 {
+			props := make([]Element, len(res.NotFound))
+			for i, name := range res.NotFound {
+				props[i] = Element{Name: name}
+			}
+			writePropstat(bw, props, http.StatusNotFound)
+		}
+
+		bw.WriteString("</d:response>")
+	}
+
+	bw.WriteString("</d:multistatus>\n")
+
+	return bw.Flush()
+}
+
+func writePropstat(bw *bufio.Writer, props []Element, status int) {
+	bw.WriteString("<d:propstat><d:prop>")
+	for _, prop := range props {
+		writeElement(bw, prop)
+	}
+	bw.WriteString("</d:prop>")
+	writeStatus(bw, status)
+	bw.WriteString("</d:propstat>")
+}
+
+func writeStatus(bw *bufio.Writer, status int) {
+	fmt.Fprintf(bw, "<d:status>HTTP/1.1 %d %s</d:status>", status, http.StatusText(status))
+}
+
+// writeElement writes the element with the prefix of its namespace. Other
+// namespaces are declared on the element itself.
+func writeElement(bw *bufio.Writer, e Element) {
+	name := e.Name.Local
+	declaration := ""
+	if prefix, ok := prefixes[e.Name.Space]; ok {
+		name = prefix + ":" + name
+	} else if len(e.Name.Space) != 0 {
+		name = "x:" + name
+		declaration = ` xmlns:x="` + escape(e.Name.Space) + `"`
+	}
+
+	bw.WriteString("<" + name + declaration)
+	for _, attr := range e.Attrs {
+		bw.WriteString(" " + attr.Name.Local + `="` + escape(attr.Value) + `"`)
+	}
+
+	if len(e.Text) == 0 && len(e.Children) == 0 {
+		bw.WriteString("/>")
+		return
+	}
+
+	bw.WriteString(">")
+	xml.EscapeText(bw, []byte(e.Text))
+	for _, child := range e.Children {
+		writeElement(bw, child)
+	}
+	bw.WriteString("</" + name + ">")
+}
+
+func escape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+
+	return b.String()
+}
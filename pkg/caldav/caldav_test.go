@@ -0,0 +1,170 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      Request
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok propfind",
+			input: `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/">
+  <d:prop><d:getetag/><cs:getctag/><x:color xmlns:x="urn:example"/></d:prop>
+</d:propfind>`,
+			want: Request{
+				Kind: Propfind,
+				Props: []xml.Name{
+					DAVName("getetag"),
+					{Space: NamespaceCalendarServer, Local: "getctag"},
+					{Space: "urn:example", Local: "color"},
+				},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok empty",
+			input:     "",
+			want:      Request{Kind: Propfind, AllProp: true},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok allprop",
+			input:     `<propfind xmlns="DAV:"><allprop/></propfind>`,
+			want:      Request{Kind: Propfind, AllProp: true},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok calendar-query",
+			input: `<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VTODO">
+    <c:prop-filter name="COMPLETED"><c:is-not-defined/></c:prop-filter>
+  </c:comp-filter></c:comp-filter></c:filter>
+</c:calendar-query>`,
+			want: Request{
+				Kind:      CalendarQuery,
+				Props:     []xml.Name{DAVName("getetag"), CalDAVName("calendar-data")},
+				Component: "VTODO",
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok calendar-multiget",
+			input: `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/></d:prop>
+  <d:href>/dav/todos/a1.ics</d:href>
+  <d:href>/dav/todos/b2.ics</d:href>
+</c:calendar-multiget>`,
+			want: Request{
+				Kind:  CalendarMultiget,
+				Props: []xml.Name{DAVName("getetag")},
+				Hrefs: []string{"/dav/todos/a1.ics", "/dav/todos/b2.ics"},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "unsupported report",
+			input:     `<d:sync-collection xmlns:d="DAV:"/>`,
+			errAssert: assert.Error,
+		},
+		{
+			name: "invalid filter",
+			input: `<c:calendar-query xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <c:filter><c:comp-filter name="VTODO"/></c:filter>
+</c:calendar-query>`,
+			errAssert: assert.Error,
+		},
+		{
+			name:      "malformed",
+			input:     `<d:propfind xmlns:d="DAV:">`,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseRequest(strings.NewReader(tt.input))
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestRequest_Select(t *testing.T) {
+	props := []Element{
+		{Name: DAVName("displayname"), Text: "Todos"},
+		{Name: DAVName("getetag"), Text: `"1"`},
+	}
+
+	found, notFound := Request{AllProp: true}.Select(props)
+	assert.Equal(t, props, found)
+	assert.Empty(t, notFound)
+
+	found, notFound = Request{Props: []xml.Name{DAVName("getetag"), CalDAVName("calendar-data")}}.Select(props)
+	assert.Equal(t, props[1:], found)
+	assert.Equal(t, []xml.Name{CalDAVName("calendar-data")}, notFound)
+}
+
+func TestWriteMultistatus(t *testing.T) {
+	responses := []Response{
+		{
+			Href: "/dav/todos/",
+			Props: []Element{
+				{
+					Name:     DAVName("resourcetype"),
+					Children: []Element{{Name: DAVName("collection")}, {Name: CalDAVName("calendar")}},
+				},
+				{
+					Name: CalDAVName("supported-calendar-component-set"),
+					Children: []Element{{
+						Name:  CalDAVName("comp"),
+						Attrs: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: "VTODO"}},
+					}},
+				},
+				{Name: CalDAVName("calendar-data"), Text: "BEGIN:VCALENDAR\r\n<&>"},
+			},
+			NotFound: []xml.Name{{Space: "urn:example", Local: "color"}},
+		},
+		{
+			Href:   "/dav/todos/missing.ics",
+			Status: http.StatusNotFound,
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMultistatus(&buf, responses))
+
+	want := xml.Header +
+		`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/">` +
+		`<d:response><d:href>/dav/todos/</d:href>` +
+		`<d:propstat><d:prop>` +
+		`<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>` +
+		`<c:supported-calendar-component-set><c:comp name="VTODO"/></c:supported-calendar-component-set>` +
+		`<c:calendar-data>BEGIN:VCALENDAR&#xD;&#xA;&lt;&amp;&gt;</c:calendar-data>` +
+		`</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>` +
+		`<d:propstat><d:prop><x:color xmlns:x="urn:example"/></d:prop>` +
+		`<d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>` +
+		`</d:response>` +
+		`<d:response><d:href>/dav/todos/missing.ics</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>` +
+		`</d:multistatus>` + "\n"
+	assert.Equal(t, want, buf.String())
+
+	var parsed struct {
+		Responses []struct {
+			Href string `xml:"DAV: href"`
+		} `xml:"DAV: response"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &parsed))
+	require.Len(t, parsed.Responses, 2)
+	assert.Equal(t, "/dav/todos/", parsed.Responses[0].Href)
+}
@@ -0,0 +1,118 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+type RequestKind int
+
+const (
+	Propfind RequestKind = iota
+	CalendarQuery
+	CalendarMultiget
+)
+
+// Request is the body of a PROPFIND or REPORT request. Only component
+// filters are kept.
+type Request struct {
+	Kind      RequestKind
+	AllProp   bool
+	Props     []xml.Name
+	Hrefs     []string
+	Component string
+}
+
+type requestXML struct {
+	XMLName xml.Name
+	Prop    *propXML   `xml:"DAV: prop"`
+	Hrefs   []string   `xml:"DAV: href"`
+	Filter  *filterXML `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+type propXML struct {
+	Names []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+type filterXML struct {
+	CompFilter compFilterXML `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type compFilterXML struct {
+	Name        string          `xml:"name,attr"`
+	CompFilters []compFilterXML `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// ParseRequest asks for every property without a prop element.
+func ParseRequest(r io.Reader) (Request, error) {
+	var body requestXML
+	switch err := xml.NewDecoder(r).Decode(&body); err {
+	case nil:
+	case io.EOF:
+		return Request{Kind: Propfind, AllProp: true}, nil
+	default:
+		return Request{}, err
+	}
+
+	var req Request
+	switch body.XMLName {
+	case DAVName("propfind"):
+		req.Kind = Propfind
+	case CalDAVName("calendar-query"):
+		req.Kind = CalendarQuery
+	case CalDAVName("calendar-multiget"):
+		req.Kind = CalendarMultiget
+		req.Hrefs = body.Hrefs
+	default:
+		return Request{}, errors.New("unsupported request")
+	}
+
+	if body.Prop != nil {
+		for _, name := range body.Prop.Names {
+			req.Props = append(req.Props, name.XMLName)
+		}
+	} else {
+		req.AllProp = true
+	}
+
+	if body.Filter != nil {
+		if body.Filter.CompFilter.Name != "VCALENDAR" {
+			return Request{}, errors.New("filter must start with VCALENDAR")
+		}
+		if filters := body.Filter.CompFilter.CompFilters; len(filters) != 0 {
+			req.Component = filters[0].Name
+		}
+	}
+
+	return req, nil
+}
+
+func (req Request) Select(props []Element) ([]Element, []xml.Name) {
+	if req.AllProp {
+		return props, nil
+	}
+
+	var (
+		found    []Element
+		notFound []xml.Name
+	)
+
+	for _, name := range req.Props {
+		ok := false
+		for _, prop := range props {
+			if prop.Name == name {
+				found = append(found, prop)
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			notFound = append(notFound, name)
+		}
+	}
+
+	return found, notFound
+}
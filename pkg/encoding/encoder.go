@@ -3,4 +3,6 @@ package encoding
 type Encoder interface {
 	EncodeID(id uint) (any, error)
 	DecodeID(encoded any) (uint, error)
+	EncodeIDs(ids ...uint) (any, error)
+	DecodeIDs(encoded any) ([]uint, error)
 }
@@ -9,8 +9,12 @@ import (
 type cfgKey string
 
 const (
-	UserKey cfgKey = "user"
-	TodoKey cfgKey = "todo"
+	UserKey    cfgKey = "user"
+	TodoKey    cfgKey = "todo"
+	ItemKey    cfgKey = "item"
+	TagKey     cfgKey = "tag"
+	ProjectKey cfgKey = "project"
+	EventKey   cfgKey = "event"
 )
 
 type ConfigHashids struct {
@@ -38,20 +42,42 @@ func (e *Hashids) EncodeID(id uint) (any, error) {
 }
 
 func (e *Hashids) DecodeID(encoded any) (uint, error) {
+	ids, err := e.DecodeIDs(encoded)
+	if err != nil {
+		return 0, err
+	}
+
+	return ids[0], nil
+}
+
+func (e *Hashids) EncodeIDs(ids ...uint) (any, error) {
+	values := make([]int64, len(ids))
+	for i, id := range ids {
+		values[i] = int64(id)
+	}
+
+	return e.hashID.EncodeInt64(values)
+}
+
+func (e *Hashids) DecodeIDs(encoded any) ([]uint, error) {
 	hash, ok := encoded.(string)
 	if !ok {
-		return 0, errors.New("given value could not be converted to string")
+		return nil, errors.New("given value could not be converted to string")
 	}
 
-	ids, err := e.hashID.DecodeInt64WithError(hash)
+	values, err := e.hashID.DecodeInt64WithError(hash)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return nil, errors.New("invalid hash")
 	}
 
-	if len(ids) == 0 {
-		return 0, errors.New("invalid hash")
+	ids := make([]uint, len(values))
+	for i, value := range values {
+		ids[i] = uint(value)
 	}
-	id := uint(ids[0])
 
-	return id, nil
+	return ids, nil
 }
@@ -115,3 +115,34 @@ func TestHashids_Decode(t *testing.T) {
 		tt.errAssert(t, err)
 	}
 }
+
+func TestHashids_EncodeIDs(t *testing.T) {
+	enc, err := NewHashids(ConfigHashids{}, TodoKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		input []uint
+	}{
+		{
+			name:  "single",
+			input: []uint{1},
+		},
+		{
+			name:  "several",
+			input: []uint{1, 0, 1700000000000000},
+		},
+	}
+	for _, tt := range tests {
+		hash, err := enc.EncodeIDs(tt.input...)
+		require.NoError(t, err, tt.name)
+
+		got, err := enc.DecodeIDs(hash)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.input, got, tt.name)
+
+		id, err := enc.DecodeID(hash)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.input[0], id, tt.name)
+	}
+}
@@ -0,0 +1,294 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	CSV      Format = "csv"
+	JSON     Format = "json"
+	Markdown Format = "markdown"
+	TodoTxt  Format = "todotxt"
+)
+
+const dateLayout = "2006-01-02"
+
+// ParseFormat accepts the name of a format. An empty name stands for JSON.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case "":
+		return JSON, nil
+	case CSV, JSON, Markdown, TodoTxt:
+		return format, nil
+	default:
+		return "", errors.New("unknown export format")
+	}
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case JSON:
+		return "application/json; charset=utf-8"
+	case Markdown:
+		return "text/markdown; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+func (f Format) Extension() string {
+	switch f {
+	case Markdown:
+		return "md"
+	case TodoTxt:
+		return "txt"
+	default:
+		return string(f)
+	}
+}
+
+type Todo struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Completed   bool       `json:"completed"`
+	Priority    string     `json:"priority"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	Recurrence  string     `json:"recurrence,omitempty"`
+	ProjectID   string     `json:"projectId,omitempty"`
+	Tags        []string   `json:"tags"`
+}
+
+// Writer serializes todos one at a time. Close does not close the
+// underlying writer.
+type Writer interface {
+	Write(todo Todo) error
+	Close() error
+}
+
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case JSON:
+		return &jsonWriter{w: bufio.NewWriter(w)}, nil
+	case Markdown:
+		return &markdownWriter{w: bufio.NewWriter(w)}, nil
+	case TodoTxt:
+		return &todoTxtWriter{w: bufio.NewWriter(w)}, nil
+	default:
+		return nil, errors.New("unknown export format")
+	}
+}
+
+var csvHeader = []string{
+	"id", "title", "description", "completed", "priority", "due_at", "recurrence", "project_id", "tags",
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (cw *csvWriter) Write(todo Todo) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	var dueAt string
+	if todo.DueAt != nil {
+		dueAt = todo.DueAt.UTC().Format(time.RFC3339)
+	}
+
+	return cw.w.Write([]string{
+		todo.ID, todo.Title, todo.Description, strconv.FormatBool(todo.Completed), todo.Priority, dueAt,
+		todo.Recurrence, todo.ProjectID, strings.Join(todo.Tags, ";"),
+	})
+}
+
+func (cw *csvWriter) Close() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	cw.w.Flush()
+
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) writeHeader() error {
+	if cw.headerWritten {
+		return nil
+	}
+
+	cw.headerWritten = true
+
+	return cw.w.Write(csvHeader)
+}
+
+// jsonWriter writes an array of todos.
+type jsonWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func (jw *jsonWriter) Write(todo Todo) error {
+	if todo.Tags == nil {
+		todo.Tags = []string{}
+	}
+
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n"
+	if jw.count == 0 {
+		separator = "[\n"
+	}
+	jw.count++
+
+	if _, err := jw.w.WriteString(separator); err != nil {
+		return err
+	}
+	_, err = jw.w.Write(data)
+
+	return err
+}
+
+func (jw *jsonWriter) Close() error {
+	closing := "\n]\n"
+	if jw.count == 0 {
+		closing = "[]\n"
+	}
+
+	if _, err := jw.w.WriteString(closing); err != nil {
+		return err
+	}
+
+	return jw.w.Flush()
+}
+
+type markdownWriter struct {
+	w *bufio.Writer
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "#", `\#`, "<", `\<`,
+)
+
+func (mw *markdownWriter) Write(todo Todo) error {
+	var b strings.Builder
+
+	b.WriteString("- [")
+	if todo.Completed {
+		b.WriteString("x")
+	} else {
+		b.WriteString(" ")
+	}
+	b.WriteString("] ")
+	b.WriteString(markdownEscaper.Replace(singleLine(todo.Title)))
+
+	var details []string
+	if len(todo.Priority) != 0 && todo.Priority != "none" {
+		details = append(details, "priority: "+todo.Priority)
+	}
+	if todo.DueAt != nil {
+		details = append(details, "due: "+todo.DueAt.UTC().Format(dateLayout))
+	}
+	if len(todo.Recurrence) != 0 {
+		details = append(details, "repeats: `"+todo.Recurrence+"`")
+	}
+	if len(details) != 0 {
+		b.WriteString(" (" + strings.Join(details, ", ") + ")")
+	}
+
+	for _, tag := range todo.Tags {
+		b.WriteString(" `#" + tag + "`")
+	}
+	b.WriteString("\n")
+
+	if len(todo.Description) != 0 {
+		for _, line := range strings.Split(strings.TrimRight(todo.Description, "\n"), "\n") {
+			b.WriteString("  " + markdownEscaper.Replace(line) + "\n")
+		}
+	}
+
+	_, err := mw.w.WriteString(b.String())
+
+	return err
+}
+
+func (mw *markdownWriter) Close() error {
+	return mw.w.Flush()
+}
+
+// todoTxtWriter writes a line per todo in the todo.txt format. Tags become
+// projects, and the other fields are kept as key:value pairs.
+type todoTxtWriter struct {
+	w *bufio.Writer
+}
+
+var todoTxtPriorities = map[string]string{
+	"urgent": "A",
+	"high":   "B",
+	"medium": "C",
+	"low":    "D",
+}
+
+func (tw *todoTxtWriter) Write(todo Todo) error {
+	var fields []string
+
+	// Completed todos keep their priority as a pri:A pair, the way todo.txt
+	// clients do when they complete a task.
+	priority, hasPriority := todoTxtPriorities[todo.Priority]
+	switch {
+	case todo.Completed:
+		fields = append(fields, "x")
+	case hasPriority:
+		fields = append(fields, "("+priority+")")
+	}
+
+	fields = append(fields, singleLine(todo.Title))
+
+	if todo.Completed && hasPriority {
+		fields = append(fields, "pri:"+priority)
+	}
+
+	for _, tag := range todo.Tags {
+		fields = append(fields, "+"+strings.Join(strings.Fields(tag), "_"))
+	}
+	if todo.DueAt != nil {
+		fields = append(fields, "due:"+todo.DueAt.UTC().Format(dateLayout))
+	}
+	if len(todo.Recurrence) != 0 {
+		fields = append(fields, "rrule:"+todo.Recurrence)
+	}
+	if len(todo.ProjectID) != 0 {
+		fields = append(fields, "project:"+todo.ProjectID)
+	}
+	fields = append(fields, "id:"+todo.ID)
+
+	_, err := tw.w.WriteString(strings.Join(fields, " ") + "\n")
+
+	return err
+}
+
+func (tw *todoTxtWriter) Close() error {
+	return tw.w.Flush()
+}
+
+func singleLine(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
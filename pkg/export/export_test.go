@@ -0,0 +1,131 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      Format
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "ok",
+			input:     "todotxt",
+			want:      TodoTxt,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok default",
+			input:     "",
+			want:      JSON,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "unknown",
+			input:     "xml",
+			want:      "",
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.input)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	dueAt := time.Date(2022, time.March, 1, 9, 30, 0, 0, time.UTC)
+	todos := []Todo{
+		{
+			ID:          "a1",
+			Title:       "Buy *milk*",
+			Description: "two\nbottles",
+			Priority:    "high",
+			DueAt:       &dueAt,
+			Recurrence:  "FREQ=WEEKLY",
+			ProjectID:   "p1",
+			Tags:        []string{"home", "errands"},
+		},
+		{
+			ID:        "b2",
+			Title:     "Call, mom",
+			Completed: true,
+			Priority:  "urgent",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		format Format
+		todos  []Todo
+		want   string
+	}{
+		{
+			name:   "csv",
+			format: CSV,
+			todos:  todos,
+			want: "id,title,description,completed,priority,due_at,recurrence,project_id,tags\n" +
+				"a1,Buy *milk*,\"two\nbottles\",false,high,2022-03-01T09:30:00Z,FREQ=WEEKLY,p1,home;errands\n" +
+				"b2,\"Call, mom\",,true,urgent,,,,\n",
+		},
+		{
+			name:   "csv empty",
+			format: CSV,
+			want:   "id,title,description,completed,priority,due_at,recurrence,project_id,tags\n",
+		},
+		{
+			name:   "json",
+			format: JSON,
+			todos:  todos,
+			want: "[\n" +
+				`{"id":"a1","title":"Buy *milk*","description":"two\nbottles","completed":false,` +
+				`"priority":"high","dueAt":"2022-03-01T09:30:00Z","recurrence":"FREQ=WEEKLY",` +
+				`"projectId":"p1","tags":["home","errands"]},` + "\n" +
+				`{"id":"b2","title":"Call, mom","completed":true,"priority":"urgent","tags":[]}` +
+				"\n]\n",
+		},
+		{
+			name:   "json empty",
+			format: JSON,
+			want:   "[]\n",
+		},
+		{
+			name:   "markdown",
+			format: Markdown,
+			todos:  todos,
+			want: "- [ ] Buy \\*milk\\* (priority: high, due: 2022-03-01, repeats: `FREQ=WEEKLY`) `#home` `#errands`\n" +
+				"  two\n" +
+				"  bottles\n" +
+				"- [x] Call, mom (priority: urgent)\n",
+		},
+		{
+			name:   "todo.txt",
+			format: TodoTxt,
+			todos:  todos,
+			want: "(B) Buy *milk* +home +errands due:2022-03-01 rrule:FREQ=WEEKLY project:p1 id:a1\n" +
+				"x Call, mom pri:A id:b2\n",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+
+		w, err := NewWriter(&buf, tt.format)
+		require.NoError(t, err, tt.name)
+
+		for _, todo := range tt.todos {
+			require.NoError(t, w.Write(todo), tt.name)
+		}
+		require.NoError(t, w.Close(), tt.name)
+
+		assert.Equal(t, tt.want, buf.String(), tt.name)
+	}
+}
@@ -0,0 +1,364 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RecordError struct {
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err.Error())
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Reader parses todos one at a time and returns io.EOF once the input ends.
+// Line is the line the last record read starts on.
+type Reader interface {
+	Read() (Todo, error)
+	Line() int
+}
+
+// NewReader returns a reader of the formats todos can be imported from, that
+// is every format but Markdown.
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case CSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.ReuseRecord = true
+		return &csvReader{r: cr}, nil
+	case JSON:
+		lc := &lineCounter{r: r}
+		return &jsonReader{d: json.NewDecoder(lc), lc: lc}, nil
+	case TodoTxt:
+		s := bufio.NewScanner(r)
+		s.Buffer(nil, maxLineLength)
+		return &todoTxtReader{s: s}, nil
+	default:
+		return nil, errors.New("format cannot be imported")
+	}
+}
+
+const maxLineLength = 1 << 20
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+	line    int
+}
+
+func (cr *csvReader) Read() (Todo, error) {
+	if cr.columns == nil {
+		if err := cr.readHeader(); err != nil {
+			return Todo{}, err
+		}
+	}
+
+	record, err := cr.r.Read()
+	if err == io.EOF {
+		return Todo{}, err
+	}
+	cr.line, _ = cr.r.FieldPos(0)
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			cr.line = parseErr.StartLine
+			return Todo{}, &RecordError{Line: cr.line, Err: parseErr.Err}
+		}
+		return Todo{}, err
+	}
+
+	field := func(name string) string {
+		if i, ok := cr.columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	todo := Todo{
+		ID:          field("id"),
+		Title:       field("title"),
+		Description: field("description"),
+		Priority:    field("priority"),
+		Recurrence:  field("recurrence"),
+		ProjectID:   field("project_id"),
+	}
+
+	if completed := field("completed"); len(completed) != 0 {
+		if todo.Completed, err = strconv.ParseBool(completed); err != nil {
+			return Todo{}, &RecordError{Line: cr.line, Err: errors.New("invalid completed")}
+		}
+	}
+
+	if dueAt := field("due_at"); len(dueAt) != 0 {
+		t, err := parseTime(dueAt)
+		if err != nil {
+			return Todo{}, &RecordError{Line: cr.line, Err: errors.New("invalid due_at")}
+		}
+		todo.DueAt = &t
+	}
+
+	for _, tag := range strings.Split(field("tags"), ";") {
+		if tag = strings.TrimSpace(tag); len(tag) != 0 {
+			todo.Tags = append(todo.Tags, tag)
+		}
+	}
+
+	return todo, nil
+}
+
+func (cr *csvReader) Line() int {
+	return cr.line
+}
+
+// readHeader maps the column names of the first record, written the same way
+// the CSV writer does, to their positions.
+func (cr *csvReader) readHeader() error {
+	header, err := cr.r.Read()
+	if err == io.EOF {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("could not read header: %s", err.Error())
+	}
+
+	cr.columns = make(map[string]int, len(header))
+	for i, name := range header {
+		cr.columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := cr.columns["title"]; !ok {
+		return errors.New("missing title column")
+	}
+
+	return nil
+}
+
+// jsonReader reads the elements of an array, as written by the JSON writer.
+type jsonReader struct {
+	d       *json.Decoder
+	lc      *lineCounter
+	started bool
+	line    int
+}
+
+func (jr *jsonReader) Read() (Todo, error) {
+	if !jr.started {
+		jr.started = true
+		if token, err := jr.d.Token(); err != nil || token != json.Delim('[') {
+			return Todo{}, errors.New("expected an array of todos")
+		}
+	}
+
+	if !jr.d.More() {
+		if _, err := jr.d.Token(); err != nil {
+			return Todo{}, err
+		}
+		if _, err := jr.d.Token(); err != io.EOF {
+			return Todo{}, errors.New("unexpected data after the array of todos")
+		}
+		return Todo{}, io.EOF
+	}
+
+	jr.line = jr.lc.line(jr.d.InputOffset() + jr.skippedBytes())
+
+	// Syntax errors leave the rest of the input unreadable, while an element
+	// that does not fit a todo can be skipped.
+	var element json.RawMessage
+	if err := jr.d.Decode(&element); err != nil {
+		return Todo{}, err
+	}
+
+	var todo Todo
+	if err := json.Unmarshal(element, &todo); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			err = fmt.Errorf("invalid %s", typeErr.Field)
+		}
+		return Todo{}, &RecordError{Line: jr.line, Err: err}
+	}
+
+	return todo, nil
+}
+
+func (jr *jsonReader) Line() int {
+	return jr.line
+}
+
+// skippedBytes counts the separators the decoder has buffered ahead of the
+// next element.
+func (jr *jsonReader) skippedBytes() int64 {
+	buffered := bufio.NewReader(jr.d.Buffered())
+
+	var n int64
+	for {
+		b, err := buffered.ReadByte()
+		if err != nil {
+			return n
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n', ',':
+			n++
+		default:
+			return n
+		}
+	}
+}
+
+// lineCounter remembers where the lines of the input end, so that offsets can
+// be told apart by line.
+type lineCounter struct {
+	r        io.Reader
+	offset   int64
+	newlines []int64
+}
+
+func (lc *lineCounter) Read(p []byte) (int, error) {
+	n, err := lc.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			lc.newlines = append(lc.newlines, lc.offset+int64(i))
+		}
+	}
+	lc.offset += int64(n)
+
+	return n, err
+}
+
+func (lc *lineCounter) line(offset int64) int {
+	return 1 + sort.Search(len(lc.newlines), func(i int) bool {
+		return lc.newlines[i] >= offset
+	})
+}
+
+// todoTxtReader reads the todo.txt format. Projects and contexts both become
+// tags, and the pairs written by the todo.txt writer are read back.
+type todoTxtReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+var todoTxtPriorityNames = map[string]string{
+	"A": "urgent",
+	"B": "high",
+	"C": "medium",
+	"D": "low",
+}
+
+func (tr *todoTxtReader) Read() (Todo, error) {
+	var words []string
+	for len(words) == 0 {
+		if !tr.s.Scan() {
+			if err := tr.s.Err(); err != nil {
+				return Todo{}, err
+			}
+			return Todo{}, io.EOF
+		}
+		tr.line++
+		words = strings.Fields(tr.s.Text())
+	}
+
+	var todo Todo
+
+	if words[0] == "x" {
+		todo.Completed = true
+		words = words[1:]
+	} else if priority, ok := parseTodoTxtPriority(words[0]); ok {
+		todo.Priority = priority
+		words = words[1:]
+	}
+
+	// Completion and creation dates are not kept.
+	for len(words) != 0 && isDate(words[0]) {
+		words = words[1:]
+	}
+
+	var title []string
+	for _, word := range words {
+		if len(word) > 1 && (word[0] == '+' || word[0] == '@') {
+			todo.Tags = append(todo.Tags, word[1:])
+			continue
+		}
+
+		key, value, ok := strings.Cut(word, ":")
+		if !ok || len(value) == 0 {
+			title = append(title, word)
+			continue
+		}
+
+		switch key {
+		case "due":
+			t, err := parseTime(value)
+			if err != nil {
+				return Todo{}, &RecordError{Line: tr.line, Err: errors.New("invalid due date")}
+			}
+			todo.DueAt = &t
+		case "pri":
+			priority, ok := parseTodoTxtPriority("(" + value + ")")
+			if !ok {
+				return Todo{}, &RecordError{Line: tr.line, Err: errors.New("invalid priority")}
+			}
+			todo.Priority = priority
+		case "rrule":
+			todo.Recurrence = value
+		case "project":
+			todo.ProjectID = value
+		case "id":
+			todo.ID = value
+		default:
+			title = append(title, word)
+		}
+	}
+
+	todo.Title = strings.Join(title, " ")
+
+	return todo, nil
+}
+
+func (tr *todoTxtReader) Line() int {
+	return tr.line
+}
+
+// parseTodoTxtPriority accepts priorities from (A) to (Z). Those past (D) are
+// all low.
+func parseTodoTxtPriority(word string) (string, bool) {
+	if len(word) != 3 || word[0] != '(' || word[2] != ')' || word[1] < 'A' || word[1] > 'Z' {
+		return "", false
+	}
+
+	if name, ok := todoTxtPriorityNames[word[1:2]]; ok {
+		return name, true
+	}
+
+	return "low", true
+}
+
+func isDate(value string) bool {
+	_, err := time.Parse(dateLayout, value)
+	return err == nil
+}
+
+// parseTime accepts RFC 3339 timestamps and dates, which are taken as UTC
+// midnight.
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(dateLayout, value)
+}
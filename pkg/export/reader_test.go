@@ -0,0 +1,198 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type readResult struct {
+	Todo Todo
+	Line int
+	Err  string
+}
+
+func readAll(r Reader) []readResult {
+	var results []readResult
+	for {
+		todo, err := r.Read()
+		if err == io.EOF {
+			return results
+		}
+
+		var recordErr *RecordError
+		switch {
+		case errors.As(err, &recordErr):
+			results = append(results, readResult{Line: recordErr.Line, Err: recordErr.Err.Error()})
+		case err != nil:
+			results = append(results, readResult{Line: r.Line(), Err: err.Error()})
+			return results
+		default:
+			results = append(results, readResult{Todo: todo, Line: r.Line()})
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	dueAt := time.Date(2022, time.March, 1, 9, 30, 0, 0, time.UTC)
+	dueDate := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		want   []readResult
+	}{
+		{
+			name:   "csv",
+			format: CSV,
+			input: "Title,completed,due_at,tags,extra\n" +
+				"\"Buy\nmilk\",true,2022-03-01T09:30:00Z,home; errands,x\n" +
+				"Call mom\n" +
+				"Walk,maybe,,,\n" +
+				"Run,,someday,,\n",
+			want: []readResult{
+				{Todo: Todo{Title: "Buy\nmilk", Completed: true, DueAt: &dueAt, Tags: []string{"home", "errands"}}, Line: 2},
+				{Todo: Todo{Title: "Call mom"}, Line: 4},
+				{Line: 5, Err: "invalid completed"},
+				{Line: 6, Err: "invalid due_at"},
+			},
+		},
+		{
+			name:   "csv missing title column",
+			format: CSV,
+			input:  "id,description\n",
+			want:   []readResult{{Err: "missing title column"}},
+		},
+		{
+			name:   "json",
+			format: JSON,
+			input: "[\n" +
+				`  {"id": "a1", "title": "Buy milk", "dueAt": "2022-03-01T09:30:00Z", "tags": ["home"]},` + "\n" +
+				"  {\n" +
+				`    "title": "Call mom", "completed": "yes"` + "\n" +
+				"  },\n" +
+				`  {"title": "Walk", "priority": "low"}` + "\n" +
+				"]\n",
+			want: []readResult{
+				{Todo: Todo{ID: "a1", Title: "Buy milk", DueAt: &dueAt, Tags: []string{"home"}}, Line: 2},
+				{Line: 3, Err: "invalid completed"},
+				{Todo: Todo{Title: "Walk", Priority: "low"}, Line: 6},
+			},
+		},
+		{
+			name:   "json empty",
+			format: JSON,
+			input:  "[]",
+			want:   nil,
+		},
+		{
+			name:   "json syntax error",
+			format: JSON,
+			input:  "[\n" + `{"title": "Walk"},` + "\n" + `{"title": }` + "\n]",
+			want: []readResult{
+				{Todo: Todo{Title: "Walk"}, Line: 2},
+				{Line: 3, Err: "invalid character '}' after array element"},
+			},
+		},
+		{
+			name:   "json not an array",
+			format: JSON,
+			input:  `{"title": "Walk"}`,
+			want:   []readResult{{Err: "expected an array of todos"}},
+		},
+		{
+			name:   "todo.txt",
+			format: TodoTxt,
+			input: "(B) 2022-02-01 Buy milk +home @store due:2022-03-01 rrule:FREQ=WEEKLY id:a1 see:notes\n" +
+				"\n" +
+				"x 2022-02-03 2022-02-01 Call mom pri:A project:p1\n" +
+				"(F) Walk due:someday\n",
+			want: []readResult{
+				{
+					Todo: Todo{
+						ID:         "a1",
+						Title:      "Buy milk see:notes",
+						Priority:   "high",
+						DueAt:      &dueDate,
+						Recurrence: "FREQ=WEEKLY",
+						Tags:       []string{"home", "store"},
+					},
+					Line: 1,
+				},
+				{Todo: Todo{Title: "Call mom", Completed: true, Priority: "urgent", ProjectID: "p1"}, Line: 3},
+				{Line: 4, Err: "invalid due date"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		r, err := NewReader(strings.NewReader(tt.input), tt.format)
+		require.NoError(t, err, tt.name)
+
+		assert.Equal(t, tt.want, readAll(r), tt.name)
+	}
+}
+
+func TestReader_RoundTrip(t *testing.T) {
+	dueAt := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+	todos := []Todo{
+		{
+			ID:          "a1",
+			Title:       "Buy milk",
+			Description: "two bottles",
+			Priority:    "high",
+			DueAt:       &dueAt,
+			Recurrence:  "FREQ=WEEKLY",
+			ProjectID:   "p1",
+			Tags:        []string{"home", "errands"},
+		},
+		{
+			ID:        "b2",
+			Title:     "Call mom",
+			Completed: true,
+			Priority:  "urgent",
+			Tags:      []string{},
+		},
+	}
+
+	for _, format := range []Format{CSV, JSON, TodoTxt} {
+		var buf bytes.Buffer
+
+		w, err := NewWriter(&buf, format)
+		require.NoError(t, err, format)
+		for _, todo := range todos {
+			require.NoError(t, w.Write(todo), format)
+		}
+		require.NoError(t, w.Close(), format)
+
+		r, err := NewReader(&buf, format)
+		require.NoError(t, err, format)
+
+		for _, want := range todos {
+			got, err := r.Read()
+			require.NoError(t, err, format)
+
+			if format == TodoTxt {
+				want.Description = ""
+			}
+			if len(want.Tags) == 0 && format != JSON {
+				want.Tags = nil
+			}
+			assert.Equal(t, want, got, format)
+		}
+
+		_, err = r.Read()
+		assert.Equal(t, io.EOF, err, format)
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	_, err := NewReader(strings.NewReader(""), Markdown)
+	assert.Error(t, err)
+}
@@ -0,0 +1,162 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	ContentType = "text/calendar; charset=utf-8"
+	ProductID   = "-//grimerssy//todo-service//EN"
+)
+
+const (
+	dateTimeLayout = "20060102T150405Z"
+	maxLineLength  = 75
+)
+
+// Todo is a VTODO component as described in RFC 5545. A zero Priority is left
+// undefined, and so are zero times and an empty RRule.
+type Todo struct {
+	UID          string
+	Summary      string
+	Description  string
+	Categories   []string
+	Priority     int
+	Due          time.Time
+	RRule        string
+	Completed    bool
+	CompletedAt  time.Time
+	Created      time.Time
+	LastModified time.Time
+	Stamp        time.Time
+}
+
+// Writer writes a calendar one todo at a time. Close ends the calendar and
+// flushes it, but does not close the underlying writer.
+type Writer struct {
+	w       *bufio.Writer
+	name    string
+	started bool
+}
+
+func NewWriter(w io.Writer, name string) *Writer {
+	return &Writer{
+		w:    bufio.NewWriter(w),
+		name: name,
+	}
+}
+
+func (cw *Writer) Write(todo Todo) error {
+	cw.start()
+
+	cw.line("BEGIN", "VTODO")
+	cw.line("UID", todo.UID)
+	cw.time("DTSTAMP", todo.Stamp)
+	cw.time("CREATED", todo.Created)
+	cw.time("LAST-MODIFIED", todo.LastModified)
+	cw.line("SUMMARY", escapeText(todo.Summary))
+	if len(todo.Description) != 0 {
+		cw.line("DESCRIPTION", escapeText(todo.Description))
+	}
+	if len(todo.Categories) != 0 {
+		categories := make([]string, len(todo.Categories))
+		for i, category := range todo.Categories {
+			categories[i] = escapeText(category)
+		}
+		cw.line("CATEGORIES", strings.Join(categories, ","))
+	}
+	if todo.Priority != 0 {
+		cw.line("PRIORITY", strconv.Itoa(todo.Priority))
+	}
+	// Recurrence is anchored at DTSTART, which VTODO does not require
+	// otherwise.
+	if len(todo.RRule) != 0 {
+		cw.time("DTSTART", todo.Due)
+		cw.line("RRULE", todo.RRule)
+	}
+	cw.time("DUE", todo.Due)
+	if todo.Completed {
+		cw.line("STATUS", "COMPLETED")
+		cw.time("COMPLETED", todo.CompletedAt)
+	} else {
+		cw.line("STATUS", "NEEDS-ACTION")
+	}
+	cw.line("END", "VTODO")
+
+	return cw.err()
+}
+
+func (cw *Writer) Close() error {
+	cw.start()
+	cw.line("END", "VCALENDAR")
+
+	if err := cw.err(); err != nil {
+		return err
+	}
+
+	return cw.w.Flush()
+}
+
+func (cw *Writer) start() {
+	if cw.started {
+		return
+	}
+
+	cw.started = true
+
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", ProductID)
+	cw.line("CALSCALE", "GREGORIAN")
+	cw.line("METHOD", "PUBLISH")
+	if len(cw.name) != 0 {
+		cw.line("X-WR-CALNAME", escapeText(cw.name))
+	}
+}
+
+func (cw *Writer) time(name string, t time.Time) {
+	if !t.IsZero() {
+		cw.line(name, t.UTC().Format(dateTimeLayout))
+	}
+}
+
+// line writes a content line, folded so that no line is longer than 75
+// octets. Folds never split a UTF-8 sequence.
+func (cw *Writer) line(name, value string) {
+	line := name + ":" + value
+
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		cw.w.WriteString(line[:cut])
+		cw.w.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineLength - 1
+	}
+
+	cw.w.WriteString(line)
+	cw.w.WriteString("\r\n")
+}
+
+// err reports the first error of the buffered writer, which keeps it and
+// ignores every write after it.
+func (cw *Writer) err() error {
+	_, err := cw.w.Write(nil)
+	return err
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`,
+)
+
+func escapeText(value string) string {
+	return textEscaper.Replace(value)
+}
@@ -0,0 +1,292 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	created := time.Date(2022, time.February, 20, 8, 0, 0, 0, time.UTC)
+	updated := time.Date(2022, time.February, 21, 10, 15, 0, 0, time.FixedZone("", 2*60*60))
+	due := time.Date(2022, time.March, 1, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input []Todo
+		want  []string
+	}{
+		{
+			name: "ok",
+			input: []Todo{
+				{
+					UID:          "a1@todo-service",
+					Summary:      "Buy milk; eggs, bread",
+					Description:  "two\nbottles \\ fresh",
+					Categories:   []string{"home", "a,b"},
+					Priority:     3,
+					Due:          due,
+					RRule:        "FREQ=WEEKLY",
+					Created:      created,
+					LastModified: updated,
+					Stamp:        updated,
+				},
+				{
+					UID:          "b2@todo-service",
+					Summary:      "Call mom",
+					Due:          due,
+					Completed:    true,
+					CompletedAt:  updated,
+					Created:      created,
+					LastModified: updated,
+					Stamp:        updated,
+				},
+			},
+			want: []string{
+				"BEGIN:VCALENDAR",
+				"VERSION:2.0",
+				"PRODID:" + ProductID,
+				"CALSCALE:GREGORIAN",
+				"METHOD:PUBLISH",
+				"X-WR-CALNAME:Todos",
+				"BEGIN:VTODO",
+				"UID:a1@todo-service",
+				"DTSTAMP:20220221T081500Z",
+				"CREATED:20220220T080000Z",
+				"LAST-MODIFIED:20220221T081500Z",
+				`SUMMARY:Buy milk\; eggs\, bread`,
+				`DESCRIPTION:two\nbottles \\ fresh`,
+				`CATEGORIES:home,a\,b`,
+				"PRIORITY:3",
+				"DTSTART:20220301T093000Z",
+				"RRULE:FREQ=WEEKLY",
+				"DUE:20220301T093000Z",
+				"STATUS:NEEDS-ACTION",
+				"END:VTODO",
+				"BEGIN:VTODO",
+				"UID:b2@todo-service",
+				"DTSTAMP:20220221T081500Z",
+				"CREATED:20220220T080000Z",
+				"LAST-MODIFIED:20220221T081500Z",
+				"SUMMARY:Call mom",
+				"DUE:20220301T093000Z",
+				"STATUS:COMPLETED",
+				"COMPLETED:20220221T081500Z",
+				"END:VTODO",
+				"END:VCALENDAR",
+			},
+		},
+		{
+			name:  "ok empty",
+			input: nil,
+			want: []string{
+				"BEGIN:VCALENDAR",
+				"VERSION:2.0",
+				"PRODID:" + ProductID,
+				"CALSCALE:GREGORIAN",
+				"METHOD:PUBLISH",
+				"X-WR-CALNAME:Todos",
+				"END:VCALENDAR",
+			},
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, "Todos")
+		for _, todo := range tt.input {
+			require.NoError(t, w.Write(todo), tt.name)
+		}
+		require.NoError(t, w.Close(), tt.name)
+		assert.Equal(t, strings.Join(tt.want, "\r\n")+"\r\n", buf.String(), tt.name)
+	}
+}
+
+func TestWriter_folding(t *testing.T) {
+	summary := strings.Repeat("ab", 40) + strings.Repeat("ж", 40)
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, "")
+	require.NoError(t, w.Write(Todo{UID: "a1", Summary: summary}))
+	require.NoError(t, w.Close())
+
+	var unfolded strings.Builder
+	for i, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), maxLineLength, "line %d", i)
+		assert.True(t, utf8.ValidString(line), "line %d", i)
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+			continue
+		}
+		unfolded.WriteString("\n" + line)
+	}
+
+	assert.Contains(t, unfolded.String(), "\nSUMMARY:"+summary+"\n")
+}
+
+func TestParse(t *testing.T) {
+	due := time.Date(2022, time.March, 1, 9, 30, 0, 0, time.UTC)
+	kyiv, err := time.LoadLocation("Europe/Kiev")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		input     []string
+		want      Todo
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name: "ok",
+			input: []string{
+				"BEGIN:VCALENDAR",
+				"VERSION:2.0",
+				"PRODID:-//Example//EN",
+				"BEGIN:VTIMEZONE",
+				"TZID:Europe/Kiev",
+				"END:VTIMEZONE",
+				"BEGIN:VTODO",
+				"UID:1234-5678",
+				"DTSTAMP:20220221T081500Z",
+				`SUMMARY:Buy milk\; eggs\, br`,
+				" ead",
+				`DESCRIPTION:two\nbottles`,
+				`CATEGORIES:home,a\,b`,
+				"CATEGORIES:errands",
+				"PRIORITY:1",
+				`DUE;TZID="Europe/Kiev":20220301T113000`,
+				"RRULE:FREQ=WEEKLY",
+				"STATUS:NEEDS-ACTION",
+				"BEGIN:VALARM",
+				"DESCRIPTION:Reminder",
+				"END:VALARM",
+				"END:VTODO",
+				"END:VCALENDAR",
+			},
+			want: Todo{
+				UID:         "1234-5678",
+				Summary:     "Buy milk; eggs, bread",
+				Description: "two\nbottles",
+				Categories:  []string{"home", "a,b", "errands"},
+				Priority:    1,
+				Due:         time.Date(2022, time.March, 1, 11, 30, 0, 0, kyiv).UTC(),
+				RRule:       "FREQ=WEEKLY",
+				Stamp:       time.Date(2022, time.February, 21, 8, 15, 0, 0, time.UTC),
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "ok completed date",
+			input: []string{
+				"BEGIN:VCALENDAR",
+				"BEGIN:VTODO",
+				"UID:a1",
+				"SUMMARY:Call mom",
+				"DUE;VALUE=DATE:20220301",
+				"COMPLETED:20220302T093000Z",
+				"END:VTODO",
+				"END:VCALENDAR",
+			},
+			want: Todo{
+				UID:         "a1",
+				Summary:     "Call mom",
+				Due:         time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC),
+				Completed:   true,
+				CompletedAt: due.AddDate(0, 0, 1),
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name: "missing VTODO",
+			input: []string{
+				"BEGIN:VCALENDAR",
+				"BEGIN:VEVENT",
+				"UID:a1",
+				"END:VEVENT",
+				"END:VCALENDAR",
+			},
+			errAssert: assert.Error,
+		},
+		{
+			name: "more than one VTODO",
+			input: []string{
+				"BEGIN:VCALENDAR",
+				"BEGIN:VTODO",
+				"UID:a1",
+				"END:VTODO",
+				"BEGIN:VTODO",
+				"UID:b2",
+				"END:VTODO",
+				"END:VCALENDAR",
+			},
+			errAssert: assert.Error,
+		},
+		{
+			name: "missing UID",
+			input: []string{
+				"BEGIN:VCALENDAR",
+				"BEGIN:VTODO",
+				"SUMMARY:Call mom",
+				"END:VTODO",
+				"END:VCALENDAR",
+			},
+			errAssert: assert.Error,
+		},
+		{
+			name: "invalid due date",
+			input: []string{
+				"BEGIN:VCALENDAR",
+				"BEGIN:VTODO",
+				"UID:a1",
+				"DUE:tomorrow",
+				"END:VTODO",
+				"END:VCALENDAR",
+			},
+			errAssert: assert.Error,
+		},
+		{
+			name: "unterminated",
+			input: []string{
+				"BEGIN:VCALENDAR",
+				"BEGIN:VTODO",
+				"UID:a1",
+				"END:VTODO",
+			},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		got, err := Parse(strings.NewReader(strings.Join(tt.input, "\r\n") + "\r\n"))
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestParse_roundTrip(t *testing.T) {
+	todo := Todo{
+		UID:          "a1@todo-service",
+		Summary:      strings.Repeat("Buy milk, eggs; bread ", 5),
+		Description:  "two\nbottles \\ fresh",
+		Categories:   []string{"home", "a,b"},
+		Priority:     5,
+		Due:          time.Date(2022, time.March, 1, 9, 30, 0, 0, time.UTC),
+		RRule:        "FREQ=WEEKLY;BYDAY=MO,TH",
+		Completed:    true,
+		CompletedAt:  time.Date(2022, time.March, 2, 9, 30, 0, 0, time.UTC),
+		Created:      time.Date(2022, time.February, 20, 8, 0, 0, 0, time.UTC),
+		LastModified: time.Date(2022, time.March, 2, 9, 30, 0, 0, time.UTC),
+		Stamp:        time.Date(2022, time.March, 2, 9, 30, 0, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, "Todos")
+	require.NoError(t, w.Write(todo))
+	require.NoError(t, w.Close())
+
+	got, err := Parse(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, todo, got)
+}
@@ -0,0 +1,253 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout          = "20060102"
+	localDateTimeLayout = "20060102T150405"
+)
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse ignores nested components and converts times to UTC.
+func Parse(r io.Reader) (Todo, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return Todo{}, err
+	}
+
+	var (
+		todo       Todo
+		components []string
+		found      bool
+		status     string
+	)
+
+	for i, line := range lines {
+		prop, err := parseProperty(line)
+		if err != nil {
+			return Todo{}, fmt.Errorf("line %d: %s", i+1, err.Error())
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			component := strings.ToUpper(prop.value)
+			if len(components) == 0 && component != "VCALENDAR" {
+				return Todo{}, errors.New("not a calendar")
+			}
+			if len(components) == 1 && component == "VTODO" {
+				if found {
+					return Todo{}, errors.New("more than one VTODO")
+				}
+				found = true
+			}
+			components = append(components, component)
+			continue
+		case "END":
+			if len(components) == 0 || components[len(components)-1] != strings.ToUpper(prop.value) {
+				return Todo{}, fmt.Errorf("line %d: unexpected END:%s", i+1, prop.value)
+			}
+			components = components[:len(components)-1]
+			continue
+		}
+
+		if len(components) != 2 || components[1] != "VTODO" {
+			continue
+		}
+
+		if err := todo.set(prop, &status); err != nil {
+			return Todo{}, fmt.Errorf("line %d: invalid %s: %s", i+1, prop.name, err.Error())
+		}
+	}
+
+	if len(components) != 0 {
+		return Todo{}, errors.New("unterminated component")
+	}
+	if !found {
+		return Todo{}, errors.New("missing VTODO")
+	}
+	if len(todo.UID) == 0 {
+		return Todo{}, errors.New("missing UID")
+	}
+
+	switch status {
+	case "COMPLETED":
+		todo.Completed = true
+	case "":
+		todo.Completed = !todo.CompletedAt.IsZero()
+	}
+
+	return todo, nil
+}
+
+func (todo *Todo) set(prop property, status *string) error {
+	var err error
+
+	switch prop.name {
+	case "UID":
+		todo.UID = prop.value
+	case "SUMMARY":
+		todo.Summary = unescapeText(prop.value)
+	case "DESCRIPTION":
+		todo.Description = unescapeText(prop.value)
+	case "CATEGORIES":
+		for _, category := range splitText(prop.value) {
+			if category = strings.TrimSpace(category); len(category) != 0 {
+				todo.Categories = append(todo.Categories, category)
+			}
+		}
+	case "PRIORITY":
+		todo.Priority, err = strconv.Atoi(prop.value)
+		if err == nil && (todo.Priority < 0 || todo.Priority > 9) {
+			err = errors.New("out of range")
+		}
+	case "DUE":
+		todo.Due, err = parseTime(prop)
+	case "RRULE":
+		todo.RRule = prop.value
+	case "STATUS":
+		*status = strings.ToUpper(prop.value)
+	case "COMPLETED":
+		todo.CompletedAt, err = parseTime(prop)
+	case "CREATED":
+		todo.Created, err = parseTime(prop)
+	case "LAST-MODIFIED":
+		todo.LastModified, err = parseTime(prop)
+	case "DTSTAMP":
+		todo.Stamp, err = parseTime(prop)
+	}
+
+	return err
+}
+
+// unfold joins the lines that were folded to fit 75 octets back together.
+func unfold(r io.Reader) ([]string, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+
+	var lines []string
+	for s.Scan() {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		switch {
+		case len(line) == 0:
+		case (line[0] == ' ' || line[0] == '\t') && len(lines) != 0:
+			lines[len(lines)-1] += line[1:]
+		default:
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, s.Err()
+}
+
+// parseProperty splits a content line into its name, parameters and value.
+// Parameter values may be quoted, and quoted ones may contain colons.
+func parseProperty(line string) (property, error) {
+	prop := property{params: make(map[string]string)}
+
+	end := strings.IndexAny(line, ";:")
+	if end <= 0 {
+		return property{}, errors.New("malformed content line")
+	}
+	prop.name = strings.ToUpper(line[:end])
+
+	for line[end] == ';' {
+		line = line[end+1:]
+
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			return property{}, errors.New("malformed parameter")
+		}
+		name := strings.ToUpper(line[:eq])
+		line = line[eq+1:]
+
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			closing := strings.IndexByte(line[1:], '"')
+			if closing < 0 {
+				return property{}, errors.New("unterminated parameter value")
+			}
+			value, line = line[1:closing+1], line[closing+2:]
+		} else {
+			i := strings.IndexAny(line, ";:")
+			if i < 0 {
+				return property{}, errors.New("malformed parameter")
+			}
+			value, line = line[:i], line[i:]
+		}
+		prop.params[name] = value
+
+		if len(line) == 0 || (line[0] != ';' && line[0] != ':') {
+			return property{}, errors.New("malformed parameter")
+		}
+		end = 0
+	}
+
+	prop.value = line[end+1:]
+
+	return prop, nil
+}
+
+// parseTime accepts dates, which are taken as UTC midnight, and date-times in
+// UTC, in the time zone named by TZID or floating.
+func parseTime(prop property) (time.Time, error) {
+	if prop.params["VALUE"] == "DATE" || len(prop.value) == len(dateLayout) {
+		return time.Parse(dateLayout, prop.value)
+	}
+
+	if strings.HasSuffix(prop.value, "Z") {
+		return time.Parse(dateTimeLayout, prop.value)
+	}
+
+	location := time.UTC
+	if tzid, ok := prop.params["TZID"]; ok {
+		if loaded, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			location = loaded
+		}
+	}
+
+	t, err := time.ParseInLocation(localDateTimeLayout, prop.value, location)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return t.UTC(), nil
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func unescapeText(value string) string {
+	return textUnescaper.Replace(value)
+}
+
+// splitText splits a list of text values on the commas that are not escaped.
+func splitText(value string) []string {
+	var (
+		values []string
+		start  int
+	)
+
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, unescapeText(value[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(values, unescapeText(value[start:]))
+}
@@ -0,0 +1,341 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+	OpMove    = "move"
+	OpCopy    = "copy"
+	OpTest    = "test"
+)
+
+var (
+	ErrTestFailed   = errors.New("test operation failed")
+	ErrPathNotFound = errors.New("path not found")
+)
+
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Patch is a JSON Patch document as described in RFC 6902.
+type Patch []Operation
+
+// Decode parses a patch document and checks that every operation carries the
+// members it needs. A missing value is told apart from a null one.
+func Decode(data []byte) (Patch, error) {
+	var patch Patch
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, err
+	}
+
+	for i, op := range patch {
+		switch op.Op {
+		case OpAdd, OpReplace, OpTest:
+			if op.Value == nil {
+				return nil, fmt.Errorf("operation %d: missing value", i)
+			}
+		case OpMove, OpCopy:
+			if _, err := parsePointer(op.From); err != nil {
+				return nil, fmt.Errorf("operation %d: %s", i, err.Error())
+			}
+		case OpRemove:
+		default:
+			return nil, fmt.Errorf("operation %d: unknown op %q", i, op.Op)
+		}
+
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("operation %d: %s", i, err.Error())
+		}
+	}
+
+	return patch, nil
+}
+
+// Apply applies the operations to the document in order. The document is
+// left as is when any of them fails.
+func (p Patch) Apply(doc []byte) ([]byte, error) {
+	var root any
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, fmt.Errorf("could not unmarshal document: %s", err.Error())
+	}
+
+	for i, op := range p {
+		var err error
+		if root, err = op.apply(root); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	return json.Marshal(root)
+}
+
+func (op Operation) apply(root any) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case OpAdd:
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case OpRemove:
+		root, _, err := remove(root, path)
+		return root, err
+	case OpReplace:
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		if root, _, err = remove(root, path); err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case OpMove:
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if len(from) < len(path) && isPrefix(from, path) {
+			return nil, errors.New("cannot move a value into one of its children")
+		}
+		root, value, err := remove(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case OpCopy:
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(root, from)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = deepCopy(value); err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case OpTest:
+		want, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		got, err := get(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(got, want) {
+			return nil, ErrTestFailed
+		}
+		return root, nil
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+}
+
+func (op Operation) value() (any, error) {
+	var value any
+	if err := json.Unmarshal(op.Value, &value); err != nil {
+		return nil, fmt.Errorf("could not unmarshal value: %s", err.Error())
+	}
+
+	return value, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped reference
+// tokens. The empty pointer refers to the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if len(pointer) == 0 {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func isPrefix(prefix, tokens []string) bool {
+	for i := range prefix {
+		if prefix[i] != tokens[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func get(node any, path []string) (any, error) {
+	if len(path) == 0 {
+		return node, nil
+	}
+
+	switch node := node.(type) {
+	case map[string]any:
+		child, ok := node[path[0]]
+		if !ok {
+			return nil, ErrPathNotFound
+		}
+		return get(child, path[1:])
+	case []any:
+		i, err := parseIndex(path[0], len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		return get(node[i], path[1:])
+	default:
+		return nil, ErrPathNotFound
+	}
+}
+
+// add returns the node with the value added at the path. Arrays may be
+// reallocated, so callers store the returned node in place of the old one.
+func add(node any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token, rest := path[0], path[1:]
+
+	switch node := node.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, ErrPathNotFound
+		}
+		child, err := add(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+	case []any:
+		if len(rest) == 0 {
+			i := len(node)
+			if token != "-" {
+				var err error
+				if i, err = parseIndex(token, len(node)); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		i, err := parseIndex(token, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		child, err := add(node[i], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	default:
+		return nil, ErrPathNotFound
+	}
+}
+
+func remove(node any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+
+	token, rest := path[0], path[1:]
+
+	switch node := node.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, ErrPathNotFound
+		}
+		if len(rest) == 0 {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := remove(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = child
+		return node, removed, nil
+	case []any:
+		i, err := parseIndex(token, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := node[i]
+			return append(node[:i], node[i+1:]...), removed, nil
+		}
+		child, removed, err := remove(node[i], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		node[i] = child
+		return node, removed, nil
+	default:
+		return nil, nil, ErrPathNotFound
+	}
+}
+
+func parseIndex(token string, last int) (int, error) {
+	if len(token) == 0 || (len(token) > 1 && token[0] == '0') ||
+		strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > last {
+		return 0, ErrPathNotFound
+	}
+
+	return i, nil
+}
+
+func deepCopy(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var copied any
+	err = json.Unmarshal(data, &copied)
+
+	return copied, err
+}
@@ -0,0 +1,213 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      Patch
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "ok",
+			input: `[{"op": "replace", "path": "/title", "value": "t"}, {"op": "remove", "path": "/description"}]`,
+			want: Patch{
+				{Op: OpReplace, Path: "/title", Value: []byte(`"t"`)},
+				{Op: OpRemove, Path: "/description"},
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok null value",
+			input:     `[{"op": "add", "path": "/dueAt", "value": null}]`,
+			want:      Patch{{Op: OpAdd, Path: "/dueAt", Value: []byte(`null`)}},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "missing value",
+			input:     `[{"op": "add", "path": "/title"}]`,
+			want:      nil,
+			errAssert: assert.Error,
+		},
+		{
+			name:      "unknown op",
+			input:     `[{"op": "merge", "path": "/title", "value": "t"}]`,
+			want:      nil,
+			errAssert: assert.Error,
+		},
+		{
+			name:      "invalid pointer",
+			input:     `[{"op": "remove", "path": "title"}]`,
+			want:      nil,
+			errAssert: assert.Error,
+		},
+		{
+			name:      "invalid from",
+			input:     `[{"op": "move", "from": "title", "path": "/description"}]`,
+			want:      nil,
+			errAssert: assert.Error,
+		},
+		{
+			name:      "not an array",
+			input:     `{"op": "remove", "path": "/title"}`,
+			want:      nil,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		got, err := Decode([]byte(tt.input))
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestPatch_Apply(t *testing.T) {
+	tests := []struct {
+		name      string
+		doc       string
+		patch     string
+		want      string
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "add object member",
+			doc:       `{"foo": "bar"}`,
+			patch:     `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			want:      `{"baz": "qux", "foo": "bar"}`,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "add array element",
+			doc:       `{"foo": ["bar", "baz"]}`,
+			patch:     `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			want:      `{"foo": ["bar", "qux", "baz"]}`,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "add to the end of an array",
+			doc:       `{"foo": ["bar"]}`,
+			patch:     `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			want:      `{"foo": ["bar", ["abc", "def"]]}`,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "remove object member",
+			doc:       `{"baz": "qux", "foo": "bar"}`,
+			patch:     `[{"op": "remove", "path": "/baz"}]`,
+			want:      `{"foo": "bar"}`,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "remove array element",
+			doc:       `{"foo": ["bar", "qux", "baz"]}`,
+			patch:     `[{"op": "remove", "path": "/foo/1"}]`,
+			want:      `{"foo": ["bar", "baz"]}`,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "replace",
+			doc:       `{"baz": "qux", "foo": "bar"}`,
+			patch:     `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			want:      `{"baz": "boo", "foo": "bar"}`,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "replace whole document",
+			doc:       `{"foo": "bar"}`,
+			patch:     `[{"op": "replace", "path": "", "value": {"baz": "qux"}}]`,
+			want:      `{"baz": "qux"}`,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "move",
+			doc:       `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch:     `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			want:      `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "move array element",
+			doc:       `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch:     `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			want:      `{"foo": ["all", "cows", "eat", "grass"]}`,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "copy",
+			doc:       `{"foo": {"bar": 1}}`,
+			patch:     `[{"op": "copy", "from": "/foo", "path": "/baz"}, {"op": "replace", "path": "/baz/bar", "value": 2}]`,
+			want:      `{"baz": {"bar": 2}, "foo": {"bar": 1}}`,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "test",
+			doc:       `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			patch:     `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`,
+			want:      `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "escaped pointer",
+			doc:       `{"/": 9, "~1": 10}`,
+			patch:     `[{"op": "test", "path": "/~01", "value": 10}, {"op": "remove", "path": "/~1"}]`,
+			want:      `{"~1": 10}`,
+			errAssert: assert.NoError,
+		},
+		{
+			name:  "test failed",
+			doc:   `{"baz": "qux"}`,
+			patch: `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrTestFailed)
+			},
+		},
+		{
+			name:  "missing member",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "remove", "path": "/baz"}]`,
+			errAssert: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, ErrPathNotFound)
+			},
+		},
+		{
+			name:      "missing parent",
+			doc:       `{"foo": "bar"}`,
+			patch:     `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			errAssert: assert.Error,
+		},
+		{
+			name:      "index out of bounds",
+			doc:       `{"foo": ["bar"]}`,
+			patch:     `[{"op": "add", "path": "/foo/2", "value": "qux"}]`,
+			errAssert: assert.Error,
+		},
+		{
+			name:      "leading zero index",
+			doc:       `{"foo": ["bar", "baz"]}`,
+			patch:     `[{"op": "remove", "path": "/foo/01"}]`,
+			errAssert: assert.Error,
+		},
+		{
+			name:      "move into a child",
+			doc:       `{"foo": {"bar": 1}}`,
+			patch:     `[{"op": "move", "from": "/foo", "path": "/foo/bar"}]`,
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		patch, err := Decode([]byte(tt.patch))
+		require.NoError(t, err, tt.name)
+
+		got, err := patch.Apply([]byte(tt.doc))
+		tt.errAssert(t, err, tt.name)
+		if len(tt.want) != 0 {
+			assert.JSONEq(t, tt.want, string(got), tt.name)
+		}
+	}
+}
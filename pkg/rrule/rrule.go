@@ -0,0 +1,301 @@
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+const (
+	untilDateTimeLayout = "20060102T150405Z"
+	untilDateLayout     = "20060102"
+	// maxSkips bounds the search for a valid date, e.g. the next February 29
+	// for a yearly rule or the next 31st for a monthly one.
+	maxSkips = 1000
+)
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+var weekdayNames = map[time.Weekday]string{
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+	time.Sunday:    "SU",
+}
+
+// Rule is the subset of an RFC 5545 recurrence rule made of FREQ, INTERVAL,
+// BYDAY, COUNT and UNTIL. COUNT includes the occurrence the rule starts from.
+type Rule struct {
+	Freq     Frequency
+	Interval uint
+	ByDay    []time.Weekday
+	Count    uint
+	Until    *time.Time
+}
+
+func Parse(value string) (Rule, error) {
+	rule := Rule{Interval: 1}
+
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if len(value) == 0 {
+		return Rule{}, errors.New("empty rule")
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		if !ok || len(val) == 0 {
+			return Rule{}, fmt.Errorf("malformed rule part %q", part)
+		}
+		name = strings.ToUpper(name)
+		if seen[name] {
+			return Rule{}, fmt.Errorf("duplicate rule part %s", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "FREQ":
+			freq := Frequency(strings.ToUpper(val))
+			switch freq {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = freq
+			default:
+				return Rule{}, fmt.Errorf("unsupported frequency %s", val)
+			}
+		case "INTERVAL":
+			interval, err := strconv.ParseUint(val, 10, 32)
+			if err != nil || interval == 0 {
+				return Rule{}, fmt.Errorf("invalid interval %s", val)
+			}
+			rule.Interval = uint(interval)
+		case "BYDAY":
+			days, err := parseByDay(val)
+			if err != nil {
+				return Rule{}, err
+			}
+			rule.ByDay = days
+		case "COUNT":
+			count, err := strconv.ParseUint(val, 10, 32)
+			if err != nil || count == 0 {
+				return Rule{}, fmt.Errorf("invalid count %s", val)
+			}
+			rule.Count = uint(count)
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return Rule{}, err
+			}
+			rule.Until = &until
+		default:
+			return Rule{}, fmt.Errorf("unsupported rule part %s", name)
+		}
+	}
+
+	if len(rule.Freq) == 0 {
+		return Rule{}, errors.New("missing frequency")
+	}
+	if rule.Count != 0 && rule.Until != nil {
+		return Rule{}, errors.New("count and until are mutually exclusive")
+	}
+	if len(rule.ByDay) != 0 && rule.Freq != Daily && rule.Freq != Weekly {
+		return Rule{}, fmt.Errorf("byday is not supported with frequency %s", rule.Freq)
+	}
+
+	return rule, nil
+}
+
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if len(r.ByDay) != 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = weekdayNames[day]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count != 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilDateTimeLayout))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Next returns the occurrence that follows start together with the rule that
+// continues the series from it, or false when the series is over.
+func (r Rule) Next(start time.Time) (time.Time, Rule, bool) {
+	occurrences := r.Occurrences(start, 1)
+	if len(occurrences) == 0 {
+		return time.Time{}, Rule{}, false
+	}
+
+	next := r
+	if next.Count != 0 {
+		next.Count--
+	}
+
+	return occurrences[0], next, true
+}
+
+func (r Rule) Occurrences(start time.Time, n uint) []time.Time {
+	if r.Count != 0 && n > r.Count-1 {
+		n = r.Count - 1
+	}
+
+	interval := int(r.Interval)
+	if interval == 0 {
+		interval = 1
+	}
+
+	occurrences := make([]time.Time, 0, n)
+	add := func(t time.Time) bool {
+		if !t.After(start) {
+			return true
+		}
+		if r.Until != nil && t.After(*r.Until) {
+			return false
+		}
+		occurrences = append(occurrences, t)
+		return uint(len(occurrences)) < n
+	}
+
+	if n == 0 {
+		return occurrences
+	}
+
+	switch r.Freq {
+	case Daily:
+		for step, skips := 1, 0; skips < maxSkips; step++ {
+			t := start.AddDate(0, 0, step*interval)
+			if !r.matchesDay(t) {
+				skips++
+				continue
+			}
+			if !add(t) {
+				break
+			}
+		}
+	case Weekly:
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+		offsets := make([]int, len(days))
+		for i, day := range days {
+			offsets[i] = mondayOffset(day)
+		}
+		sort.Ints(offsets)
+
+		weekStart := start.AddDate(0, 0, -mondayOffset(start.Weekday()))
+	weeks:
+		for week := 0; week < maxSkips; week++ {
+			for _, offset := range offsets {
+				if !add(weekStart.AddDate(0, 0, week*7*interval+offset)) {
+					break weeks
+				}
+			}
+		}
+	case Monthly:
+		for step, skips := 1, 0; skips < maxSkips; step++ {
+			t := start.AddDate(0, step*interval, 0)
+			if t.Day() != start.Day() {
+				skips++
+				continue
+			}
+			if !add(t) {
+				break
+			}
+		}
+	case Yearly:
+		for step, skips := 1, 0; skips < maxSkips; step++ {
+			t := start.AddDate(step*interval, 0, 0)
+			if t.Day() != start.Day() || t.Month() != start.Month() {
+				skips++
+				continue
+			}
+			if !add(t) {
+				break
+			}
+		}
+	}
+
+	return occurrences
+}
+
+func (r Rule) matchesDay(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, day := range r.ByDay {
+		if t.Weekday() == day {
+			return true
+		}
+	}
+	return false
+}
+
+func mondayOffset(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+func parseByDay(value string) ([]time.Weekday, error) {
+	seen := make(map[time.Weekday]bool)
+	days := make([]time.Weekday, 0)
+
+	for _, name := range strings.Split(value, ",") {
+		day, ok := weekdays[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported weekday %s", name)
+		}
+		if seen[day] {
+			continue
+		}
+		seen[day] = true
+		days = append(days, day)
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return mondayOffset(days[i]) < mondayOffset(days[j])
+	})
+
+	return days, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	if until, err := time.Parse(untilDateTimeLayout, value); err == nil {
+		return until, nil
+	}
+	if until, err := time.Parse(untilDateLayout, value); err == nil {
+		return until.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid until %s", value)
+}
@@ -0,0 +1,193 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	until := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		input     string
+		want      Rule
+		errAssert assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "ok daily",
+			input:     "FREQ=DAILY",
+			want:      Rule{Freq: Daily, Interval: 1},
+			errAssert: assert.NoError,
+		},
+		{
+			name:  "ok weekly with byday",
+			input: "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=FR,mo,FR;COUNT=3",
+			want: Rule{
+				Freq:     Weekly,
+				Interval: 2,
+				ByDay:    []time.Weekday{time.Monday, time.Friday},
+				Count:    3,
+			},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "ok until",
+			input:     "FREQ=MONTHLY;UNTIL=20220301T000000Z",
+			want:      Rule{Freq: Monthly, Interval: 1, Until: &until},
+			errAssert: assert.NoError,
+		},
+		{
+			name:      "empty",
+			input:     "",
+			want:      Rule{},
+			errAssert: assert.Error,
+		},
+		{
+			name:      "missing frequency",
+			input:     "COUNT=3",
+			want:      Rule{},
+			errAssert: assert.Error,
+		},
+		{
+			name:      "unsupported part",
+			input:     "FREQ=DAILY;BYHOUR=9",
+			want:      Rule{},
+			errAssert: assert.Error,
+		},
+		{
+			name:      "zero interval",
+			input:     "FREQ=DAILY;INTERVAL=0",
+			want:      Rule{},
+			errAssert: assert.Error,
+		},
+		{
+			name:      "count with until",
+			input:     "FREQ=DAILY;COUNT=2;UNTIL=20220301",
+			want:      Rule{},
+			errAssert: assert.Error,
+		},
+		{
+			name:      "byday with monthly",
+			input:     "FREQ=MONTHLY;BYDAY=MO",
+			want:      Rule{},
+			errAssert: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.input)
+		tt.errAssert(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestRule_String(t *testing.T) {
+	const value = "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;UNTIL=20220301T000000Z"
+
+	rule, err := Parse(value)
+	require.NoError(t, err)
+
+	assert.Equal(t, value, rule.String())
+}
+
+func TestRule_Occurrences(t *testing.T) {
+	// Wednesday
+	start := time.Date(2022, time.January, 5, 9, 0, 0, 0, time.UTC)
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2022, month, d, 9, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		n     uint
+		want  []time.Time
+	}{
+		{
+			name:  "daily with interval",
+			input: "FREQ=DAILY;INTERVAL=3",
+			n:     2,
+			want:  []time.Time{day(time.January, 8), day(time.January, 11)},
+		},
+		{
+			name:  "daily with byday",
+			input: "FREQ=DAILY;BYDAY=MO,FR",
+			n:     3,
+			want:  []time.Time{day(time.January, 7), day(time.January, 10), day(time.January, 14)},
+		},
+		{
+			name:  "weekly",
+			input: "FREQ=WEEKLY",
+			n:     2,
+			want:  []time.Time{day(time.January, 12), day(time.January, 19)},
+		},
+		{
+			name:  "weekly with byday and interval",
+			input: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH",
+			n:     3,
+			want:  []time.Time{day(time.January, 6), day(time.January, 17), day(time.January, 20)},
+		},
+		{
+			name:  "monthly skips short months",
+			input: "FREQ=MONTHLY",
+			n:     2,
+			want:  []time.Time{day(time.February, 5), day(time.March, 5)},
+		},
+		{
+			name:  "count includes start",
+			input: "FREQ=DAILY;COUNT=3",
+			n:     10,
+			want:  []time.Time{day(time.January, 6), day(time.January, 7)},
+		},
+		{
+			name:  "until",
+			input: "FREQ=DAILY;UNTIL=20220107",
+			n:     10,
+			want:  []time.Time{day(time.January, 6), day(time.January, 7)},
+		},
+		{
+			name:  "exhausted",
+			input: "FREQ=DAILY;COUNT=1",
+			n:     10,
+			want:  []time.Time{},
+		},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.input)
+		require.NoError(t, err, tt.name)
+
+		got := rule.Occurrences(start, tt.n)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestRule_OccurrencesMonthlyEndOfMonth(t *testing.T) {
+	start := time.Date(2022, time.January, 31, 0, 0, 0, 0, time.UTC)
+
+	rule, err := Parse("FREQ=MONTHLY")
+	require.NoError(t, err)
+
+	got := rule.Occurrences(start, 2)
+	assert.Equal(t, []time.Time{
+		time.Date(2022, time.March, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, time.May, 31, 0, 0, 0, 0, time.UTC),
+	}, got)
+}
+
+func TestRule_Next(t *testing.T) {
+	start := time.Date(2022, time.January, 5, 9, 0, 0, 0, time.UTC)
+
+	rule, err := Parse("FREQ=DAILY;COUNT=2")
+	require.NoError(t, err)
+
+	next, nextRule, ok := rule.Next(start)
+	require.True(t, ok)
+	assert.Equal(t, start.AddDate(0, 0, 1), next)
+	assert.Equal(t, uint(1), nextRule.Count)
+
+	_, _, ok = nextRule.Next(next)
+	assert.False(t, ok)
+}
@@ -0,0 +1,10 @@
+DROP TRIGGER todos_updated_at ON todos;
+CREATE TRIGGER todos_updated_at
+    BEFORE UPDATE
+    OF title, description, completed
+    ON todos
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at();
+
+DROP INDEX idx_todos_due_at;
+ALTER TABLE todos DROP COLUMN due_at;
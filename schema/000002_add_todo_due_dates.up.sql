@@ -0,0 +1,10 @@
+ALTER TABLE todos ADD COLUMN due_at TIMESTAMPTZ NULL;
+CREATE INDEX idx_todos_due_at ON todos (due_at);
+
+DROP TRIGGER todos_updated_at ON todos;
+CREATE TRIGGER todos_updated_at
+    BEFORE UPDATE
+    OF title, description, completed, due_at
+    ON todos
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at();
@@ -0,0 +1,11 @@
+DROP TRIGGER todos_updated_at ON todos;
+CREATE TRIGGER todos_updated_at
+    BEFORE UPDATE
+    OF title, description, completed, due_at
+    ON todos
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at();
+
+DROP INDEX idx_todos_priority;
+ALTER TABLE todos DROP CONSTRAINT chk_todos_priority;
+ALTER TABLE todos DROP COLUMN priority;
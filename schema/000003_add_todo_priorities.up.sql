@@ -0,0 +1,11 @@
+ALTER TABLE todos ADD COLUMN priority SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE todos ADD CONSTRAINT chk_todos_priority CHECK (priority BETWEEN 0 AND 4);
+CREATE INDEX idx_todos_priority ON todos (priority);
+
+DROP TRIGGER todos_updated_at ON todos;
+CREATE TRIGGER todos_updated_at
+    BEFORE UPDATE
+    OF title, description, completed, priority, due_at
+    ON todos
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at();
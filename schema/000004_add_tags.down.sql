@@ -0,0 +1,4 @@
+DROP TRIGGER tags_updated_at ON tags;
+
+DROP TABLE todos_tags;
+DROP TABLE tags;
@@ -0,0 +1,26 @@
+CREATE TABLE tags (
+    id SERIAL NOT NULL,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_tags_id PRIMARY KEY (id),
+    CONSTRAINT uq_tags_user_id_name UNIQUE (user_id, name),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE todos_tags (
+    todo_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    CONSTRAINT pk_todos_tags PRIMARY KEY (todo_id, tag_id),
+    CONSTRAINT fk_todo_id FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+    CONSTRAINT fk_tag_id FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_todos_tags_tag_id ON todos_tags (tag_id);
+
+CREATE TRIGGER tags_updated_at
+    BEFORE UPDATE
+    OF name
+    ON tags
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at();
@@ -0,0 +1,12 @@
+DROP TRIGGER todo_items_updated_at ON todo_items;
+DROP TABLE todo_items;
+
+DROP TRIGGER todos_updated_at ON todos;
+CREATE TRIGGER todos_updated_at
+    BEFORE UPDATE
+    OF title, description, completed, priority, due_at
+    ON todos
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at();
+
+ALTER TABLE todos DROP COLUMN auto_complete;
@@ -0,0 +1,29 @@
+ALTER TABLE todos ADD COLUMN auto_complete BOOLEAN NOT NULL DEFAULT FALSE;
+
+DROP TRIGGER todos_updated_at ON todos;
+CREATE TRIGGER todos_updated_at
+    BEFORE UPDATE
+    OF title, description, completed, priority, due_at, auto_complete
+    ON todos
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at();
+
+CREATE TABLE todo_items (
+    id SERIAL NOT NULL,
+    todo_id INTEGER NOT NULL,
+    title VARCHAR(64) NOT NULL,
+    completed BOOLEAN NOT NULL,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_todo_items_id PRIMARY KEY (id),
+    CONSTRAINT fk_todo_id FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_todo_items_todo_id_position ON todo_items (todo_id, position);
+
+CREATE TRIGGER todo_items_updated_at
+    BEFORE UPDATE
+    OF title, completed, position
+    ON todo_items
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at();
@@ -0,0 +1,9 @@
+DROP TRIGGER todos_updated_at ON todos;
+CREATE TRIGGER todos_updated_at
+    BEFORE UPDATE
+    OF title, description, completed, priority, due_at, auto_complete
+    ON todos
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at();
+
+ALTER TABLE todos DROP COLUMN recurrence;
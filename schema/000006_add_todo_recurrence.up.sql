@@ -0,0 +1,9 @@
+ALTER TABLE todos ADD COLUMN recurrence VARCHAR(256) NOT NULL DEFAULT '';
+
+DROP TRIGGER todos_updated_at ON todos;
+CREATE TRIGGER todos_updated_at
+    BEFORE UPDATE
+    OF title, description, completed, priority, due_at, auto_complete, recurrence
+    ON todos
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at();
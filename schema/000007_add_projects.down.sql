@@ -0,0 +1,6 @@
+DROP INDEX idx_users_todos_project_id;
+ALTER TABLE users_todos DROP CONSTRAINT fk_project_id;
+ALTER TABLE users_todos DROP COLUMN project_id;
+
+DROP TRIGGER projects_updated_at ON projects;
+DROP TABLE projects;
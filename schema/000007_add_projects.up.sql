@@ -0,0 +1,24 @@
+CREATE TABLE projects (
+    id SERIAL NOT NULL,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    color VARCHAR(7) NOT NULL DEFAULT '',
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_projects_id PRIMARY KEY (id),
+    CONSTRAINT uq_projects_user_id_name UNIQUE (user_id, name),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER projects_updated_at
+    BEFORE UPDATE
+    OF name, color, archived
+    ON projects
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at();
+
+ALTER TABLE users_todos ADD COLUMN project_id INTEGER NULL;
+ALTER TABLE users_todos ADD CONSTRAINT fk_project_id
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE SET NULL;
+CREATE INDEX idx_users_todos_project_id ON users_todos (project_id);
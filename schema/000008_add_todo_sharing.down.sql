@@ -0,0 +1,5 @@
+DELETE FROM users_todos WHERE role <> 'owner';
+
+DROP INDEX idx_users_todos_todo_id;
+ALTER TABLE users_todos DROP CONSTRAINT chk_users_todos_role;
+ALTER TABLE users_todos DROP COLUMN role;
@@ -0,0 +1,4 @@
+ALTER TABLE users_todos ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'owner';
+ALTER TABLE users_todos ADD CONSTRAINT chk_users_todos_role
+    CHECK (role IN ('owner', 'editor', 'viewer'));
+CREATE INDEX idx_users_todos_todo_id ON users_todos (todo_id);
@@ -0,0 +1,3 @@
+DROP INDEX idx_todos_search;
+
+ALTER TABLE todos DROP COLUMN search;
@@ -0,0 +1,6 @@
+ALTER TABLE todos ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', title), 'A') ||
+    setweight(to_tsvector('english', description), 'B')
+) STORED;
+
+CREATE INDEX idx_todos_search ON todos USING GIN (search);
@@ -0,0 +1,5 @@
+DELETE FROM todos WHERE deleted_at IS NOT NULL;
+
+DROP INDEX idx_todos_deleted_at;
+
+ALTER TABLE todos DROP COLUMN deleted_at;
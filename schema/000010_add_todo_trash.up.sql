@@ -0,0 +1,3 @@
+ALTER TABLE todos ADD COLUMN deleted_at TIMESTAMPTZ NULL;
+
+CREATE INDEX idx_todos_deleted_at ON todos (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP TRIGGER todo_events_append_only ON todo_events;
+DROP FUNCTION forbid_todo_event_changes();
+DROP TABLE todo_events;
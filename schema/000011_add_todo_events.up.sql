@@ -0,0 +1,31 @@
+CREATE TABLE todo_events (
+    id SERIAL NOT NULL,
+    todo_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    changes JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_todo_events_id PRIMARY KEY (id),
+    CONSTRAINT fk_todo_id FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT chk_todo_events_action CHECK (action IN ('created', 'updated', 'deleted', 'restored'))
+);
+CREATE INDEX idx_todo_events_todo_id_id ON todo_events (todo_id, id);
+
+-- Events are only ever removed together with their todo or user, which
+-- happens from within the triggers of the foreign keys.
+CREATE FUNCTION forbid_todo_event_changes()
+    RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND pg_trigger_depth() > 1 THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'todo events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER todo_events_append_only
+    BEFORE UPDATE OR DELETE
+    ON todo_events
+    FOR EACH ROW
+EXECUTE PROCEDURE forbid_todo_event_changes();
@@ -0,0 +1,4 @@
+DROP TRIGGER todos_version ON todos;
+DROP FUNCTION increment_version();
+
+ALTER TABLE todos DROP COLUMN version;
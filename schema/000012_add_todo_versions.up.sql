@@ -0,0 +1,15 @@
+ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+CREATE FUNCTION increment_version()
+    RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER todos_version
+    BEFORE UPDATE
+    ON todos
+    FOR EACH ROW
+EXECUTE PROCEDURE increment_version();
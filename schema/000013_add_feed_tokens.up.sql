@@ -0,0 +1,8 @@
+CREATE TABLE feed_tokens (
+    user_id INTEGER NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_feed_tokens_user_id PRIMARY KEY (user_id),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT uq_feed_tokens_token_hash UNIQUE (token_hash)
+);
@@ -0,0 +1,3 @@
+DROP INDEX uq_users_todos_calendar_name;
+ALTER TABLE users_todos DROP COLUMN calendar_uid;
+ALTER TABLE users_todos DROP COLUMN calendar_name;
@@ -0,0 +1,5 @@
+-- Todos created over CalDAV keep the resource name and UID their client chose,
+-- per user, since every collaborator syncs the todo on their own.
+ALTER TABLE users_todos ADD COLUMN calendar_name VARCHAR(255) NULL;
+ALTER TABLE users_todos ADD COLUMN calendar_uid VARCHAR(255) NULL;
+CREATE UNIQUE INDEX uq_users_todos_calendar_name ON users_todos (user_id, calendar_name);
@@ -0,0 +1,18 @@
+-- Every sign-in starts a family of refresh tokens, and each refresh rotates
+-- the token into a new one of the same family. Rotated tokens are kept to
+-- detect their reuse.
+CREATE SEQUENCE refresh_token_families;
+
+CREATE TABLE refresh_tokens (
+    id SERIAL NOT NULL,
+    user_id INTEGER NOT NULL,
+    family_id INTEGER NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    rotated_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_refresh_tokens_id PRIMARY KEY (id),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT uq_refresh_tokens_token_hash UNIQUE (token_hash)
+);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
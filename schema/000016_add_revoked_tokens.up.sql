@@ -0,0 +1,12 @@
+-- Access tokens are revoked one by one by their jti, or all at once by moving
+-- the cutoff of their user, which rejects every token issued before it.
+CREATE TABLE revoked_tokens (
+    jti VARCHAR(64) NOT NULL,
+    user_id INTEGER NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT pk_revoked_tokens_jti PRIMARY KEY (jti),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+ALTER TABLE users ADD COLUMN tokens_revoked_at TIMESTAMPTZ NULL;
@@ -0,0 +1 @@
+DROP TABLE dav_passwords;
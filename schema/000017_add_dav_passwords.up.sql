@@ -0,0 +1,10 @@
+-- CalDAV clients authenticate with an app password instead of the account
+-- password, so that access can be revoked without changing the latter.
+CREATE TABLE dav_passwords (
+    user_id INTEGER NOT NULL,
+    password_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_dav_passwords_user_id PRIMARY KEY (user_id),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT uq_dav_passwords_password_hash UNIQUE (password_hash)
+);